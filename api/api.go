@@ -1,60 +1,243 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"user-manager/auth"
 	"user-manager/database"
 	"user-manager/dto"
 	services "user-manager/internal"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Server struct {
-	Queries *database.Queries
-	Pool    *pgxpool.Pool
+	// Queries is the storage, see database.Querier for the backends.
+	Queries  database.Querier
+	Verifier *auth.Verifier
+	// Issuer signs tokens for password logins. Nil when only external tokens are accepted.
+	Issuer *auth.Issuer
+	// Events wakes the GET /users/events streams when users change.
+	Events database.Notifier
+	// GraphQLMaxDepth and GraphQLMaxComplexity bound the operations of /graphql.
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+
+	// streams is cancelled by CloseStreams to end the event streams.
+	streams     context.Context
+	stopStreams context.CancelFunc
 }
 
-func NewServer(queries *database.Queries, pool *pgxpool.Pool) *Server {
+func NewServer(queries database.Querier, events database.Notifier, verifier *auth.Verifier, issuer *auth.Issuer) *Server {
+	streams, stopStreams := context.WithCancel(context.Background())
 	return &Server{
-		Queries: queries,
-		Pool:    pool,
+		Queries:  queries,
+		Verifier: verifier,
+		Issuer:   issuer,
+		Events:   events,
+
+		GraphQLMaxDepth:      DefaultGraphQLMaxDepth,
+		GraphQLMaxComplexity: DefaultGraphQLMaxComplexity,
+
+		streams:     streams,
+		stopStreams: stopStreams,
 	}
 }
 
+// CloseStreams ends the open event streams, which would otherwise keep
+// http.Server.Shutdown waiting. Register it with http.Server.RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.stopStreams()
+}
+
 func (s *Server) UserRouter(r chi.Router) {
-	r.Get("/", s.getUsers)
-	r.Post("/", s.createUser)
-	r.Get("/{id}", s.getUser)
-	r.Patch("/{id}", s.updateUser)
-	r.Delete("/{id}", s.deleteUser)
+	r.Use(s.authenticate)
+
+	admin := requireRole(auth.RoleAdmin)
+	staff := requireRole(auth.RoleAdmin, auth.RoleSupport)
+	// support and self may edit some fields only, see services.checkEditable
+	anyone := requireRole(auth.RoleAdmin, auth.RoleSupport, auth.RoleSelf)
+
+	r.With(staff).Get("/", s.getUsers)
+	r.With(admin).Post("/", s.createUser)
+	r.With(staff).Get("/search", s.searchUsers)
+	r.With(admin).Post("/import", s.importUsers)
+	r.With(staff).Get("/export", s.exportUsers)
+	r.With(admin).Post("/batch", s.batchUsers)
+	r.With(staff).Get("/events", s.streamUserEvents)
+	r.With(anyone).Get("/{id}", s.getUser)
+	r.With(anyone).Patch("/{id}", s.updateUser)
+	r.With(anyone).Put("/{id}", s.replaceUser)
+	r.With(admin).Delete("/{id}", s.deleteUser)
+	r.With(admin).Post("/{id}/restore", s.restoreUser)
+	r.With(requireRole(auth.RoleAdmin, auth.RoleSelf)).Get("/{id}/roles", s.getUserRoles)
+	r.With(admin).Put("/{id}/roles", s.setUserRoles)
+	r.With(admin).Get("/{id}/audit", s.getUserAudit)
+	r.With(requireRole(auth.RoleAdmin, auth.RoleSelf)).Put("/{id}/password", s.setPassword)
 }
 
 // @Summary Get all users
-// @Description Retrieve a list of all users
+// @Description Retrieve a page of users. Supports keyset pagination with cursor, offset pagination with page, sorting and filtering
 // @Produce json
-// @Success 200 {array} dto.User
+// @Security BearerAuth
+// @Param limit query int false "Page size. Default 20, max 100"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param page query int false "Page number for offset pagination. Cannot be combined with cursor"
+// @Param sort query string false "Sort field" Enums(userId, firstName, lastName, email, phone, age, status)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Param status query string false "Filter by status" Enums(Active, Inactive)
+// @Param min_age query int false "Minimum age"
+// @Param max_age query int false "Maximum age"
+// @Param email_prefix query string false "Filter by email prefix"
+// @Param name_prefix query string false "Filter by first or last name prefix"
+// @Param include_deleted query bool false "Include soft deleted users"
+// @Success 200 {object} dto.UserPage
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
 // @Router /users [get]
 func (s *Server) getUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	fmt.Println("Get Users request received")
-	users, err := s.Queries.ListUsers(ctx)
+	params, err := listParams(r)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on parsing users list parameters", "error", err)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, err.Error())
+		return
+	}
+
+	page, err := services.ListUsers(ctx, params, s.Queries)
 	if err != nil {
-		fmt.Println("error on retrieving users list: ", err)
-		http.Error(w, "Error on Returing All Users", http.StatusInternalServerError)
+		slog.DebugContext(r.Context(), "error on retrieving users list", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
-	fmt.Println("users list retrieved", len(users))
+	slog.DebugContext(ctx, "users list retrieved", "count", len(page.Users))
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(users)
+	err = json.NewEncoder(w).Encode(page)
 	if err != nil {
-		fmt.Println("error on retrieving users list: ", err)
-		http.Error(w, "Error on Returing All Users", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Returing All Users")
+		return
+	}
+}
+
+func listParams(r *http.Request) (dto.UserListParams, error) {
+	query := r.URL.Query()
+	params := dto.UserListParams{
+		Cursor:      query.Get("cursor"),
+		Sort:        query.Get("sort"),
+		Order:       query.Get("order"),
+		Status:      query.Get("status"),
+		EmailPrefix: query.Get("email_prefix"),
+		NamePrefix:  query.Get("name_prefix"),
+	}
+
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		return params, err
+	}
+	params.IncludeDeleted = includeDeleted
+
+	ints := map[string]*int32{
+		"limit": &params.Limit,
+		"page":  &params.Page,
+	}
+	for name, target := range ints {
+		n, err := queryInt32(r, name)
+		if err != nil {
+			return params, err
+		}
+		if n != nil {
+			*target = *n
+		}
+	}
+
+	// age bounds stay nil when omitted, so that min_age=0 and max_age=0 filter
+	params.MinAge, err = queryInt32(r, "min_age")
+	if err != nil {
+		return params, err
+	}
+	params.MaxAge, err = queryInt32(r, "max_age")
+	if err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
+
+// queryInt32 returns the integer query parameter name, nil when it is omitted.
+func queryInt32(r *http.Request, name string) (*int32, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	i := int32(n)
+	return &i, nil
+}
+
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean", name)
+	}
+	return b, nil
+}
+
+// @Summary Search users
+// @Description Full-text and typo tolerant search over first name, last name and email, ranked by relevance
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search text"
+// @Param limit query int false "Maximum number of results. Default 20, max 100"
+// @Success 200 {array} database.SearchUsersRow
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /users/search [get]
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	params := dto.UserSearchParams{Query: query.Get("q")}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, "limit must be an integer")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	results, err := services.SearchUsers(ctx, params, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on searching users", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(results)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Searching Users")
 		return
 	}
 }
@@ -63,84 +246,392 @@ func (s *Server) getUsers(w http.ResponseWriter, r *http.Request) {
 // @Description  Create a New User
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param UserInput body dto.User true "User Details for Creation"
-// @Success 200 {object} dto.User
+// @Success 201 {object} dto.User
+// @Header 201 {string} ETag "Version of the user"
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
 // @Router /users [post]
 func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var user dto.User
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
 		return
 	}
 
-	dbUser, userError, httpstatus := services.CreateUser(ctx, user, s.Queries)
-	if httpstatus != http.StatusCreated {
-		fmt.Println(userError)
-		http.Error(w, userError, httpstatus)
+	dbUser, err := services.CreateUser(ctx, user, s.Queries)
+	if err != nil {
+		slog.DebugContext(ctx, "error on creating user", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(httpstatus)
-	fmt.Println("User Creating with name: " + user.Firstname)
+	w.Header().Set("ETag", etag(dbUser))
+	w.WriteHeader(http.StatusCreated)
+	slog.InfoContext(ctx, "user created", "user_id", dbUser.Userid)
 	err = json.NewEncoder(w).Encode(dbUser)
 	if err != nil {
-		fmt.Println("error on  Creating User: ", err)
-		http.Error(w, "Error on Creating User", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Creating User")
 		return
 	}
 }
 
+// @Summary Import users
+// @Description Create users from a CSV file with a header row or from NDJSON, one user per line.
+// @Description Every row is validated and reported. Unless atomic, the valid rows are stored even if others are rejected
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Security BearerAuth
+// @Param dry_run query bool false "Validate the file without storing any user"
+// @Param atomic query bool false "Store no user unless every row is valid"
+// @Success 200 {object} dto.ImportReport
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 415 {object} dto.Problem
+// @Failure 422 {object} dto.ImportReport "Atomic import with rejected rows, nothing was stored"
+// @Router /users/import [post]
+func (s *Server) importUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMed, "Content-Type must be "+services.CSVType+" or "+services.NDJSONType)
+		return
+	}
+
+	var opts dto.ImportOptions
+	opts.DryRun, err = queryBool(r, "dry_run")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, err.Error())
+		return
+	}
+	opts.Atomic, err = queryBool(r, "atomic")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, err.Error())
+		return
+	}
+
+	report, err := services.ImportUsers(ctx, mediaType, r.Body, opts, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on importing users", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if opts.Atomic && report.Rejected > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	slog.InfoContext(ctx, "users imported", "accepted", report.Accepted, "rejected", report.Rejected)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+	}
+}
+
+// @Summary Export users
+// @Description Stream every user matching the filters of the users list as CSV, NDJSON or an XLSX workbook.
+// @Description The format is taken from the format parameter, else from the Accept header. Paging parameters are ignored
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "csv, ndjson or xlsx. Default csv"
+// @Param columns query string false "Comma separated columns to export: userId, firstName, lastName, email, phone, age, status, deletedAt, version"
+// @Param sort query string false "Sort field: userId, firstName, lastName, email, phone, age, status"
+// @Param order query string false "Sort order: asc or desc"
+// @Param status query string false "Filter by status: Active or Inactive"
+// @Param min_age query int false "Minimum age"
+// @Param max_age query int false "Maximum age"
+// @Param email_prefix query string false "Email starts with"
+// @Param name_prefix query string false "First or last name starts with"
+// @Param include_deleted query bool false "Also export soft deleted users"
+// @Success 200 {file} file
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 406 {object} dto.Problem
+// @Router /users/export [get]
+func (s *Server) exportUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	list, err := listParams(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, err.Error())
+		return
+	}
+	params := dto.UserExportParams{UserListParams: list, Format: r.URL.Query().Get("format")}
+	if columns := r.URL.Query().Get("columns"); columns != "" {
+		params.Columns = strings.Split(columns, ",")
+	}
+	if params.Format == "" {
+		format, ok := exportFormat(r.Header.Get("Accept"))
+		if !ok {
+			writeProblem(w, r, http.StatusNotAcceptable, codeNotAcceptable, "Exports are available as "+services.CSVType+", "+services.NDJSONType+" or "+services.XLSXType)
+			return
+		}
+		params.Format = format
+	}
+
+	err = services.ValidateExport(params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	slog.DebugContext(ctx, "exporting users", "format", params.Format)
+	w.Header().Set("Content-Type", services.ExportTypes[params.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.%s"`, time.Now().UTC().Format("2006-01-02"), params.Format))
+	err = services.ExportUsers(ctx, params, w, s.Queries)
+	if err != nil {
+		// the response has started, so the error can only end it early
+		slog.DebugContext(r.Context(), "error on exporting users", "error", err)
+	}
+}
+
+// exportFormat picks the export format from an Accept header. Without one, or
+// when any type is accepted, the export is CSV.
+func exportFormat(accept string) (string, bool) {
+	if accept == "" {
+		return services.ExportCSV, true
+	}
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(item)
+		if err != nil {
+			continue
+		}
+		if mediaType == "*/*" || mediaType == "text/*" {
+			return services.ExportCSV, true
+		}
+		for format, exportType := range services.ExportTypes {
+			if mediaType == exportType {
+				return format, true
+			}
+		}
+	}
+	return "", false
+}
+
+// @Summary Run a batch of user operations
+// @Description Create, patch and delete users in a single transaction. Each operation reports the status it would have had on its own.
+// @Description Unless atomic, failed operations are undone without affecting the others
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param BatchInput body dto.BatchRequest true "Operations to run in order"
+// @Success 200 {object} dto.BatchReport
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 422 {object} dto.BatchReport "Atomic batch with a failed operation, nothing was stored"
+// @Router /users/batch [post]
+func (s *Server) batchUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req dto.BatchRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	results, committed, err := services.RunBatch(ctx, req, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on running batch", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	report := dto.BatchReport{Atomic: req.Atomic, Committed: committed, Results: make([]dto.BatchResult, len(results))}
+	successStatus := map[string]int{"create": http.StatusCreated, "patch": http.StatusOK, "delete": http.StatusNoContent}
+	for i, result := range results {
+		op := req.Operations[i].Op
+		report.Results[i] = dto.BatchResult{Index: i, Op: op, Status: successStatus[op], User: result.User}
+		if result.Err != nil {
+			problem := errorProblem(r, result.Err)
+			report.Results[i].Status = problem.Status
+			report.Results[i].Error = &problem
+			report.Failed++
+			continue
+		}
+		report.Succeeded++
+	}
+
+	status := http.StatusOK
+	if !committed {
+		status = http.StatusUnprocessableEntity
+	}
+	slog.InfoContext(ctx, "batch run", "succeeded", report.Succeeded, "failed", report.Failed)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+	}
+}
+
 // @Summary Get single user
 // @Description Retrieve a user
 // @Produce json
+// @Security BearerAuth
+// @Param include_deleted query bool false "Return the user even if it is soft deleted"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} dto.User
+// @Header 200 {string} ETag "Version of the user"
+// @Success 304
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
 // @Router /users/id [get]
 func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
 	userId := chi.URLParam(r, "id")
 
 	ctx := r.Context()
-	w.Header().Set("Content-Type", "application/json")
 
 	id, err := strconv.Atoi(userId)
 	if err != nil {
-		fmt.Println("error on Returing User: ", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "error on returning user", "error", err)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, err.Error())
 		return
 	}
 
-	user, err := s.Queries.GetUser(ctx, int32(id))
+	user, err := services.GetUser(ctx, id, includeDeleted, s.Queries)
 	if err != nil {
-		fmt.Println("error on Returing User: ", err)
-		http.Error(w, "Error on Returing User with id: ", http.StatusNotFound)
+		slog.DebugContext(r.Context(), "error on returning user", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(user))
+	if notModified(r, etag(user)) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(user)
 	if err != nil {
-		fmt.Println("error on Returing User: ", err)
-		http.Error(w, "Error on Returing User", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Returing User")
 		return
 	}
 }
 
 // @Summary Update existing User
-// @Description Update existing User
+// @Description Partially update existing User with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902). Optional fields set to null are cleared
 // @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
 // @Produce json
-// @Param UserInput body dto.User true "User Details for Update"
-// @Success 200 {object} dto.User
+// @Security BearerAuth
+// @Param UserInput body dto.User true "Fields to update"
+// @Param If-Match header string true "ETag of the user the patch is based on"
+// @Success 200 {object} database.User
+// @Header 200 {string} ETag "Version of the user"
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 412 {object} dto.Problem
+// @Failure 415 {object} dto.Problem
+// @Failure 428 {object} dto.Problem
 // @Router /users/id [patch]
 func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) {
 	userId := chi.URLParam(r, "id")
-	fmt.Println("Updating User with id: " + userId)
+	id, strErr := strconv.Atoi(userId)
+	if strErr != nil {
+		slog.DebugContext(r.Context(), "error on updating user", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	patchType := services.MergePatchType
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMed, err.Error())
+			return
+		}
+		if mediaType != "application/json" {
+			patchType = mediaType
+		}
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
 
+	ctx := r.Context()
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	dbUser, err := services.PatchUser(ctx, id, version, patchType, patch, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on updating user", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(dbUser))
+	slog.InfoContext(ctx, "user updated", "user_id", id)
+	err = json.NewEncoder(w).Encode(dbUser)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Updating User")
+		return
+	}
+}
+
+// @Summary Replace existing User
+// @Description Replace every field of existing User. Optional fields left out are cleared
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param UserInput body dto.User true "User Details for Replacement"
+// @Param If-Match header string true "ETag of the user being replaced"
+// @Success 200 {object} database.User
+// @Header 200 {string} ETag "Version of the user"
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 412 {object} dto.Problem
+// @Failure 428 {object} dto.Problem
+// @Router /users/id [put]
+func (s *Server) replaceUser(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
 	id, strErr := strconv.Atoi(userId)
 	if strErr != nil {
-		fmt.Println("error on Updating User: ", strErr)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "error on replacing user", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
@@ -148,59 +639,286 @@ func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) {
 	var user dto.User
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
 		return
 	}
 
-	updateErr, httpstatus := services.UpdateUser(ctx, id, user, s.Queries)
-
-	if httpstatus != http.StatusOK {
-		fmt.Println("Error on updating user: " + updateErr)
-		http.Error(w, updateErr, httpstatus)
+	dbUser, err := services.UpdateUser(ctx, id, version, user, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on replacing user", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Println("User Updated with id: " + userId)
-	err = json.NewEncoder(w).Encode("User Updated with id: " + userId)
+	w.Header().Set("ETag", etag(dbUser))
+	slog.InfoContext(ctx, "user replaced", "user_id", id)
+	err = json.NewEncoder(w).Encode(dbUser)
 	if err != nil {
-		fmt.Println("error on Updating User: ", err)
-		http.Error(w, "Error on Updating User", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Replacing User")
 		return
 	}
 }
 
 // @Summary Delete existing User
-// @Description Delete existing User
+// @Description Soft delete existing User. It can be restored until it is purged
 // @Accept json
 // @Produce json
+// @Security BearerAuth
+// @Param If-Match header string true "ETag of the user being deleted"
 // @Success 200
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 412 {object} dto.Problem
+// @Failure 428 {object} dto.Problem
 // @Router /users/id [delete]
 func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
 	userId := chi.URLParam(r, "id")
 
 	ctx := r.Context()
-	w.Header().Set("Content-Type", "application/json")
 
 	id, strErr := strconv.Atoi(userId)
 	if strErr != nil {
-		fmt.Println("error on Updating User: ", strErr)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "error on deleting user", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
 		return
 	}
 
-	err := s.Queries.DeleteUser(ctx, int32(id))
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	err := services.DeleteUser(ctx, id, version, s.Queries)
 	if err != nil {
-		fmt.Println("error on Deleting User: ", strErr)
-		http.Error(w, "Error on Deleting User with id: "+userId, http.StatusNotFound)
+		slog.DebugContext(r.Context(), "error on deleting user", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
-	fmt.Println("Deleting User with id: " + userId)
+	w.Header().Set("Content-Type", "application/json")
+	slog.InfoContext(ctx, "user deleted", "user_id", id)
 	err = json.NewEncoder(w).Encode("Deleting User with id: " + userId)
 	if err != nil {
-		fmt.Println("error on Deleting User: ", err)
-		http.Error(w, "Error on Deleting User", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Deleting User")
+		return
+	}
+}
+
+// @Summary Restore deleted User
+// @Description Restore a soft deleted User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} database.User
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Router /users/id/restore [post]
+func (s *Server) restoreUser(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	ctx := r.Context()
+
+	id, strErr := strconv.Atoi(userId)
+	if strErr != nil {
+		slog.DebugContext(r.Context(), "error on restoring user", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	dbUser, err := services.RestoreUser(ctx, id, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on restoring user", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(dbUser))
+	slog.InfoContext(ctx, "user restored", "user_id", id)
+	err = json.NewEncoder(w).Encode(dbUser)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Restoring User")
+		return
+	}
+}
+
+// @Summary Get User roles
+// @Description Retrieve the roles granted to a User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.UserRoles
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /users/id/roles [get]
+func (s *Server) getUserRoles(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	ctx := r.Context()
+
+	id, strErr := strconv.Atoi(userId)
+	if strErr != nil {
+		slog.DebugContext(r.Context(), "error on returning user roles", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	roles, err := services.ListUserRoles(ctx, id, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on returning user roles", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(roles)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Returing User Roles")
+		return
+	}
+}
+
+// @Summary Set User roles
+// @Description Replace the roles granted to a User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param RolesInput body dto.UserRoles true "Roles of the User"
+// @Success 200 {object} dto.UserRoles
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /users/id/roles [put]
+func (s *Server) setUserRoles(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	ctx := r.Context()
+
+	id, strErr := strconv.Atoi(userId)
+	if strErr != nil {
+		slog.DebugContext(r.Context(), "error on setting user roles", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	var roles dto.UserRoles
+	err := json.NewDecoder(r.Body).Decode(&roles)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	updated, err := services.SetUserRoles(ctx, id, roles, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on setting user roles", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	slog.InfoContext(ctx, "user roles updated", "user_id", id)
+	err = json.NewEncoder(w).Encode(updated)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Setting User Roles")
+		return
+	}
+}
+
+// @Summary Set User password
+// @Description Set the password used to log in. Users changing their own existing password must supply the current one
+// @Accept json
+// @Security BearerAuth
+// @Param PasswordInput body dto.PasswordChange true "New password"
+// @Success 204
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /users/id/password [put]
+func (s *Server) setPassword(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	ctx := r.Context()
+
+	id, strErr := strconv.Atoi(userId)
+	if strErr != nil {
+		slog.DebugContext(r.Context(), "error on setting user password", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	var change dto.PasswordChange
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	err = services.SetPassword(ctx, id, change, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on setting user password", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(ctx, "user password set", "user_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get User audit log
+// @Description Retrieve a page of the create, update, delete and restore events of a User, newest first
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size. Default 20, max 100"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Success 200 {object} dto.AuditPage
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /users/id/audit [get]
+func (s *Server) getUserAudit(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	ctx := r.Context()
+
+	id, strErr := strconv.Atoi(userId)
+	if strErr != nil {
+		slog.DebugContext(r.Context(), "error on getting user audit", "error", strErr)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "User id must be an integer")
+		return
+	}
+
+	params := dto.AuditListParams{Cursor: r.URL.Query().Get("cursor")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, "limit must be an integer")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	page, err := services.ListAuditEvents(ctx, id, params, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on getting user audit", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(page)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Returing User Audit")
 		return
 	}
 }
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"user-manager/auth"
+	services "user-manager/internal"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+)
+
+// authenticate rejects requests without a valid bearer token, including
+// tokens revoked by a logout and tokens of deleted users, and stores the token
+// claims and the caller in the request context.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := auth.Authenticate(r.Context(), r.Header.Get("Authorization"), s.Verifier, services.Sessions(s.Queries))
+		switch {
+		case err == nil:
+			next.ServeHTTP(w, r.WithContext(ctx))
+		case errors.Is(err, auth.ErrMissingToken):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="user-manager"`)
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "Missing bearer token")
+		case errors.Is(err, auth.ErrRevokedToken):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="user-manager", error="invalid_token"`)
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "Bearer token has been revoked")
+		case errors.Is(err, auth.ErrUnauthorized):
+			slog.DebugContext(r.Context(), "error on verifying token", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="user-manager", error="invalid_token"`)
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "Invalid or expired bearer token")
+		case errors.Is(err, services.ErrUnauthenticated):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="user-manager", error="invalid_token"`)
+			writeError(w, r, err)
+		default:
+			slog.ErrorContext(r.Context(), "error on authenticating caller", "error", err)
+			writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Internal Server Error")
+		}
+	})
+}
+
+// requireRole allows the request when the caller holds one of roles. auth.RoleSelf
+// matches when the {id} URL parameter is the caller's own user id.
+func requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if ok && allowed(principal, roles, chi.URLParam(r, "id")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeProblem(w, r, http.StatusForbidden, codeForbidden, "Not allowed to perform this operation")
+		})
+	}
+}
+
+func allowed(principal *auth.Principal, roles []string, userId string) bool {
+	for _, role := range roles {
+		if role == auth.RoleSelf {
+			id, err := strconv.ParseInt(userId, 10, 32)
+			if err == nil && principal.IsSelf(int32(id)) {
+				return true
+			}
+			continue
+		}
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
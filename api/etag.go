@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"user-manager/database"
+	services "user-manager/internal"
+)
+
+const (
+	codePreconditionRequired = "precondition_required"
+	codeVersionMismatch      = "version_mismatch"
+)
+
+// etag is the entity tag of a user: its version, which every write increments.
+func etag(u *database.User) string {
+	return `"` + strconv.Itoa(int(u.Version)) + `"`
+}
+
+// ifMatchVersion returns the version required by the If-Match header,
+// services.AnyVersion for "*". Writes without If-Match are refused with 428 so
+// that clients cannot overwrite changes they have not seen.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		writeProblem(w, r, http.StatusPreconditionRequired, codePreconditionRequired, "If-Match header with the user ETag is required")
+		return 0, false
+	}
+	if header == "*" {
+		return services.AnyVersion, true
+	}
+
+	// weak tags never match If-Match, see RFC 9110 section 13.1.1
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 32)
+	if err != nil || strings.HasPrefix(header, "W/") || version <= 0 {
+		writeProblem(w, r, http.StatusPreconditionFailed, codeVersionMismatch, "If-Match does not match the user ETag")
+		return 0, false
+	}
+	return int32(version), true
+}
+
+// notModified reports whether the If-None-Match header matches tag.
+func notModified(r *http.Request, tag string) bool {
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	services "user-manager/internal"
+)
+
+const (
+	// eventBatchSize is the number of events read from the database at a time.
+	eventBatchSize = 100
+	// eventHeartbeat is how often an idle stream sends a comment, keeping
+	// proxies from closing it. Events missed by the listener are picked up then too.
+	eventHeartbeat = 15 * time.Second
+)
+
+// @Summary Stream user changes
+// @Description Server-Sent Events stream of user changes. Each event has the event id, the type (user.created, user.updated,
+// @Description user.deleted, user.restored or user.purged) and a dto.UserEvent as data. Reconnect with the Last-Event-ID header to resume
+// @Description after the last event received. Without it the stream starts with the next change
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param Last-Event-ID header string false "Id of the last event received"
+// @Param last_event_id query int false "Same as Last-Event-ID, for clients that cannot set headers"
+// @Success 200 {object} dto.UserEvent
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /users/events [get]
+func (s *Server) streamUserEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var afterID int64
+	var err error
+	if lastID != "" {
+		afterID, err = strconv.ParseInt(lastID, 10, 64)
+		if err != nil || afterID < 0 {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, "Last-Event-ID must be an event id")
+			return
+		}
+	} else {
+		afterID, err = services.LatestUserEventID(ctx, s.Queries)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	// subscribe before the first read so that no change is missed in between
+	wake, unsubscribe := s.Events.Subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		for {
+			events, err := services.ListUserEvents(ctx, afterID, eventBatchSize, s.Queries)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.WarnContext(ctx, "error on streaming user events", "error", err)
+				}
+				return
+			}
+			for _, event := range events {
+				data, err := json.Marshal(event)
+				if err != nil {
+					slog.WarnContext(ctx, "error on streaming user events", "error", err)
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+				afterID = event.ID
+			}
+			if len(events) < eventBatchSize {
+				break
+			}
+		}
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.streams.Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+	}
+}
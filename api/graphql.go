@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	services "user-manager/internal"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Default limits of GraphQL operations, see Server.GraphQLMaxDepth and Server.GraphQLMaxComplexity.
+const (
+	DefaultGraphQLMaxDepth      = 10
+	DefaultGraphQLMaxComplexity = 2000
+)
+
+// graphqlRequest is a GraphQL over HTTP request.
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// GraphQLRouter serves the GraphQL endpoint. Roles are checked per field,
+// with the same rules as the REST routes.
+func (s *Server) GraphQLRouter(r chi.Router) {
+	r.Use(s.authenticate)
+
+	r.Get("/", s.graphql)
+	r.Post("/", s.graphql)
+}
+
+// @Summary GraphQL endpoint
+// @Description Run a GraphQL query or mutation on users. GET only runs queries.
+// @Description Operations nested deeper than GRAPHQL_MAX_DEPTH or costing more than GRAPHQL_MAX_COMPLEXITY are rejected.
+// @Description Every field costs 1, and the fields of users cost once per user of the requested page
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object
+// @Failure 400 {object} object
+// @Failure 401 {object} dto.Problem
+// @Router /graphql [post]
+func (s *Server) graphql(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &req.Variables)
+			if err != nil {
+				writeGraphQLErrors(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeGraphQLErrors(w, http.StatusBadRequest, "malformed request body: "+err.Error())
+			return
+		}
+	}
+	if req.Query == "" {
+		writeGraphQLErrors(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		writeGraphQLResult(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	validation := graphql.ValidateDocument(&graphqlSchema, doc, nil)
+	if !validation.IsValid {
+		writeGraphQLResult(w, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	op := selectOperation(doc, req.OperationName)
+	if op == nil {
+		writeGraphQLErrors(w, http.StatusBadRequest, "operationName does not match an operation of the document")
+		return
+	}
+	if r.Method == http.MethodGet && op.Operation != ast.OperationTypeQuery {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQLErrors(w, http.StatusMethodNotAllowed, "mutations must be sent with POST")
+		return
+	}
+
+	limits := queryLimits{fragments: fragments(doc), variables: req.Variables, defaults: variableDefaults(op)}
+	depth := limits.depth(op.SelectionSet, map[string]bool{})
+	if depth > s.GraphQLMaxDepth {
+		writeGraphQLErrors(w, http.StatusBadRequest, fmt.Sprintf("query depth %d exceeds the maximum of %d", depth, s.GraphQLMaxDepth))
+		return
+	}
+	complexity := limits.complexity(op.SelectionSet, true, map[string]bool{})
+	if complexity > s.GraphQLMaxComplexity {
+		writeGraphQLErrors(w, http.StatusBadRequest, fmt.Sprintf("query complexity %d exceeds the maximum of %d", complexity, s.GraphQLMaxComplexity))
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphqlSchema,
+		Root:          &graphqlRoot{queries: s.Queries},
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       r.Context(),
+	})
+	writeGraphQLResult(w, http.StatusOK, result)
+}
+
+// selectOperation returns the operation of doc to run: the one named name,
+// or the only operation when name is empty.
+func selectOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var selected *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if selected != nil {
+				return nil
+			}
+			selected = op
+		} else if op.Name != nil && op.Name.Value == name {
+			return op
+		}
+	}
+	return selected
+}
+
+func fragments(doc *ast.Document) map[string]*ast.FragmentDefinition {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	return fragments
+}
+
+// queryLimits measures the depth and complexity of an operation. Introspection
+// fields are not counted: their cost is bounded by the size of the schema.
+// Fragment cycles are rejected by validation, visited guards against them anyway.
+type queryLimits struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	// defaults are the default values of the operation variables, used when
+	// the variable is not supplied, as the query runs with them.
+	defaults map[string]ast.Value
+}
+
+func (l queryLimits) depth(set *ast.SelectionSet, visited map[string]bool) int {
+	if set == nil {
+		return 0
+	}
+	deepest := 0
+	for _, selection := range set.Selections {
+		var depth int
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			depth = 1 + l.depth(sel.SelectionSet, visited)
+		case *ast.InlineFragment:
+			depth = l.depth(sel.SelectionSet, visited)
+		case *ast.FragmentSpread:
+			depth = l.spread(sel, visited, l.depth)
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
+
+// complexity counts 1 per field. The selection of the users query is counted
+// once per user of the requested page. root is true for the fields of the operation.
+func (l queryLimits) complexity(set *ast.SelectionSet, root bool, visited map[string]bool) int {
+	if set == nil {
+		return 0
+	}
+	total := 0
+	for _, selection := range set.Selections {
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			multiplier := 1
+			if root && sel.Name.Value == "users" {
+				multiplier = l.pageSize(sel)
+			}
+			total += 1 + multiplier*l.complexity(sel.SelectionSet, false, visited)
+		case *ast.InlineFragment:
+			total += l.complexity(sel.SelectionSet, root, visited)
+		case *ast.FragmentSpread:
+			total += l.spread(sel, visited, func(set *ast.SelectionSet, visited map[string]bool) int {
+				return l.complexity(set, root, visited)
+			})
+		}
+	}
+	return total
+}
+
+func (l queryLimits) spread(sel *ast.FragmentSpread, visited map[string]bool, measure func(*ast.SelectionSet, map[string]bool) int) int {
+	name := sel.Name.Value
+	fragment, ok := l.fragments[name]
+	if !ok || visited[name] {
+		return 0
+	}
+	visited[name] = true
+	defer delete(visited, name)
+	return measure(fragment.SelectionSet, visited)
+}
+
+// pageSize returns the limit argument of a users field, the default page size when it is not set.
+func (l queryLimits) pageSize(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			n, err := strconv.Atoi(value.Value)
+			if err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			name := value.Name.Value
+			if supplied, ok := l.variables[name]; ok {
+				if n, ok := supplied.(float64); ok && n > 0 {
+					return int(n)
+				}
+				break
+			}
+			if fallback, ok := l.defaults[name].(*ast.IntValue); ok {
+				n, err := strconv.Atoi(fallback.Value)
+				if err == nil && n > 0 {
+					return n
+				}
+			}
+		}
+	}
+	return services.DefaultPageSize
+}
+
+func variableDefaults(op *ast.OperationDefinition) map[string]ast.Value {
+	defaults := map[string]ast.Value{}
+	for _, def := range op.VariableDefinitions {
+		if def.DefaultValue != nil {
+			defaults[def.Variable.Name.Value] = def.DefaultValue
+		}
+	}
+	return defaults
+}
+
+func writeGraphQLErrors(w http.ResponseWriter, status int, message string) {
+	writeGraphQLResult(w, status, &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(message)}})
+}
+
+func writeGraphQLResult(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(result)
+	if err != nil {
+		slog.Warn("error on writing GraphQL response", "error", err)
+	}
+}
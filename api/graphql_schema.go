@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+	services "user-manager/internal"
+
+	"github.com/graphql-go/graphql"
+)
+
+// graphqlRoot is the root value of GraphQL operations.
+type graphqlRoot struct {
+	queries database.Querier
+}
+
+var userStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserStatus",
+	Values: graphql.EnumValueConfigMap{
+		"Active":   {Value: string(database.UserstatusActive)},
+		"Inactive": {Value: string(database.UserstatusInactive)},
+	},
+})
+
+var userSortEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserSort",
+	Values: graphql.EnumValueConfigMap{
+		"userId":    {Value: "userId"},
+		"firstName": {Value: "firstName"},
+		"lastName":  {Value: "lastName"},
+		"email":     {Value: "email"},
+		"phone":     {Value: "phone"},
+		"age":       {Value: "age"},
+		"status":    {Value: "status"},
+	},
+})
+
+var sortOrderEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortOrder",
+	Values: graphql.EnumValueConfigMap{
+		"asc":  {Value: "asc"},
+		"desc": {Value: "desc"},
+	},
+})
+
+// clearableFieldEnum lists the optional fields updateUser can clear. Input
+// objects cannot carry explicit nulls, so they are passed in clear instead.
+var clearableFieldEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ClearableUserField",
+	Values: graphql.EnumValueConfigMap{
+		"phone": {Value: "phone"},
+		"age":   {Value: "age"},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"userId":    {Type: graphql.NewNonNull(graphql.Int), Resolve: userField(func(u *database.User) any { return u.Userid })},
+		"firstName": {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *database.User) any { return u.Firstname })},
+		"lastName":  {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *database.User) any { return u.Lastname })},
+		"email":     {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *database.User) any { return u.Email })},
+		"phone": {Type: graphql.String, Resolve: userField(func(u *database.User) any {
+			if !u.Phone.Valid {
+				return nil
+			}
+			return u.Phone.String
+		})},
+		"age": {Type: graphql.Int, Resolve: userField(func(u *database.User) any {
+			if !u.Age.Valid {
+				return nil
+			}
+			return u.Age.Int32
+		})},
+		"status": {Type: userStatusEnum, Resolve: userField(func(u *database.User) any {
+			if !u.UserStatus.Valid {
+				return nil
+			}
+			return string(u.UserStatus.Userstatus)
+		})},
+		"deletedAt": {Type: graphql.DateTime, Resolve: userField(func(u *database.User) any {
+			if !u.DeletedAt.Valid {
+				return nil
+			}
+			return u.DeletedAt.Time
+		})},
+		"version": {Type: graphql.NewNonNull(graphql.Int), Resolve: userField(func(u *database.User) any { return u.Version })},
+	},
+})
+
+var userPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserPage",
+	Fields: graphql.Fields{
+		"users": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType))), Resolve: func(p graphql.ResolveParams) (any, error) {
+			users := p.Source.(*dto.UserPage).Users
+			list := make([]*database.User, len(users))
+			for i := range users {
+				list[i] = &users[i]
+			}
+			return list, nil
+		}},
+		"nextCursor": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+			if cursor := p.Source.(*dto.UserPage).NextCursor; cursor != "" {
+				return cursor, nil
+			}
+			return nil, nil
+		}},
+		"total": {Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(*dto.UserPage).Total, nil
+		}},
+	},
+})
+
+var createUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CreateUserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName": {Type: graphql.NewNonNull(graphql.String)},
+		"lastName":  {Type: graphql.NewNonNull(graphql.String)},
+		"email":     {Type: graphql.NewNonNull(graphql.String)},
+		"phone":     {Type: graphql.String},
+		"age":       {Type: graphql.Int},
+		"status":    {Type: userStatusEnum},
+	},
+})
+
+var updateUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UpdateUserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName": {Type: graphql.String},
+		"lastName":  {Type: graphql.String},
+		"email":     {Type: graphql.String},
+		"phone":     {Type: graphql.String},
+		"age":       {Type: graphql.Int},
+		"status":    {Type: userStatusEnum},
+	},
+})
+
+var graphqlSchema = mustGraphQLSchema()
+
+func mustGraphQLSchema() graphql.Schema {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": {
+				Type:        userType,
+				Description: "A user by id. Admins, support and the user itself",
+				Args: graphql.FieldConfigArgument{
+					"id":             {Type: graphql.NewNonNull(graphql.Int)},
+					"includeDeleted": {Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: resolveUser,
+			},
+			"users": {
+				Type:        graphql.NewNonNull(userPageType),
+				Description: "A page of users, with the pagination, sort and filters of GET /users. Admins and support",
+				Args: graphql.FieldConfigArgument{
+					"limit":          {Type: graphql.Int},
+					"cursor":         {Type: graphql.String},
+					"page":           {Type: graphql.Int},
+					"sort":           {Type: userSortEnum},
+					"order":          {Type: sortOrderEnum},
+					"status":         {Type: userStatusEnum},
+					"minAge":         {Type: graphql.Int},
+					"maxAge":         {Type: graphql.Int},
+					"emailPrefix":    {Type: graphql.String},
+					"namePrefix":     {Type: graphql.String},
+					"includeDeleted": {Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: resolveUsers,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": {
+				Type:        graphql.NewNonNull(userType),
+				Description: "Create a user. Admins only",
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(createUserInput)},
+				},
+				Resolve: resolveCreateUser,
+			},
+			"updateUser": {
+				Type:        graphql.NewNonNull(userType),
+				Description: "Update the given fields of a user at version, and clear the fields listed in clear. Admins, support and the user itself",
+				Args: graphql.FieldConfigArgument{
+					"id":      {Type: graphql.NewNonNull(graphql.Int)},
+					"version": {Type: graphql.NewNonNull(graphql.Int)},
+					"input":   {Type: graphql.NewNonNull(updateUserInput)},
+					"clear":   {Type: graphql.NewList(graphql.NewNonNull(clearableFieldEnum))},
+				},
+				Resolve: resolveUpdateUser,
+			},
+			"deleteUser": {
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Soft delete a user at version. Admins only",
+				Args: graphql.FieldConfigArgument{
+					"id":      {Type: graphql.NewNonNull(graphql.Int)},
+					"version": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: resolveDeleteUser,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		panic("invalid GraphQL schema: " + err.Error())
+	}
+	return schema
+}
+
+func resolveUser(p graphql.ResolveParams) (any, error) {
+	id := p.Args["id"].(int)
+	err := authorizeGraphQL(p, strconv.Itoa(id), auth.RoleAdmin, auth.RoleSupport, auth.RoleSelf)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := services.GetUser(p.Context, id, p.Args["includeDeleted"].(bool), rootQueries(p))
+	if err != nil {
+		return nil, graphqlServiceError(p.Context, err)
+	}
+	return user, nil
+}
+
+func resolveUsers(p graphql.ResolveParams) (any, error) {
+	err := authorizeGraphQL(p, "", auth.RoleAdmin, auth.RoleSupport)
+	if err != nil {
+		return nil, err
+	}
+
+	params := dto.UserListParams{
+		Limit:          int32Arg(p.Args, "limit"),
+		Cursor:         stringArg(p.Args, "cursor"),
+		Page:           int32Arg(p.Args, "page"),
+		Sort:           stringArg(p.Args, "sort"),
+		Order:          stringArg(p.Args, "order"),
+		Status:         stringArg(p.Args, "status"),
+		MinAge:         optionalInt32Arg(p.Args, "minAge"),
+		MaxAge:         optionalInt32Arg(p.Args, "maxAge"),
+		EmailPrefix:    stringArg(p.Args, "emailPrefix"),
+		NamePrefix:     stringArg(p.Args, "namePrefix"),
+		IncludeDeleted: p.Args["includeDeleted"].(bool),
+	}
+	page, err := services.ListUsers(p.Context, params, rootQueries(p))
+	if err != nil {
+		return nil, graphqlServiceError(p.Context, err)
+	}
+	return page, nil
+}
+
+func resolveCreateUser(p graphql.ResolveParams) (any, error) {
+	err := authorizeGraphQL(p, "", auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	input := p.Args["input"].(map[string]any)
+	user := dto.User{
+		Firstname: stringArg(input, "firstName"),
+		Lastname:  stringArg(input, "lastName"),
+		Email:     stringArg(input, "email"),
+		Status:    stringArg(input, "status"),
+	}
+	if phone, ok := input["phone"].(string); ok {
+		user.Phone = &phone
+	}
+	if _, ok := input["age"]; ok {
+		age := int32Arg(input, "age")
+		user.Age = &age
+	}
+
+	created, err := services.CreateUser(p.Context, user, rootQueries(p))
+	if err != nil {
+		return nil, graphqlServiceError(p.Context, err)
+	}
+	return created, nil
+}
+
+// resolveUpdateUser applies the input as a JSON Merge Patch, like PATCH /users/{id}.
+func resolveUpdateUser(p graphql.ResolveParams) (any, error) {
+	id := p.Args["id"].(int)
+	err := authorizeGraphQL(p, strconv.Itoa(id), auth.RoleAdmin, auth.RoleSupport, auth.RoleSelf)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := p.Args["input"].(map[string]any)
+	cleared, _ := p.Args["clear"].([]any)
+	for _, field := range cleared {
+		if _, ok := patch[field.(string)]; ok {
+			return nil, &graphqlError{message: field.(string) + " cannot be both set and cleared", extensions: map[string]any{
+				"code":   codeInvalidParam,
+				"status": http.StatusBadRequest,
+			}}
+		}
+		patch[field.(string)] = nil
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := graphqlVersion(p)
+	if err != nil {
+		return nil, err
+	}
+	user, err := services.PatchUser(p.Context, id, version, services.MergePatchType, body, rootQueries(p))
+	if err != nil {
+		return nil, graphqlServiceError(p.Context, err)
+	}
+	return user, nil
+}
+
+func resolveDeleteUser(p graphql.ResolveParams) (any, error) {
+	err := authorizeGraphQL(p, "", auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := graphqlVersion(p)
+	if err != nil {
+		return nil, err
+	}
+	err = services.DeleteUser(p.Context, p.Args["id"].(int), version, rootQueries(p))
+	if err != nil {
+		return nil, graphqlServiceError(p.Context, err)
+	}
+	return true, nil
+}
+
+func userField(value func(u *database.User) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return value(p.Source.(*database.User)), nil
+	}
+}
+
+func rootQueries(p graphql.ResolveParams) database.Querier {
+	return p.Info.RootValue.(*graphqlRoot).queries
+}
+
+func stringArg(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func int32Arg(args map[string]any, name string) int32 {
+	n, _ := args[name].(int)
+	return int32(n)
+}
+
+// optionalInt32Arg returns nil when the argument name is omitted.
+func optionalInt32Arg(args map[string]any, name string) *int32 {
+	n, ok := args[name].(int)
+	if !ok {
+		return nil
+	}
+	i := int32(n)
+	return &i
+}
+
+// authorizeGraphQL checks the roles of a field like requireRole does for a route.
+func authorizeGraphQL(p graphql.ResolveParams, userId string, roles ...string) error {
+	principal, ok := auth.PrincipalFromContext(p.Context)
+	if ok && allowed(principal, roles, userId) {
+		return nil
+	}
+	return &graphqlError{message: "Not allowed to perform this operation", extensions: map[string]any{
+		"code":   codeForbidden,
+		"status": http.StatusForbidden,
+	}}
+}
+
+// graphqlError is a field error. Its extensions hold the error code and HTTP
+// status the REST API would return, and the invalid fields if any.
+type graphqlError struct {
+	message    string
+	extensions map[string]any
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]any {
+	return e.extensions
+}
+
+// graphqlServiceError maps an error returned by the services package to a field error.
+// graphqlVersion returns the version argument, which must be a version of the
+// user: there is no equivalent of If-Match: * in GraphQL.
+func graphqlVersion(p graphql.ResolveParams) (int32, error) {
+	version := p.Args["version"].(int)
+	if version <= 0 {
+		return 0, &graphqlError{message: "version is required", extensions: map[string]any{
+			"code":   codePreconditionRequired,
+			"status": http.StatusPreconditionRequired,
+		}}
+	}
+	return int32(version), nil
+}
+
+func graphqlServiceError(ctx context.Context, err error) error {
+	status, serviceErr := errorStatus(err)
+	if serviceErr == nil {
+		slog.ErrorContext(ctx, "unexpected error", "error", err)
+		return &graphqlError{message: "Internal Server Error", extensions: map[string]any{
+			"code":   codeInternalError,
+			"status": status,
+		}}
+	}
+
+	extensions := map[string]any{
+		"code":   serviceErr.Code,
+		"status": status,
+	}
+	if len(serviceErr.Fields) > 0 {
+		extensions["errors"] = serviceErr.Fields
+	}
+	return &graphqlError{message: serviceErr.Detail, extensions: extensions}
+}
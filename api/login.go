@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"user-manager/auth"
+	"user-manager/dto"
+	services "user-manager/internal"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AuthRouter serves password logins and token refresh and revocation.
+func (s *Server) AuthRouter(r chi.Router) {
+	r.Post("/login", s.login)
+	r.Post("/refresh", s.refresh)
+	r.With(s.authenticate).Post("/logout", s.logout)
+}
+
+// @Summary Log in
+// @Description Exchange an email and password for an access token and a refresh token
+// @Accept json
+// @Produce json
+// @Param LoginInput body dto.LoginRequest true "Credentials"
+// @Success 200 {object} dto.TokenPair
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /auth/login [post]
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var login dto.LoginRequest
+	err := json.NewDecoder(r.Body).Decode(&login)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	tokens, err := services.Login(r.Context(), login, s.Issuer, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on login", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	writeTokens(w, r, tokens)
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new token pair. Each refresh token can be used once
+// @Accept json
+// @Produce json
+// @Param RefreshInput body dto.RefreshRequest true "Refresh token"
+// @Success 200 {object} dto.TokenPair
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /auth/refresh [post]
+func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
+	var refresh dto.RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&refresh)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	tokens, err := services.RefreshTokens(r.Context(), refresh, s.Issuer, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on refreshing tokens", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	writeTokens(w, r, tokens)
+}
+
+// @Summary Log out
+// @Description Revoke the bearer token and, when given, the refresh token
+// @Accept json
+// @Security BearerAuth
+// @Param LogoutInput body dto.LogoutRequest false "Refresh token"
+// @Success 204
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Router /auth/logout [post]
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var logout dto.LogoutRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&logout)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+			return
+		}
+	}
+
+	claims, _ := auth.FromContext(ctx)
+	err := services.Logout(ctx, claims, logout, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on logout", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTokens(w http.ResponseWriter, r *http.Request, tokens *dto.TokenPair) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(w).Encode(tokens)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "Error on Returning Tokens")
+	}
+}
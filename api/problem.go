@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"user-manager/dto"
+	services "user-manager/internal"
+)
+
+const problemContentType = "application/problem+json"
+
+// Codes of problems raised by the api package itself.
+const (
+	codeInvalidID      = "invalid_id"
+	codeMalformedBody  = "malformed_body"
+	codeInvalidParam   = "invalid_parameter"
+	codeInternalError  = "internal_error"
+	codeUnsupportedMed = "unsupported_media_type"
+	codeNotAcceptable  = "not_acceptable"
+)
+
+// writeProblem writes an RFC 7807 problem details response.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string, fields ...dto.FieldError) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(newProblem(r, status, code, detail, fields...))
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing problem response", "error", err)
+	}
+}
+
+func newProblem(r *http.Request, status int, code string, detail string, fields ...dto.FieldError) dto.Problem {
+	return dto.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+		Errors:   fields,
+	}
+}
+
+// writeError maps an error returned by the services package to a problem response.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := errorProblem(r, err)
+	writeProblem(w, r, problem.Status, problem.Code, problem.Detail, problem.Errors...)
+}
+
+// errorProblem maps an error returned by the services package to the problem reported for it.
+func errorProblem(r *http.Request, err error) dto.Problem {
+	status, serviceErr := errorStatus(err)
+	if serviceErr == nil {
+		slog.ErrorContext(r.Context(), "unexpected error", "error", err)
+		return newProblem(r, status, codeInternalError, "Internal Server Error")
+	}
+	return newProblem(r, status, serviceErr.Code, serviceErr.Detail, serviceErr.Fields...)
+}
+
+// errorStatus returns the HTTP status of an error returned by the services
+// package, and the error as a *services.Error. Other errors are internal errors.
+func errorStatus(err error) (int, *services.Error) {
+	var serviceErr *services.Error
+	if !errors.As(err, &serviceErr) {
+		return http.StatusInternalServerError, nil
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrUnsupported):
+		status = http.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrPrecondition):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, services.ErrPreconditionRequired):
+		status = http.StatusPreconditionRequired
+	case errors.Is(err, services.ErrUnauthenticated):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrAborted):
+		status = http.StatusFailedDependency
+	}
+	return status, serviceErr
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"user-manager/auth"
+	"user-manager/dto"
+	services "user-manager/internal"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WebhookRouter serves the webhook subscriptions and their delivery history. Admins only.
+func (s *Server) WebhookRouter(r chi.Router) {
+	r.Use(s.authenticate)
+	r.Use(requireRole(auth.RoleAdmin))
+
+	r.Get("/", s.getWebhooks)
+	r.Post("/", s.createWebhook)
+	r.Get("/{id}", s.getWebhook)
+	r.Put("/{id}", s.updateWebhook)
+	r.Delete("/{id}", s.deleteWebhook)
+	r.Get("/{id}/deliveries", s.getWebhookDeliveries)
+	r.Post("/{id}/deliveries/{deliveryId}/retry", s.retryWebhookDelivery)
+}
+
+// @Summary List webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.Webhook
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /webhooks [get]
+func (s *Server) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := services.ListWebhooks(r.Context(), s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on listing webhooks", "error", err)
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, webhooks)
+}
+
+// @Summary Create a webhook
+// @Description Subscribe a URL to user events. Each event is posted as a dto.WebhookEvent with a Webhook-Signature header:
+// @Description sha256= and the hex HMAC-SHA256 of the Webhook-Timestamp header, a dot and the body, keyed by the returned secret
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param WebhookInput body dto.WebhookInput true "Webhook"
+// @Success 201 {object} dto.Webhook
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /webhooks [post]
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var input dto.WebhookInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	webhook, err := services.CreateWebhook(r.Context(), input, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on creating webhook", "error", err)
+		writeError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "webhook created", "webhook_id", webhook.ID)
+	writeJSON(w, r, http.StatusCreated, webhook)
+}
+
+// @Summary Get a webhook
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.Webhook
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /webhooks/id [get]
+func (s *Server) getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, err := services.GetWebhook(r.Context(), id, s.Queries)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, webhook)
+}
+
+// @Summary Update a webhook
+// @Description Replace the URL, events and active flag of a webhook. The secret is kept
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param WebhookInput body dto.WebhookInput true "Webhook"
+// @Success 200 {object} dto.Webhook
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /webhooks/id [put]
+func (s *Server) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	var input dto.WebhookInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, err.Error())
+		return
+	}
+
+	webhook, err := services.UpdateWebhook(r.Context(), id, input, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on updating webhook", "error", err)
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, webhook)
+}
+
+// @Summary Delete a webhook
+// @Description Delete a webhook with its delivery history. Pending deliveries are dropped
+// @Security BearerAuth
+// @Success 204
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /webhooks/id [delete]
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	err := services.DeleteWebhook(r.Context(), id, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on deleting webhook", "error", err)
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get webhook deliveries
+// @Description Retrieve a page of the deliveries of a webhook, newest first, with their attempts and last result
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size. Default 20, max 100"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Success 200 {object} dto.WebhookDeliveryPage
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /webhooks/id/deliveries [get]
+func (s *Server) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	params := dto.DeliveryListParams{Cursor: r.URL.Query().Get("cursor")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParam, "limit must be an integer")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	page, err := services.ListWebhookDeliveries(r.Context(), id, params, s.Queries)
+	if err != nil {
+		slog.DebugContext(r.Context(), "error on getting webhook deliveries", "error", err)
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+// @Summary Retry a dead delivery
+// @Description Queue a dead lettered delivery again with a fresh set of attempts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.WebhookDelivery
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /webhooks/id/deliveries/deliveryId/retry [post]
+func (s *Server) retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "Delivery id must be an integer")
+		return
+	}
+
+	delivery, err := services.RetryWebhookDelivery(r.Context(), id, deliveryID, s.Queries)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, delivery)
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidID, "Webhook id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+	}
+}
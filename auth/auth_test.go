@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "user-manager-unit-test-secret-value"
+
+func TestVerifyHS256(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", time.Hour)
+
+	claims, err := verifier.Verify(token)
+	if err != nil || claims.Subject != "42" {
+		t.Fatalf("Test Failure! Valid token rejected: %v", err)
+	}
+	if id, ok := claims.UserID(); !ok || id != 42 {
+		t.Errorf("Test Failure! Subject of a local token not mapped to a user")
+	}
+}
+
+func TestVerifyForeignIssuer(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a service sharing the secret, issuing tokens under its own name
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "42",
+		Issuer:    "billing",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("Test Failure! Valid token rejected: %v", err)
+	}
+	if _, ok := claims.UserID(); ok {
+		t.Errorf("Test Failure! Subject of a token of another issuer mapped to a user")
+	}
+}
+
+func TestVerifyExpiredToken(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", -time.Minute)
+
+	_, err = verifier.Verify(token)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Test Failure! Expired token accepted")
+	}
+}
+
+func TestVerifyWrongSecret(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := sign(t, jwt.SigningMethodHS256, []byte("another-secret-another-secret-xx"), "", time.Hour)
+
+	_, err = verifier.Verify(token)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Test Failure! Token with a wrong signature accepted")
+	}
+}
+
+func TestVerifyRS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := writeJWKS(t, map[string]any{
+		"kty": "RSA",
+		"kid": "rsa-1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+
+	verifier, err := NewVerifier("", jwksFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := sign(t, jwt.SigningMethodRS256, key, "rsa-1", time.Hour)
+	claims, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("Test Failure! Valid RS256 token rejected: %v", err)
+	}
+	if _, ok := claims.UserID(); ok {
+		t.Errorf("Test Failure! Subject of an external token mapped to a user")
+	}
+
+	// without a shared secret HS256 tokens must be refused
+	token = sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", time.Hour)
+	_, err = verifier.Verify(token)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Test Failure! HS256 token accepted without a secret")
+	}
+}
+
+func TestVerifyES256WithJWKS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := writeJWKS(t, map[string]any{
+		"kty": "EC",
+		"kid": "ec-1",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		"y":   base64.RawURLEncoding.EncodeToString(point[33:]),
+	})
+
+	verifier, err := NewVerifier("", jwksFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := sign(t, jwt.SigningMethodES256, key, "", time.Hour)
+	_, err = verifier.Verify(token)
+	if err != nil {
+		t.Errorf("Test Failure! Valid ES256 token rejected: %v", err)
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, ttl time.Duration) string {
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]any) string {
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(path, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type testSessions struct {
+	revoked bool
+	err     error
+}
+
+func (s testSessions) IsTokenRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	return s.revoked, nil
+}
+
+func (s testSessions) LoadPrincipal(ctx context.Context, claims *Claims) (*Principal, error) {
+	id, _ := claims.UserID()
+	return &Principal{UserID: id, Roles: []string{RoleAdmin}}, s.err
+}
+
+func TestAuthenticate(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", time.Hour)
+
+	ctx, err := Authenticate(t.Context(), "bearer "+token, verifier, testSessions{})
+	if err != nil {
+		t.Fatalf("Test Failure! Valid token rejected: %v", err)
+	}
+	claims, _ := FromContext(ctx)
+	principal, ok := PrincipalFromContext(ctx)
+	if claims == nil || !ok || principal.UserID != 42 || !principal.HasRole(RoleAdmin) {
+		t.Errorf("Test Failure! Caller not stored in the context")
+	}
+
+	failures := map[string]struct {
+		authorization string
+		sessions      testSessions
+		err           error
+	}{
+		"missing":  {"", testSessions{}, ErrMissingToken},
+		"scheme":   {"Basic " + token, testSessions{}, ErrMissingToken},
+		"invalid":  {"Bearer " + token + "x", testSessions{}, ErrUnauthorized},
+		"revoked":  {"Bearer " + token, testSessions{revoked: true}, ErrRevokedToken},
+		"sessions": {"Bearer " + token, testSessions{err: os.ErrNotExist}, os.ErrNotExist},
+	}
+	for name, failure := range failures {
+		_, err := Authenticate(t.Context(), failure.authorization, verifier, failure.sessions)
+		if !errors.Is(err, failure.err) {
+			t.Errorf("Test Failure! Incorrect error for the %s token: %v", name, err)
+		}
+	}
+}
+
+func TestAuthenticateVerifiedClaims(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", time.Hour)
+	claims, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewVerifiedContext(t.Context(), claims)
+
+	// the claims of the same token are not verified again
+	authenticated, err := Authenticate(ctx, "Bearer "+token, nil, testSessions{})
+	if err != nil {
+		t.Fatalf("Test Failure! Verified claims not reused: %v", err)
+	}
+	if stored, _ := FromContext(authenticated); stored != claims {
+		t.Errorf("Test Failure! Verified claims not stored")
+	}
+
+	other := sign(t, jwt.SigningMethodHS256, []byte("another-secret-another-secret-xx"), "", time.Hour)
+	_, err = Authenticate(ctx, "Bearer "+other, verifier, testSessions{})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Test Failure! Claims of another token reused: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrMissingToken is returned when there is no bearer token.
+	ErrMissingToken = fmt.Errorf("%w: missing bearer token", ErrUnauthorized)
+	// ErrRevokedToken is returned for tokens revoked by a logout.
+	ErrRevokedToken = fmt.Errorf("%w: bearer token has been revoked", ErrUnauthorized)
+)
+
+// Sessions looks up what the token alone does not tell about the caller.
+type Sessions interface {
+	// IsTokenRevoked reports whether the access token was revoked by a logout.
+	IsTokenRevoked(ctx context.Context, claims *Claims) (bool, error)
+	// LoadPrincipal resolves the caller of verified claims.
+	LoadPrincipal(ctx context.Context, claims *Claims) (*Principal, error)
+}
+
+// Authenticate checks the bearer token of an Authorization header value: its
+// signature and expiry with verifier, unless ctx carries the claims verified
+// from it by NewVerifiedContext, then that it was not revoked. It returns a
+// copy of ctx carrying the claims and the caller.
+//
+// Rejected tokens are reported with errors wrapping ErrUnauthorized. Errors of
+// sessions are returned as they are.
+func Authenticate(ctx context.Context, authorization string, verifier *Verifier, sessions Sessions) (context.Context, error) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims, ok := verifiedClaims(ctx, token)
+	if !ok {
+		var err error
+		claims, err = verifier.Verify(token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	revoked, err := sessions.IsTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("error on checking token revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+	ctx = NewContext(ctx, claims)
+
+	principal, err := sessions.LoadPrincipal(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("error on loading caller roles: %w", err)
+	}
+	return NewPrincipalContext(ctx, principal), nil
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer signs HS256 access tokens and generates opaque refresh tokens.
+type Issuer struct {
+	secret     []byte
+	issuer     string
+	audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewIssuer(secret string, issuer string, audience string, accessTTL time.Duration, refreshTTL time.Duration) (*Issuer, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("a JWT secret is required to issue tokens")
+	}
+	return &Issuer{
+		secret:     []byte(secret),
+		issuer:     issuer,
+		audience:   audience,
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	}, nil
+}
+
+// AccessToken signs an access token for subject. The token id (jti) allows revoking it before it expires.
+func (i *Issuer) AccessToken(subject string) (token string, claims *Claims, err error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims = &Claims{RegisteredClaims: jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   subject,
+		Issuer:    i.issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(i.AccessTTL)),
+	}, local: true}
+	if i.audience != "" {
+		claims.Audience = jwt.ClaimStrings{i.audience}
+	}
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+	return token, claims, err
+}
+
+// RefreshToken returns a new opaque refresh token and the hash to store for it.
+func (i *Issuer) RefreshToken() (token string, hash []byte, err error) {
+	token, err = randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hash refresh tokens are stored and looked up by.
+func HashRefreshToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHashPasswordArgon2id(t *testing.T) {
+	hash, err := HashPassword("correct horse battery", Argon2id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, needsRehash := VerifyPassword(hash, "correct horse battery", Argon2id)
+	if !ok || needsRehash {
+		t.Errorf("Test Failure! Valid argon2id password rejected")
+	}
+	ok, _ = VerifyPassword(hash, "wrong horse battery", Argon2id)
+	if ok {
+		t.Errorf("Test Failure! Wrong password accepted")
+	}
+}
+
+func TestHashPasswordBcryptNeedsRehash(t *testing.T) {
+	hash, err := HashPassword("correct horse battery", Bcrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, needsRehash := VerifyPassword(hash, "correct horse battery", Argon2id)
+	if !ok || !needsRehash {
+		t.Errorf("Test Failure! bcrypt hash not verified or not flagged for rehash")
+	}
+}
+
+func TestIssuerAccessToken(t *testing.T) {
+	issuer, err := NewIssuer(testSecret, "user-manager", "users", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(testSecret, "", "user-manager", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := issuer.AccessToken("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifier.Verify(token)
+	if err != nil || claims.Subject != "42" || claims.ID == "" {
+		t.Errorf("Test Failure! Issued token rejected: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Roles that can be granted to a user. RoleSelf is never stored: every
+// caller holds it for its own user record.
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleSelf    = "self"
+)
+
+// Principal is the authenticated caller: the user id of the token subject
+// and the roles granted to that user.
+type Principal struct {
+	UserID int32
+	Roles  []string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// IsSelf reports whether userID is the caller's own user record.
+func (p *Principal) IsSelf(userID int32) bool {
+	return p.UserID != 0 && p.UserID == userID
+}
+
+type principalKey struct{}
+
+// NewPrincipalContext returns a copy of ctx carrying the caller.
+func NewPrincipalContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller of the request, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
@@ -2,51 +2,438 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	// Storage is the storage backend: postgres, sqlite or memory. The DB
+	// settings are only required by postgres.
+	Storage    string
+	SQLitePath string
+
 	DBHost     string
 	DBPort     int
 	DBUser     string
 	DBPassword string
 	DBName     string
 	APPPort    int
+	GRPCPort   int
+	// ShutdownDelay is how long the server keeps serving after the readiness
+	// probe starts failing at shutdown.
+	ShutdownDelay time.Duration
+
+	PurgeInterval  time.Duration
+	PurgeRetention time.Duration
+
+	WebhookInterval    time.Duration
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+	// WebhookRetention is how long finished webhook events and their deliveries are kept.
+	WebhookRetention time.Duration
+	// WebhookAllowPrivate lets webhooks target loopback, private and link-local addresses.
+	WebhookAllowPrivate bool
+
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+
+	// LogLevel is debug, info, warn or error, LogFormat json or text.
+	LogLevel  string
+	LogFormat string
+
+	// TracingExporter is otlp, stdout or none.
+	TracingExporter string
+
+	JWTSecret   string
+	JWKSFile    string
+	JWTIssuer   string
+	JWTAudience string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	PasswordHash    string
+	PasswordPolicy  PasswordPolicy
+
+	RateLimits RateLimits
+}
+
+// PasswordPolicy is the set of rules new passwords must satisfy.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// RateLimit allows a client Requests requests per Period, in bursts of up to
+// Requests. The zero RateLimit allows every request.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// RateLimits are the limits of the HTTP API.
+type RateLimits struct {
+	Default RateLimit
+	// Routes are the limits of the routes limited apart from Default, by
+	// method and chi route pattern such as "POST /users".
+	Routes map[string]RateLimit
+	// Store is memory, for limits per replica, or postgres, for limits shared
+	// by the replicas.
+	Store string
+	// TrustedProxies are the proxies whose X-Forwarded-For header is trusted.
+	TrustedProxies []netip.Prefix
 }
 
 func LoadConfig() (*Config, error) {
 	_ = godotenv.Load()
 
+	storage := os.Getenv("STORAGE")
+	if storage == "" {
+		storage = "postgres"
+	}
+	if storage != "postgres" && storage != "sqlite" && storage != "memory" {
+		return nil, fmt.Errorf("STORAGE must be postgres, sqlite or memory")
+	}
+	sqlitePath := os.Getenv("SQLITE_PATH")
+	if sqlitePath == "" {
+		sqlitePath = "user-manager.db"
+	}
+
 	dbHost := os.Getenv("DB_HOST")
 	dbUser := os.Getenv("DB_USER")
 	dbPwd := os.Getenv("DB_PASSWORD")
 	dbName := os.Getenv("DB_NAME")
 
-	dbPort, err := strconv.Atoi(os.Getenv("DB_PORT"))
-	if err != nil {
-		fmt.Println("error on parsing DB port")
-		return nil, err
+	var dbPort int
+	var err error
+	if storage == "postgres" {
+		dbPort, err = strconv.Atoi(os.Getenv("DB_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("error on parsing DB port: %w", err)
+		}
 	}
 	appPort, err := strconv.Atoi(os.Getenv("APP_PORT"))
 	if err != nil {
-		fmt.Println("error on parsing App port")
+		return nil, fmt.Errorf("error on parsing App port: %w", err)
+	}
+
+	grpcPort := 9090
+	if value := os.Getenv("GRPC_PORT"); value != "" {
+		grpcPort, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("error on parsing gRPC port: %w", err)
+		}
+	}
+
+	purgeInterval, err := durationEnv("PURGE_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing purge interval: %w", err)
+	}
+	purgeRetention, err := durationEnv("PURGE_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing purge retention: %w", err)
+	}
+	shutdownDelay, err := durationEnv("SHUTDOWN_DELAY", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing shutdown delay: %w", err)
+	}
+
+	webhookInterval, err := durationEnv("WEBHOOK_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing webhook interval: %w", err)
+	}
+	webhookTimeout, err := durationEnv("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing webhook timeout: %w", err)
+	}
+	webhookMaxAttempts := 8
+	if value := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); value != "" {
+		webhookMaxAttempts, err = strconv.Atoi(value)
+		if err != nil || webhookMaxAttempts < 1 {
+			return nil, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be a positive integer")
+		}
+	}
+	webhookRetention, err := durationEnv("WEBHOOK_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing webhook retention: %w", err)
+	}
+	webhookAllowPrivate, err := boolEnv("WEBHOOK_ALLOW_PRIVATE")
+	if err != nil {
 		return nil, err
 	}
 
-	if dbHost == "" || dbPort <= 0 || dbUser == "" || dbPwd == "" || dbName == "" || appPort <= 0 {
-		fmt.Println("error on configurations")
-		return nil, fmt.Errorf("error on configurations")
+	graphqlMaxDepth, err := positiveIntEnv("GRAPHQL_MAX_DEPTH", 10)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing GraphQL max depth: %w", err)
+	}
+	graphqlMaxComplexity, err := positiveIntEnv("GRAPHQL_MAX_COMPLEXITY", 2000)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing GraphQL max complexity: %w", err)
+	}
+
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "json"
+	}
+	if logFormat != "json" && logFormat != "text" {
+		return nil, fmt.Errorf("LOG_FORMAT must be json or text")
+	}
+
+	tracingExporter := os.Getenv("TRACING_EXPORTER")
+	if tracingExporter == "" {
+		tracingExporter = "none"
+	}
+	if tracingExporter != "otlp" && tracingExporter != "stdout" && tracingExporter != "none" {
+		return nil, fmt.Errorf("TRACING_EXPORTER must be otlp, stdout or none")
+	}
+
+	jwtSecret := os.Getenv("JWT_SECRET")
+	jwksFile := os.Getenv("JWT_JWKS_FILE")
+	if jwtSecret == "" && jwksFile == "" {
+		return nil, fmt.Errorf("JWT_SECRET or JWT_JWKS_FILE is required")
+	}
+	if jwtSecret != "" && len(jwtSecret) < 32 {
+		return nil, fmt.Errorf("JWT_SECRET must be at least 32 characters")
+	}
+
+	accessTTL, err := durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing access token TTL: %w", err)
+	}
+	refreshTTL, err := durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("error on parsing refresh token TTL: %w", err)
+	}
+
+	passwordHash := os.Getenv("PASSWORD_HASH")
+	if passwordHash == "" {
+		passwordHash = "argon2id"
+	}
+	if passwordHash != "argon2id" && passwordHash != "bcrypt" {
+		return nil, fmt.Errorf("PASSWORD_HASH must be argon2id or bcrypt")
+	}
+	policy, err := loadPasswordPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("error on password configurations: %w", err)
+	}
+	rateLimits, err := loadRateLimits()
+	if err != nil {
+		return nil, fmt.Errorf("error on rate limit configurations: %w", err)
+	}
+	if rateLimits.Store == "postgres" && storage != "postgres" {
+		return nil, fmt.Errorf("RATE_LIMIT_STORE postgres requires the postgres storage")
+	}
+
+	if storage == "postgres" && (dbHost == "" || dbPort <= 0 || dbUser == "" || dbPwd == "" || dbName == "") {
+		return nil, fmt.Errorf("DB_HOST, DB_PORT, DB_USER, DB_PASSWORD and DB_NAME are required by the postgres storage")
+	}
+	if appPort <= 0 || grpcPort <= 0 {
+		return nil, fmt.Errorf("APP_PORT and GRPC_PORT must be positive")
 	}
 
 	return &Config{
+		Storage:    storage,
+		SQLitePath: sqlitePath,
+
 		DBHost:     dbHost,
 		DBPort:     dbPort,
 		DBUser:     dbUser,
 		DBPassword: dbPwd,
 		DBName:     dbName,
 		APPPort:    appPort,
+		GRPCPort:   grpcPort,
+
+		ShutdownDelay: shutdownDelay,
+
+		PurgeInterval:  purgeInterval,
+		PurgeRetention: purgeRetention,
+
+		WebhookInterval:    webhookInterval,
+		WebhookTimeout:     webhookTimeout,
+		WebhookMaxAttempts: webhookMaxAttempts,
+
+		WebhookRetention:    webhookRetention,
+		WebhookAllowPrivate: webhookAllowPrivate,
+
+		GraphQLMaxDepth:      graphqlMaxDepth,
+		GraphQLMaxComplexity: graphqlMaxComplexity,
+
+		LogLevel:  logLevel,
+		LogFormat: logFormat,
+
+		TracingExporter: tracingExporter,
+
+		JWTSecret:   jwtSecret,
+		JWKSFile:    jwksFile,
+		JWTIssuer:   os.Getenv("JWT_ISSUER"),
+		JWTAudience: os.Getenv("JWT_AUDIENCE"),
+
+		AccessTokenTTL:  accessTTL,
+		RefreshTokenTTL: refreshTTL,
+		PasswordHash:    passwordHash,
+		PasswordPolicy:  policy,
+
+		RateLimits: rateLimits,
 	}, nil
 }
+
+// defaultRouteLimits are the stricter limits of the routes creating users
+// and checking passwords.
+const defaultRouteLimits = "POST /users=30/1m,POST /users/import=5/1m,POST /users/batch=5/1m,POST /auth/login=10/1m"
+
+func loadRateLimits() (RateLimits, error) {
+	limits := RateLimits{Routes: map[string]RateLimit{}, Store: os.Getenv("RATE_LIMIT_STORE")}
+
+	value := os.Getenv("RATE_LIMIT")
+	if value == "" {
+		value = "300/1m"
+	}
+	if value != "off" {
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			return limits, fmt.Errorf("RATE_LIMIT %w", err)
+		}
+		limits.Default = limit
+	}
+
+	routes, ok := os.LookupEnv("RATE_LIMIT_ROUTES")
+	if !ok {
+		routes = defaultRouteLimits
+	}
+	for _, entry := range strings.Split(routes, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		method, pattern, _ := strings.Cut(route, " ")
+		if !ok || method == "" || !strings.HasPrefix(pattern, "/") {
+			return limits, fmt.Errorf("RATE_LIMIT_ROUTES entries must look like POST /users=30/1m")
+		}
+		limit := RateLimit{}
+		if value != "off" {
+			var err error
+			limit, err = parseRateLimit(value)
+			if err != nil {
+				return limits, fmt.Errorf("RATE_LIMIT_ROUTES %s %w", route, err)
+			}
+		}
+		limits.Routes[strings.ToUpper(method)+" "+pattern] = limit
+	}
+
+	if limits.Store == "" {
+		limits.Store = "memory"
+	}
+	if limits.Store != "memory" && limits.Store != "postgres" {
+		return limits, fmt.Errorf("RATE_LIMIT_STORE must be memory or postgres")
+	}
+
+	for _, value := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return limits, fmt.Errorf("TRUSTED_PROXIES must be IP addresses or CIDR ranges: %w", err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		limits.TrustedProxies = append(limits.TrustedProxies, prefix.Masked())
+	}
+	return limits, nil
+}
+
+// parseRateLimit parses a limit such as "30/1m", 30 requests per minute.
+func parseRateLimit(value string) (RateLimit, error) {
+	requests, period, ok := strings.Cut(value, "/")
+	n, err := strconv.Atoi(requests)
+	if !ok || err != nil || n < 1 {
+		return RateLimit{}, fmt.Errorf("must look like 30/1m or be off")
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("must look like 30/1m or be off")
+	}
+	return RateLimit{Requests: n, Period: d}, nil
+}
+
+func loadPasswordPolicy() (PasswordPolicy, error) {
+	policy := PasswordPolicy{MinLength: 12}
+	if value := os.Getenv("PASSWORD_MIN_LENGTH"); value != "" {
+		minLength, err := strconv.Atoi(value)
+		if err != nil || minLength < 8 || minLength > 72 {
+			return policy, fmt.Errorf("PASSWORD_MIN_LENGTH must be between 8 and 72")
+		}
+		policy.MinLength = minLength
+	}
+
+	var err error
+	for name, rule := range map[string]*bool{
+		"PASSWORD_REQUIRE_UPPER":  &policy.RequireUpper,
+		"PASSWORD_REQUIRE_LOWER":  &policy.RequireLower,
+		"PASSWORD_REQUIRE_DIGIT":  &policy.RequireDigit,
+		"PASSWORD_REQUIRE_SYMBOL": &policy.RequireSymbol,
+	} {
+		*rule, err = boolEnv(name)
+		if err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+// boolEnv parses a boolean such as "true" from the environment, false when it is unset.
+func boolEnv(name string) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean", name)
+	}
+	return b, nil
+}
+
+// durationEnv parses a time.Duration such as "24h" from the environment, falling back when it is unset.
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+	return d, nil
+}
+
+// positiveIntEnv parses a positive integer from the environment, falling back when it is unset.
+func positiveIntEnv(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
@@ -0,0 +1,47 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: copyfrom.go
+
+package database
+
+import (
+	"context"
+)
+
+// iteratorForImportUsers implements pgx.CopyFromSource.
+type iteratorForImportUsers struct {
+	rows                 []ImportUsersParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForImportUsers) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForImportUsers) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].Firstname,
+		r.rows[0].Lastname,
+		r.rows[0].Email,
+		r.rows[0].Phone,
+		r.rows[0].Age,
+		r.rows[0].UserStatus,
+	}, nil
+}
+
+func (r iteratorForImportUsers) Err() error {
+	return nil
+}
+
+func (q *Queries) ImportUsers(ctx context.Context, arg []ImportUsersParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"users"}, []string{"firstname", "lastname", "email", "phone", "age", "user_status"}, &iteratorForImportUsers{rows: arg})
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// UniqueViolation returns the error Postgres reports when a row breaks the
+// unique constraint, for the backends emulating it.
+func UniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           pgerrcode.UniqueViolation,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+// ForeignKeyViolation returns the error Postgres reports when a row references
+// a missing row through the foreign key constraint.
+func ForeignKeyViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           pgerrcode.ForeignKeyViolation,
+		Message:        fmt.Sprintf("insert or update violates foreign key constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserEventsChannel is notified by the audit_events trigger when user events are added.
+const UserEventsChannel = "user_events"
+
+// Listener wakes its subscribers when a Postgres notification channel is
+// notified. It uses a single connection however many subscribers there are.
+type Listener struct {
+	Broadcaster
+
+	pool    *pgxpool.Pool
+	channel string
+}
+
+func NewListener(pool *pgxpool.Pool, channel string) *Listener {
+	return &Listener{
+		pool:    pool,
+		channel: channel,
+	}
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting after errors.
+func (l *Listener) Run(ctx context.Context) {
+	delay := time.Second
+	for {
+		started := time.Now()
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			delay = time.Second
+		}
+		slog.ErrorContext(ctx, "error on listening for notifications", "channel", l.channel, "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, 30*time.Second)
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// the connection is left listening, so it is not returned to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+	// notifications sent while disconnected are lost
+	l.Notify()
+
+	for {
+		_, err = conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		l.Notify()
+	}
+}
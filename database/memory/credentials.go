@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) ListUserRoles(ctx context.Context, userid int32) ([]database.Userrole, error) {
+	var roles []database.Userrole
+	s.read(func(t *tables) {
+		if user, ok := t.users[userid]; ok && !user.DeletedAt.Valid {
+			roles = slices.Clone(t.roles[userid])
+		}
+	})
+	slices.Sort(roles)
+	return roles, nil
+}
+
+func (s *Store) SetUserRoles(ctx context.Context, arg database.SetUserRolesParams) error {
+	return s.write(func(t *tables) error {
+		if _, ok := t.users[arg.Userid]; !ok && len(arg.Roles) > 0 {
+			return database.ForeignKeyViolation("user_roles_userid_fkey")
+		}
+		roles := []database.Userrole{}
+		for _, role := range arg.Roles {
+			if !slices.Contains(roles, database.Userrole(role)) {
+				roles = append(roles, database.Userrole(role))
+			}
+		}
+		t.roles[arg.Userid] = roles
+		return nil
+	})
+}
+
+func (s *Store) GetCredential(ctx context.Context, userid int32) (database.UserCredential, error) {
+	var credential database.UserCredential
+	var ok bool
+	s.read(func(t *tables) {
+		user, found := t.users[userid]
+		credential, ok = t.credentials[userid]
+		ok = ok && found && !user.DeletedAt.Valid
+	})
+	if !ok {
+		return database.UserCredential{}, pgx.ErrNoRows
+	}
+	return credential, nil
+}
+
+func (s *Store) GetCredentialByEmail(ctx context.Context, email string) (database.GetCredentialByEmailRow, error) {
+	var row database.GetCredentialByEmailRow
+	err := error(pgx.ErrNoRows)
+	s.read(func(t *tables) {
+		for id, user := range t.users {
+			credential, ok := t.credentials[id]
+			if ok && !user.DeletedAt.Valid && strings.EqualFold(user.Email, email) {
+				row = database.GetCredentialByEmailRow{Userid: id, UserStatus: user.UserStatus, PasswordHash: credential.PasswordHash}
+				err = nil
+				return
+			}
+		}
+	})
+	return row, err
+}
+
+func (s *Store) UpsertCredential(ctx context.Context, arg database.UpsertCredentialParams) error {
+	return s.write(func(t *tables) error {
+		if _, ok := t.users[arg.Userid]; !ok {
+			return database.ForeignKeyViolation("user_credentials_userid_fkey")
+		}
+		t.credentials[arg.Userid] = database.UserCredential{
+			Userid:       arg.Userid,
+			PasswordHash: arg.PasswordHash,
+			UpdatedAt:    now(),
+		}
+		return nil
+	})
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	return s.write(func(t *tables) error {
+		if _, ok := t.users[arg.Userid]; !ok {
+			return database.ForeignKeyViolation("refresh_tokens_userid_fkey")
+		}
+		if _, ok := t.refreshTokens[string(arg.TokenHash)]; ok {
+			return database.UniqueViolation("refresh_tokens_pkey")
+		}
+		t.refreshTokens[string(arg.TokenHash)] = database.RefreshToken{
+			TokenHash: slices.Clone(arg.TokenHash),
+			Userid:    arg.Userid,
+			ExpiresAt: arg.ExpiresAt,
+			CreatedAt: now(),
+		}
+		return nil
+	})
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, tokenHash []byte) (database.RefreshToken, error) {
+	var token database.RefreshToken
+	var ok bool
+	s.read(func(t *tables) {
+		token, ok = t.refreshTokens[string(tokenHash)]
+	})
+	if !ok {
+		return database.RefreshToken{}, pgx.ErrNoRows
+	}
+	return token, nil
+}
+
+// RevokeRefreshToken revokes a valid refresh token and returns its user.
+func (s *Store) RevokeRefreshToken(ctx context.Context, tokenHash []byte) (int32, error) {
+	var userid int32
+	err := s.write(func(t *tables) error {
+		token, ok := t.refreshTokens[string(tokenHash)]
+		revokedAt := now()
+		if !ok || token.RevokedAt.Valid || !token.ExpiresAt.Time.After(revokedAt.Time) {
+			return pgx.ErrNoRows
+		}
+		token.RevokedAt = revokedAt
+		t.refreshTokens[string(tokenHash)] = token
+		userid = token.Userid
+		return nil
+	})
+	return userid, err
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userid int32) (int64, error) {
+	var revoked int64
+	err := s.write(func(t *tables) error {
+		for hash, token := range t.refreshTokens {
+			if token.Userid == userid && !token.RevokedAt.Valid {
+				token.RevokedAt = now()
+				t.refreshTokens[hash] = token
+				revoked++
+			}
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+func (s *Store) RevokeAccessToken(ctx context.Context, arg database.RevokeAccessTokenParams) error {
+	return s.write(func(t *tables) error {
+		if _, ok := t.revokedTokens[arg.Jti]; !ok {
+			t.revokedTokens[arg.Jti] = database.RevokedToken{Jti: arg.Jti, ExpiresAt: arg.ExpiresAt}
+		}
+		return nil
+	})
+}
+
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	s.read(func(t *tables) {
+		_, revoked = t.revokedTokens[jti]
+	})
+	return revoked, nil
+}
+
+// DeleteExpiredTokens removes expired refresh tokens and revoked access tokens.
+func (s *Store) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := s.write(func(t *tables) error {
+		expiry := now().Time
+		for hash, token := range t.refreshTokens {
+			if token.ExpiresAt.Time.Before(expiry) {
+				delete(t.refreshTokens, hash)
+				deleted++
+			}
+		}
+		for jti, token := range t.revokedTokens {
+			if token.ExpiresAt.Time.Before(expiry) {
+				delete(t.revokedTokens, jti)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
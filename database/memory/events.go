@@ -0,0 +1,376 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (s *Store) CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) error {
+	err := s.write(func(t *tables) error {
+		changes := arg.Changes
+		if changes == nil {
+			changes = []byte("{}")
+		}
+		t.lastAuditID++
+		t.auditEvents = append(t.auditEvents, database.AuditEvent{
+			ID:        t.lastAuditID,
+			Userid:    arg.Userid,
+			Action:    arg.Action,
+			ActorID:   arg.ActorID,
+			RequestID: arg.RequestID,
+			Before:    arg.Before,
+			After:     arg.After,
+			Changes:   changes,
+			CreatedAt: now(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// ListAuditEvents returns the events of a user, newest first.
+func (s *Store) ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error) {
+	events := []database.AuditEvent{}
+	s.read(func(t *tables) {
+		for _, event := range slices.Backward(t.auditEvents) {
+			if len(events) == int(arg.MaxResults) {
+				break
+			}
+			if event.Userid == arg.Userid && (arg.BeforeID == 0 || event.ID < arg.BeforeID) {
+				events = append(events, event)
+			}
+		}
+	})
+	return events, nil
+}
+
+// ListUserEvents returns the events following afterID, oldest first. The
+// transactions commit one at a time, so the ids are in commit order and serve
+// as the event ids.
+func (s *Store) ListUserEvents(ctx context.Context, arg database.ListUserEventsParams) ([]database.ListUserEventsRow, error) {
+	events := []database.ListUserEventsRow{}
+	s.read(func(t *tables) {
+		for _, event := range t.auditEvents {
+			if len(events) == int(arg.MaxResults) {
+				break
+			}
+			if event.ID > arg.AfterID {
+				events = append(events, database.ListUserEventsRow{
+					Seq:       event.ID,
+					Userid:    event.Userid,
+					Action:    event.Action,
+					Changes:   event.Changes,
+					CreatedAt: event.CreatedAt,
+				})
+			}
+		}
+	})
+	return events, nil
+}
+
+func (s *Store) LatestUserEventID(ctx context.Context) (int64, error) {
+	var id int64
+	s.read(func(t *tables) {
+		id = t.lastAuditID
+	})
+	return id, nil
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	var webhook database.Webhook
+	err := s.write(func(t *tables) error {
+		t.lastWebhookID++
+		webhook = database.Webhook{
+			ID:        t.lastWebhookID,
+			Url:       arg.Url,
+			Secret:    arg.Secret,
+			Events:    slices.Clone(arg.Events),
+			Active:    arg.Active,
+			CreatedAt: now(),
+		}
+		t.webhooks[webhook.ID] = webhook
+		return nil
+	})
+	return webhook, err
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id int32) (database.Webhook, error) {
+	var webhook database.Webhook
+	var ok bool
+	s.read(func(t *tables) {
+		webhook, ok = t.webhooks[id]
+	})
+	if !ok {
+		return database.Webhook{}, pgx.ErrNoRows
+	}
+	return webhook, nil
+}
+
+func (s *Store) ListWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	var webhooks []database.Webhook
+	s.read(func(t *tables) {
+		webhooks = slices.Collect(maps.Values(t.webhooks))
+	})
+	slices.SortFunc(webhooks, func(a, b database.Webhook) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return webhooks, nil
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, arg database.UpdateWebhookParams) (database.Webhook, error) {
+	var webhook database.Webhook
+	err := s.write(func(t *tables) error {
+		var ok bool
+		webhook, ok = t.webhooks[arg.ID]
+		if !ok {
+			return pgx.ErrNoRows
+		}
+		webhook.Url = arg.Url
+		webhook.Events = slices.Clone(arg.Events)
+		webhook.Active = arg.Active
+		t.webhooks[arg.ID] = webhook
+		return nil
+	})
+	return webhook, err
+}
+
+// DeleteWebhook deletes a webhook with its deliveries.
+func (s *Store) DeleteWebhook(ctx context.Context, id int32) (int64, error) {
+	var deleted int64
+	err := s.write(func(t *tables) error {
+		if _, ok := t.webhooks[id]; !ok {
+			return nil
+		}
+		delete(t.webhooks, id)
+		maps.DeleteFunc(t.deliveries, func(_ int64, d database.WebhookDelivery) bool {
+			return d.WebhookID == id
+		})
+		deleted = 1
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *Store) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) error {
+	return s.write(func(t *tables) error {
+		t.lastOutboxID++
+		t.outbox = append(t.outbox, database.WebhookOutbox{
+			ID:        t.lastOutboxID,
+			EventType: arg.EventType,
+			Userid:    arg.Userid,
+			Payload:   arg.Payload,
+			CreatedAt: now(),
+		})
+		return nil
+	})
+}
+
+// FanOutWebhookEvents marks up to maxEvents outbox events as dispatched, and
+// creates a delivery of each to the active webhooks subscribed to it.
+func (s *Store) FanOutWebhookEvents(ctx context.Context, maxEvents int32) (int64, error) {
+	var created int64
+	err := s.write(func(t *tables) error {
+		webhooks := slices.Collect(maps.Values(t.webhooks))
+		slices.SortFunc(webhooks, func(a, b database.Webhook) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		fannedOut := int32(0)
+		for i, event := range t.outbox {
+			if fannedOut == maxEvents {
+				break
+			}
+			if event.DispatchedAt.Valid {
+				continue
+			}
+			fannedOut++
+			event.DispatchedAt = now()
+			t.outbox[i] = event
+
+			for _, webhook := range webhooks {
+				if !webhook.Active || !slices.Contains(webhook.Events, event.EventType) || webhook.CreatedAt.Time.After(event.CreatedAt.Time) {
+					continue
+				}
+				if t.hasDelivery(webhook.ID, event.ID) {
+					continue
+				}
+				t.lastDeliveryID++
+				t.deliveries[t.lastDeliveryID] = database.WebhookDelivery{
+					ID:            t.lastDeliveryID,
+					WebhookID:     webhook.ID,
+					EventID:       event.ID,
+					Status:        database.DeliverystatusPending,
+					NextAttemptAt: event.DispatchedAt,
+					CreatedAt:     event.DispatchedAt,
+				}
+				created++
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+func (t *tables) hasDelivery(webhookID int32, eventID int64) bool {
+	for _, d := range t.deliveries {
+		if d.WebhookID == webhookID && d.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimWebhookDeliveries leases the pending deliveries that are due, counting
+// an attempt for each.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	rows := []database.ClaimWebhookDeliveriesRow{}
+	err := s.write(func(t *tables) error {
+		claimedAt := now()
+		var due []database.WebhookDelivery
+		for _, d := range t.deliveries {
+			if d.Status == database.DeliverystatusPending && !d.NextAttemptAt.Time.After(claimedAt.Time) {
+				due = append(due, d)
+			}
+		}
+		slices.SortFunc(due, func(a, b database.WebhookDelivery) int {
+			return cmp.Or(a.NextAttemptAt.Time.Compare(b.NextAttemptAt.Time), cmp.Compare(a.ID, b.ID))
+		})
+
+		lease := time.Duration(arg.LeaseSeconds * float64(time.Second))
+		for _, d := range due[:min(int(arg.MaxResults), len(due))] {
+			d.Attempts++
+			d.NextAttemptAt = pgtype.Timestamptz{Time: claimedAt.Time.Add(lease), Valid: true}
+			t.deliveries[d.ID] = d
+
+			webhook := t.webhooks[d.WebhookID]
+			event, _ := t.outboxEvent(d.EventID)
+			rows = append(rows, database.ClaimWebhookDeliveriesRow{
+				ID:             d.ID,
+				Attempts:       d.Attempts,
+				Url:            webhook.Url,
+				Secret:         webhook.Secret,
+				EventID:        event.ID,
+				EventType:      event.EventType,
+				Payload:        event.Payload,
+				EventCreatedAt: event.CreatedAt,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (t *tables) outboxEvent(id int64) (database.WebhookOutbox, bool) {
+	i, ok := slices.BinarySearchFunc(t.outbox, id, func(e database.WebhookOutbox, id int64) int {
+		return cmp.Compare(e.ID, id)
+	})
+	if !ok {
+		return database.WebhookOutbox{}, false
+	}
+	return t.outbox[i], true
+}
+
+func (s *Store) RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error {
+	return s.write(func(t *tables) error {
+		d, ok := t.deliveries[arg.ID]
+		if !ok {
+			return nil
+		}
+		d.Status = arg.Status
+		d.NextAttemptAt = arg.NextAttemptAt
+		d.LastStatusCode = arg.LastStatusCode
+		d.LastError = arg.LastError
+		d.DeliveredAt = pgtype.Timestamptz{}
+		if arg.Status == database.DeliverystatusDelivered {
+			d.DeliveredAt = now()
+		}
+		t.deliveries[arg.ID] = d
+		return nil
+	})
+}
+
+// ListWebhookDeliveries returns the deliveries of a webhook, newest first.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.ListWebhookDeliveriesRow, error) {
+	rows := []database.ListWebhookDeliveriesRow{}
+	s.read(func(t *tables) {
+		for _, d := range t.deliveries {
+			if d.WebhookID != arg.WebhookID || (arg.BeforeID != 0 && d.ID >= arg.BeforeID) {
+				continue
+			}
+			event, _ := t.outboxEvent(d.EventID)
+			rows = append(rows, database.ListWebhookDeliveriesRow{
+				ID:             d.ID,
+				WebhookID:      d.WebhookID,
+				EventID:        d.EventID,
+				Status:         d.Status,
+				Attempts:       d.Attempts,
+				NextAttemptAt:  d.NextAttemptAt,
+				LastStatusCode: d.LastStatusCode,
+				LastError:      d.LastError,
+				DeliveredAt:    d.DeliveredAt,
+				CreatedAt:      d.CreatedAt,
+				EventType:      event.EventType,
+			})
+		}
+	})
+	slices.SortFunc(rows, func(a, b database.ListWebhookDeliveriesRow) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return rows[:min(int(arg.MaxResults), len(rows))], nil
+}
+
+// RetryWebhookDelivery schedules a dead delivery again, with a fresh attempt count.
+func (s *Store) RetryWebhookDelivery(ctx context.Context, arg database.RetryWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	var d database.WebhookDelivery
+	err := s.write(func(t *tables) error {
+		var ok bool
+		d, ok = t.deliveries[arg.ID]
+		if !ok || d.WebhookID != arg.WebhookID || d.Status != database.DeliverystatusDead {
+			return pgx.ErrNoRows
+		}
+		d.Status = database.DeliverystatusPending
+		d.Attempts = 0
+		d.NextAttemptAt = now()
+		t.deliveries[arg.ID] = d
+		return nil
+	})
+	return d, err
+}
+
+// DeleteFinishedWebhookEvents removes the events dispatched before
+// dispatchedBefore that have no pending delivery left, with their deliveries.
+func (s *Store) DeleteFinishedWebhookEvents(ctx context.Context, dispatchedBefore pgtype.Timestamptz) (int64, error) {
+	var deleted int64
+	err := s.write(func(t *tables) error {
+		pending := map[int64]bool{}
+		for _, d := range t.deliveries {
+			if d.Status == database.DeliverystatusPending {
+				pending[d.EventID] = true
+			}
+		}
+		finished := map[int64]bool{}
+		t.outbox = slices.DeleteFunc(t.outbox, func(e database.WebhookOutbox) bool {
+			if !e.DispatchedAt.Valid || !e.DispatchedAt.Time.Before(dispatchedBefore.Time) || pending[e.ID] {
+				return false
+			}
+			finished[e.ID] = true
+			return true
+		})
+		maps.DeleteFunc(t.deliveries, func(_ int64, d database.WebhookDelivery) bool {
+			return finished[d.EventID]
+		})
+		deleted = int64(len(finished))
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,139 @@
+// Package memory implements database.Querier in memory, for running the
+// service and its tests without a database server. Data is lost when the
+// process exits.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Store keeps the tables in memory. It is safe for concurrent use:
+// transactions run one at a time, and see no change made outside them.
+type Store struct {
+	// Broadcaster is notified when user events are committed.
+	*database.Broadcaster
+
+	mu   *sync.RWMutex
+	data *tables
+	// tx is set on the Store bound to a transaction. ExecTx holds mu for it.
+	tx *txState
+}
+
+type txState struct {
+	// notify is set when the transaction recorded user events.
+	notify bool
+}
+
+// tables holds the rows of every table. Rows are stored by value and their
+// slices are never modified in place, so copying the maps and slices is
+// enough to snapshot the tables.
+type tables struct {
+	users         map[int32]database.User
+	roles         map[int32][]database.Userrole
+	credentials   map[int32]database.UserCredential
+	refreshTokens map[string]database.RefreshToken
+	revokedTokens map[string]database.RevokedToken
+	auditEvents   []database.AuditEvent
+	webhooks      map[int32]database.Webhook
+	outbox        []database.WebhookOutbox
+	deliveries    map[int64]database.WebhookDelivery
+
+	lastUserID     int32
+	lastAuditID    int64
+	lastWebhookID  int32
+	lastOutboxID   int64
+	lastDeliveryID int64
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		Broadcaster: &database.Broadcaster{},
+		mu:          &sync.RWMutex{},
+		data: &tables{
+			users:         map[int32]database.User{},
+			roles:         map[int32][]database.Userrole{},
+			credentials:   map[int32]database.UserCredential{},
+			refreshTokens: map[string]database.RefreshToken{},
+			revokedTokens: map[string]database.RevokedToken{},
+			webhooks:      map[int32]database.Webhook{},
+			deliveries:    map[int64]database.WebhookDelivery{},
+		},
+	}
+}
+
+func (t *tables) clone() *tables {
+	c := *t
+	c.users = maps.Clone(t.users)
+	c.roles = maps.Clone(t.roles)
+	c.credentials = maps.Clone(t.credentials)
+	c.refreshTokens = maps.Clone(t.refreshTokens)
+	c.revokedTokens = maps.Clone(t.revokedTokens)
+	c.auditEvents = slices.Clone(t.auditEvents)
+	c.webhooks = maps.Clone(t.webhooks)
+	c.outbox = slices.Clone(t.outbox)
+	c.deliveries = maps.Clone(t.deliveries)
+	return &c
+}
+
+// ExecTx runs fn on a copy of the tables, which replaces them when fn
+// succeeds. Called within a transaction it nests like a savepoint.
+func (s *Store) ExecTx(ctx context.Context, fn func(database.Querier) error) error {
+	if s.tx == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	tx := &Store{Broadcaster: s.Broadcaster, mu: s.mu, data: s.data.clone(), tx: &txState{}}
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	s.data = tx.data
+
+	if tx.tx.notify {
+		s.notify()
+	}
+	return nil
+}
+
+// read runs fn on the tables, holding the read lock outside transactions.
+func (s *Store) read(fn func(t *tables)) {
+	if s.tx == nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	fn(s.data)
+}
+
+// write runs fn on the tables, holding the write lock outside transactions.
+// fn must check every constraint before changing a table, as there is no
+// rollback outside transactions.
+func (s *Store) write(fn func(t *tables) error) error {
+	if s.tx == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(s.data)
+}
+
+// notify wakes the subscribers, once the transaction commits when in one.
+func (s *Store) notify() {
+	if s.tx != nil {
+		s.tx.notify = true
+		return
+	}
+	s.Notify()
+}
+
+func now() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now(), Valid: true}
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"testing"
+	"user-manager/database"
+	"user-manager/database/querytest"
+)
+
+func TestStore(t *testing.T) {
+	querytest.Run(t, func(t *testing.T) database.Querier {
+		return New()
+	})
+}
@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const emailConstraint = "users_email_key"
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	var user database.User
+	err := s.write(func(t *tables) error {
+		if t.emailTaken(arg.Email, 0) {
+			return database.UniqueViolation(emailConstraint)
+		}
+		t.lastUserID++
+		user = database.User{
+			Userid:     t.lastUserID,
+			Firstname:  arg.Firstname,
+			Lastname:   arg.Lastname,
+			Email:      arg.Email,
+			Phone:      arg.Phone,
+			Age:        arg.Age,
+			UserStatus: arg.UserStatus,
+			Version:    1,
+		}
+		t.users[user.Userid] = user
+		return nil
+	})
+	return user, err
+}
+
+func (s *Store) GetUser(ctx context.Context, arg database.GetUserParams) (database.User, error) {
+	var user database.User
+	var ok bool
+	s.read(func(t *tables) {
+		user, ok = t.users[arg.Userid]
+	})
+	if !ok || (user.DeletedAt.Valid && !arg.IncludeDeleted) {
+		return database.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]database.User, error) {
+	users := s.matching(database.ListUsersFilter{})
+	slices.SortStableFunc(users, func(a, b database.User) int {
+		return strings.Compare(a.Firstname, b.Firstname)
+	})
+	return users, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	var user database.User
+	err := s.write(func(t *tables) error {
+		current, ok := t.users[arg.Userid]
+		if !ok || current.Version != arg.Version || current.DeletedAt.Valid {
+			return pgx.ErrNoRows
+		}
+		if t.emailTaken(arg.Email, arg.Userid) {
+			return database.UniqueViolation(emailConstraint)
+		}
+		user = current
+		user.Firstname = arg.Firstname
+		user.Lastname = arg.Lastname
+		user.Email = arg.Email
+		user.Phone = arg.Phone
+		user.Age = arg.Age
+		user.UserStatus = arg.UserStatus
+		user.Version++
+		t.users[user.Userid] = user
+		return nil
+	})
+	return user, err
+}
+
+func (s *Store) DeleteUser(ctx context.Context, arg database.DeleteUserParams) (int64, error) {
+	var deleted int64
+	err := s.write(func(t *tables) error {
+		user, ok := t.users[arg.Userid]
+		if !ok || user.Version != arg.Version || user.DeletedAt.Valid {
+			return nil
+		}
+		user.DeletedAt = now()
+		user.Version++
+		t.users[user.Userid] = user
+		deleted = 1
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *Store) RestoreUser(ctx context.Context, userid int32) (database.User, error) {
+	var user database.User
+	err := s.write(func(t *tables) error {
+		var ok bool
+		user, ok = t.users[userid]
+		if !ok || !user.DeletedAt.Valid {
+			return pgx.ErrNoRows
+		}
+		if t.emailTaken(user.Email, userid) {
+			return database.UniqueViolation(emailConstraint)
+		}
+		user.DeletedAt = pgtype.Timestamptz{}
+		user.Version++
+		t.users[userid] = user
+		return nil
+	})
+	return user, err
+}
+
+// PurgeDeletedUsers removes users soft deleted before deletedBefore, with
+// their roles, credentials and refresh tokens, and returns them.
+func (s *Store) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]database.User, error) {
+	purged := []database.User{}
+	err := s.write(func(t *tables) error {
+		for id, user := range t.users {
+			if !user.DeletedAt.Valid || !user.DeletedAt.Time.Before(deletedBefore.Time) {
+				continue
+			}
+			delete(t.users, id)
+			delete(t.roles, id)
+			delete(t.credentials, id)
+			for hash, token := range t.refreshTokens {
+				if token.Userid == id {
+					delete(t.refreshTokens, hash)
+				}
+			}
+			purged = append(purged, user)
+		}
+		slices.SortFunc(purged, func(a, b database.User) int {
+			return cmp.Compare(a.Userid, b.Userid)
+		})
+		return nil
+	})
+	return purged, err
+}
+
+func (s *Store) ListUsersPage(ctx context.Context, arg database.ListUsersPageParams) ([]database.User, error) {
+	users, err := s.sorted(arg.Filter, arg.SortColumn, arg.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	if arg.After != nil {
+		start := len(users)
+		for i, user := range users {
+			c := compareKeys(arg.SortColumn, database.SortKey(user, arg.SortColumn), *arg.After)
+			if (c > 0 && !arg.Descending) || (c < 0 && arg.Descending) {
+				start = i
+				break
+			}
+		}
+		users = users[start:]
+	} else {
+		users = users[min(int(arg.Offset), len(users)):]
+	}
+	return users[:min(int(arg.Limit), len(users))], nil
+}
+
+func (s *Store) CountUsers(ctx context.Context, arg database.ListUsersFilter) (int64, error) {
+	return int64(len(s.matching(arg))), nil
+}
+
+// ExportUsers calls fn with a snapshot of the matching users, so that a slow
+// consumer does not hold the lock.
+func (s *Store) ExportUsers(ctx context.Context, arg database.ExportUsersParams, fn func(database.User) error) error {
+	users, err := s.sorted(arg.Filter, arg.SortColumn, arg.Descending)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		err = fn(user)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchUsers scores users with database.MatchUser, in place of the full text
+// and trigram search of Postgres.
+func (s *Store) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.SearchUsersRow, error) {
+	rows := []database.SearchUsersRow{}
+	for _, u := range s.matching(database.ListUsersFilter{}) {
+		score, ok := database.MatchUser(u, arg.Query)
+		if !ok {
+			continue
+		}
+		rows = append(rows, database.SearchUsersRow{
+			Userid:     u.Userid,
+			Firstname:  u.Firstname,
+			Lastname:   u.Lastname,
+			Email:      u.Email,
+			Phone:      u.Phone,
+			Age:        u.Age,
+			UserStatus: u.UserStatus,
+			DeletedAt:  u.DeletedAt,
+			Version:    u.Version,
+			Score:      score,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.SearchUsersRow) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Userid, b.Userid))
+	})
+	return rows[:min(int(arg.MaxResults), len(rows))], nil
+}
+
+func (s *Store) ImportUsers(ctx context.Context, arg []database.ImportUsersParams) (int64, error) {
+	err := s.write(func(t *tables) error {
+		emails := map[string]bool{}
+		for _, row := range arg {
+			email := strings.ToLower(row.Email)
+			if emails[email] || t.emailTaken(row.Email, 0) {
+				return database.UniqueViolation(emailConstraint)
+			}
+			emails[email] = true
+		}
+		for _, row := range arg {
+			t.lastUserID++
+			t.users[t.lastUserID] = database.User{
+				Userid:     t.lastUserID,
+				Firstname:  row.Firstname,
+				Lastname:   row.Lastname,
+				Email:      row.Email,
+				Phone:      row.Phone,
+				Age:        row.Age,
+				UserStatus: row.UserStatus,
+				Version:    1,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(arg)), nil
+}
+
+// ListUsersByEmails returns the users whose lower cased email is in emails.
+func (s *Store) ListUsersByEmails(ctx context.Context, emails []string) ([]database.User, error) {
+	users := []database.User{}
+	for _, user := range s.matching(database.ListUsersFilter{}) {
+		if slices.Contains(emails, strings.ToLower(user.Email)) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// emailTaken reports whether a user other than userid has the email. Like
+// the unique index of Postgres it ignores case and soft deleted users.
+func (t *tables) emailTaken(email string, userid int32) bool {
+	for _, user := range t.users {
+		if user.Userid != userid && !user.DeletedAt.Valid && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// matching returns the users matching the filter, in no particular order.
+func (s *Store) matching(f database.ListUsersFilter) []database.User {
+	users := []database.User{}
+	s.read(func(t *tables) {
+		for _, user := range t.users {
+			if matches(user, f) {
+				users = append(users, user)
+			}
+		}
+	})
+	return users
+}
+
+func matches(u database.User, f database.ListUsersFilter) bool {
+	switch {
+	case u.DeletedAt.Valid && !f.IncludeDeleted:
+		return false
+	case f.Status.Valid && u.UserStatus != f.Status:
+		return false
+	case f.MinAge.Valid && (!u.Age.Valid || u.Age.Int32 < f.MinAge.Int32):
+		return false
+	case f.MaxAge.Valid && (!u.Age.Valid || u.Age.Int32 > f.MaxAge.Int32):
+		return false
+	case f.EmailPrefix.Valid && !hasPrefixFold(u.Email, f.EmailPrefix.String):
+		return false
+	case f.NamePrefix.Valid && !hasPrefixFold(u.Firstname, f.NamePrefix.String) && !hasPrefixFold(u.Lastname, f.NamePrefix.String):
+		return false
+	}
+	return true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// sorted returns the users matching the filter ordered by the sort column, with userId as tie breaker.
+func (s *Store) sorted(f database.ListUsersFilter, column string, descending bool) ([]database.User, error) {
+	if !database.IsSortColumn(column) {
+		return nil, fmt.Errorf("unsupported sort column: %s", column)
+	}
+	users := s.matching(f)
+	slices.SortFunc(users, func(a, b database.User) int {
+		c := compareKeys(column, database.SortKey(a, column), database.SortKey(b, column))
+		if descending {
+			return -c
+		}
+		return c
+	})
+	return users, nil
+}
+
+// compareKeys orders keyset positions like Postgres orders the sort column:
+// numerically for userId and age, by text otherwise.
+func compareKeys(column string, a, b database.UserKey) int {
+	var c int
+	if column == "userId" || column == "age" {
+		x, _ := strconv.Atoi(a.Value)
+		y, _ := strconv.Atoi(b.Value)
+		c = cmp.Compare(x, y)
+	} else {
+		c = strings.Compare(a.Value, b.Value)
+	}
+	return cmp.Or(c, cmp.Compare(a.Userid, b.Userid))
+}
@@ -7,5 +7,5 @@ CREATE TABLE users (
   email varchar NOT NULL,
   phone varchar,
   age int,
-  user_status userStatus DEFAULT userStatus.Active
-);
\ No newline at end of file
+  user_status userStatus DEFAULT 'Active'
+);
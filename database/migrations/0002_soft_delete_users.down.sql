@@ -0,0 +1,5 @@
+DROP INDEX users_deleted_at_idx;
+
+DROP INDEX users_email_key;
+
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN deleted_at timestamptz;
+
+CREATE UNIQUE INDEX users_email_key ON users (lower(email)) WHERE deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX users_search_trgm_idx;
+
+DROP INDEX users_search_idx;
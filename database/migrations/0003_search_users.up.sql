@@ -0,0 +1,7 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_search_idx ON users
+  USING GIN (to_tsvector('simple', firstName || ' ' || lastName || ' ' || email));
+
+CREATE INDEX users_search_trgm_idx ON users
+  USING GIN ((firstName || ' ' || lastName || ' ' || email) gin_trgm_ops);
@@ -0,0 +1,7 @@
+CREATE TYPE userRole AS ENUM ('admin', 'support');
+
+CREATE TABLE user_roles (
+  userId int NOT NULL REFERENCES users (userId) ON DELETE CASCADE,
+  role userRole NOT NULL,
+  PRIMARY KEY (userId, role)
+);
@@ -0,0 +1,5 @@
+DROP TABLE revoked_tokens;
+
+DROP TABLE refresh_tokens;
+
+DROP TABLE user_credentials;
@@ -0,0 +1,20 @@
+CREATE TABLE user_credentials (
+  userId int PRIMARY KEY REFERENCES users (userId) ON DELETE CASCADE,
+  password_hash text NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE refresh_tokens (
+  token_hash bytea PRIMARY KEY,
+  userId int NOT NULL REFERENCES users (userId) ON DELETE CASCADE,
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refresh_tokens_userid_idx ON refresh_tokens (userId);
+
+CREATE TABLE revoked_tokens (
+  jti text PRIMARY KEY,
+  expires_at timestamptz NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP TABLE audit_events;
+
+DROP TYPE auditAction;
@@ -0,0 +1,16 @@
+CREATE TYPE auditAction AS ENUM ('create', 'update', 'delete', 'restore');
+
+-- audit events outlive purged users, so userId is not a foreign key
+CREATE TABLE audit_events (
+  id bigserial PRIMARY KEY,
+  userId int NOT NULL,
+  action auditAction NOT NULL,
+  actor_id int,
+  request_id text,
+  before jsonb,
+  after jsonb,
+  changes jsonb NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_userid_idx ON audit_events (userId, id);
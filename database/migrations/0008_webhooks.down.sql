@@ -0,0 +1,7 @@
+DROP TABLE webhook_deliveries;
+
+DROP TYPE deliveryStatus;
+
+DROP TABLE webhook_outbox;
+
+DROP TABLE webhooks;
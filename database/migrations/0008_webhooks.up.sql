@@ -0,0 +1,40 @@
+CREATE TABLE webhooks (
+  id serial PRIMARY KEY,
+  url text NOT NULL,
+  secret text NOT NULL,
+  events text[] NOT NULL,
+  active boolean NOT NULL DEFAULT true,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- the outbox is written in the transaction of the user change, and fanned out
+-- to webhook_deliveries by the dispatcher
+CREATE TABLE webhook_outbox (
+  id bigserial PRIMARY KEY,
+  event_type text NOT NULL,
+  userId int NOT NULL,
+  payload jsonb NOT NULL,
+  dispatched_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX webhook_outbox_pending_idx ON webhook_outbox (id) WHERE dispatched_at IS NULL;
+
+CREATE TYPE deliveryStatus AS ENUM ('pending', 'delivered', 'dead');
+
+CREATE TABLE webhook_deliveries (
+  id bigserial PRIMARY KEY,
+  webhook_id int NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  event_id bigint NOT NULL REFERENCES webhook_outbox (id) ON DELETE CASCADE,
+  status deliveryStatus NOT NULL DEFAULT 'pending',
+  attempts int NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz NOT NULL DEFAULT now(),
+  last_status_code int,
+  last_error text,
+  delivered_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1,3 @@
+DROP TRIGGER audit_events_notify ON audit_events;
+
+DROP FUNCTION notify_user_events();
@@ -0,0 +1,12 @@
+-- audit_events doubles as the change feed of GET /users/events. Listeners are
+-- woken once per transaction, the payload-less notifications being merged.
+CREATE FUNCTION notify_user_events() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('user_events', '');
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_notify
+AFTER INSERT ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION notify_user_events();
@@ -0,0 +1,3 @@
+DROP FUNCTION take_rate_limit_token(text, double precision, double precision);
+
+DROP TABLE rate_limits;
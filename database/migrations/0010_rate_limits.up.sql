@@ -0,0 +1,41 @@
+-- Token buckets of the rate limiter shared by the replicas, by client and
+-- route. A bucket refills at rate tokens per second up to burst; once full at
+-- full_at its row can be deleted.
+CREATE TABLE rate_limits (
+  key text PRIMARY KEY,
+  tokens double precision NOT NULL,
+  updated_at timestamptz NOT NULL,
+  full_at timestamptz NOT NULL
+);
+
+CREATE INDEX rate_limits_full_at_idx ON rate_limits (full_at);
+
+-- take_rate_limit_token takes a token from the bucket of bucket_key when one is
+-- left. It uses the clock time rather than the transaction time, so that a
+-- call waiting for the row lock does not refill the bucket backwards.
+CREATE FUNCTION take_rate_limit_token(bucket_key text, burst double precision, rate double precision,
+  OUT remaining double precision, OUT allowed boolean) AS $$
+DECLARE
+  updated timestamptz;
+  at timestamptz;
+BEGIN
+  INSERT INTO rate_limits (key, tokens, updated_at, full_at)
+  VALUES (bucket_key, burst, clock_timestamp(), clock_timestamp())
+  ON CONFLICT (key) DO NOTHING;
+
+  SELECT tokens, updated_at INTO remaining, updated
+  FROM rate_limits WHERE key = bucket_key
+  FOR UPDATE;
+
+  at := clock_timestamp();
+  remaining := LEAST(burst, remaining + GREATEST(EXTRACT(EPOCH FROM at - updated), 0) * rate);
+  allowed := remaining >= 1;
+  IF allowed THEN
+    remaining := remaining - 1;
+  END IF;
+
+  UPDATE rate_limits
+    SET tokens = remaining, updated_at = at, full_at = at + (burst - remaining) / rate * interval '1 second'
+  WHERE key = bucket_key;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,5 @@
+DROP TRIGGER audit_events_sequence ON audit_events;
+
+DROP FUNCTION sequence_user_event();
+
+ALTER TABLE audit_events DROP COLUMN seq;
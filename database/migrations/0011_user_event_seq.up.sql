@@ -0,0 +1,28 @@
+-- GET /users/events reads the audit events by seq, numbered in commit order.
+-- Ids are taken at insert time, so a long transaction could commit an id lower
+-- than one a stream had already read past, and the stream would skip it.
+ALTER TABLE audit_events ADD COLUMN seq bigint;
+
+CREATE SEQUENCE audit_events_seq_seq OWNED BY audit_events.seq;
+
+-- the events recorded so far keep their id, so that streams resume where they were
+UPDATE audit_events SET seq = id;
+SELECT setval('audit_events_seq_seq', max(id)) FROM audit_events HAVING max(id) IS NOT NULL;
+
+CREATE UNIQUE INDEX audit_events_seq_idx ON audit_events (seq);
+
+-- sequence_user_event numbers an event when its transaction commits. The lock
+-- is held until the transaction ends, so a transaction only takes numbers once
+-- the ones before it are committed or rolled back.
+CREATE FUNCTION sequence_user_event() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('audit_events_seq'));
+  UPDATE audit_events SET seq = nextval('audit_events_seq_seq') WHERE id = NEW.id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER audit_events_sequence
+AFTER INSERT ON audit_events
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION sequence_user_event();
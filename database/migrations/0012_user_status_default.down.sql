@@ -0,0 +1,2 @@
+-- Nothing to revert: 0001 sets the same default, and the backfilled users had
+-- the Active status by default.
@@ -0,0 +1,5 @@
+-- Users created without a status were stored with a NULL status, bypassing the
+-- column default. Databases created from the first schema.sql have no default.
+UPDATE users SET user_status = 'Active' WHERE user_status IS NULL;
+
+ALTER TABLE users ALTER COLUMN user_status SET DEFAULT 'Active';
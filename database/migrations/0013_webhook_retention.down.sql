@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS webhook_deliveries_event_idx;
+DROP INDEX IF EXISTS webhook_outbox_dispatched_idx;
@@ -0,0 +1,4 @@
+-- The retention sweep finds old dispatched events, then looks up their
+-- deliveries, as does the cascade deleting them.
+CREATE INDEX webhook_outbox_dispatched_idx ON webhook_outbox (dispatched_at) WHERE dispatched_at IS NOT NULL;
+CREATE INDEX webhook_deliveries_event_idx ON webhook_deliveries (event_id);
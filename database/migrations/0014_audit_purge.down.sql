@@ -0,0 +1,2 @@
+-- Nothing to revert: enum values can not be dropped, and the purge events
+-- recorded since are kept.
@@ -0,0 +1,2 @@
+-- purged users are recorded in the audit log
+ALTER TYPE auditAction ADD VALUE IF NOT EXISTS 'purge';
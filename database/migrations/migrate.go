@@ -0,0 +1,337 @@
+// Package migrations applies the versioned schema migrations embedded in the binary.
+//
+// Migrations are pairs of NNNN_name.up.sql and NNNN_name.down.sql files, applied
+// in version order. Applied versions are recorded in the schema_migrations table.
+//
+// Databases created before the migrations, from the schema.sql file mounted as
+// a Postgres init script, are baselined: the migrations whose objects exist
+// already are recorded as applied without running them.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID is the advisory lock key held while migrating, so that replicas
+// starting together do not apply the same migration twice.
+const lockID = 7_301_004_321
+
+// baselineChecks tell whether the objects of the migrations that the
+// schema.sql file used to create exist, in version order.
+var baselineChecks = []struct {
+	version int64
+	exists  string
+}{
+	{1, "SELECT to_regclass('users') IS NOT NULL"},
+	{2, `SELECT EXISTS (SELECT 1 FROM information_schema.columns
+  WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'deleted_at')`},
+	{3, "SELECT to_regclass('users_search_trgm_idx') IS NOT NULL"},
+	{4, "SELECT to_regclass('user_roles') IS NOT NULL"},
+	{5, "SELECT to_regclass('revoked_tokens') IS NOT NULL"},
+	{6, "SELECT to_regclass('audit_events') IS NOT NULL"},
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations in version order.
+func Load() ([]Migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, file := range names {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s", file)
+		}
+
+		data, err := files.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns the ones applied.
+func Up(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	var applied []Migration
+	err := withLock(ctx, pool, func(conn *pgx.Conn) error {
+		migrations, err := Load()
+		if err != nil {
+			return err
+		}
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			done, err = baseline(ctx, conn, migrations)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, m.Up)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations and returns the ones reverted.
+func Down(ctx context.Context, pool *pgxpool.Pool, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := withLock(ctx, pool, func(conn *pgx.Conn) error {
+		migrations, err := Load()
+		if err != nil {
+			return err
+		}
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %04d_%s cannot be reverted", m.Version, m.Name)
+			}
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, m.Down)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// GetStatus lists every migration with the time it was applied, nil when
+// pending. It only reads, so it does not wait for a replica migrating: the
+// migrations it is applying are listed as pending until they commit.
+func GetStatus(ctx context.Context, pool *pgxpool.Pool) ([]Status, error) {
+	migrations, done, err := readApplied(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, m := range migrations {
+		status := Status{Migration: m}
+		if appliedAt, ok := done[m.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that are not applied. Like GetStatus it does
+// not wait for the advisory lock, so readiness probes can call it.
+func Pending(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	migrations, done, err := readApplied(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if _, ok := done[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// baseline records as applied the leading migrations whose objects exist
+// already, and returns the versions recorded. It stops at the first migration
+// missing, so that a partial schema fails on the next migration rather than
+// being taken for a newer one.
+func baseline(ctx context.Context, conn *pgx.Conn, migrations []Migration) (map[int64]time.Time, error) {
+	names := map[int64]string{}
+	for _, m := range migrations {
+		names[m.Version] = m.Name
+	}
+
+	done := map[int64]time.Time{}
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		for _, check := range baselineChecks {
+			var exists bool
+			err := tx.QueryRow(ctx, check.exists).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return nil
+			}
+
+			var appliedAt time.Time
+			err = tx.QueryRow(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2) RETURNING applied_at",
+				check.version, names[check.version]).Scan(&appliedAt)
+			if err != nil {
+				return err
+			}
+			done[check.version] = appliedAt
+			slog.InfoContext(ctx, "baselined migration", "version", check.version, "name", names[check.version])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baselining the existing schema: %w", err)
+	}
+	return done, nil
+}
+
+// withLock runs fn on a single connection holding the migrations advisory lock.
+func withLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgx.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint PRIMARY KEY,
+  name text NOT NULL,
+  applied_at timestamptz NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn.Conn())
+}
+
+// readApplied returns the embedded migrations and the applied versions,
+// without taking the lock. Nothing is applied before schema_migrations exists.
+func readApplied(ctx context.Context, pool *pgxpool.Pool) ([]Migration, map[int64]time.Time, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Release()
+
+	var exists bool
+	err = conn.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil || !exists {
+		return migrations, map[int64]time.Time{}, err
+	}
+	done, err := appliedVersions(ctx, conn.Conn())
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrations, done, nil
+}
+
+func appliedVersions(ctx context.Context, conn *pgx.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import "testing"
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("Test Failure! Migrations not loaded")
+	}
+	for i, m := range migrations {
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("Test Failure! Migration %04d_%s is missing a direction", m.Version, m.Name)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Errorf("Test Failure! Migrations out of order at %04d", m.Version)
+		}
+	}
+}
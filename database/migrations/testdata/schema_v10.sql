@@ -0,0 +1,70 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TYPE userStatus AS ENUM ('Active', 'Inactive');
+
+CREATE TABLE users (
+  userId SERIAL PRIMARY KEY,
+  firstName varchar(50) NOT NULL,
+  lastName varchar(50) NOT NULL,
+  email varchar NOT NULL,
+  phone varchar,
+  age int,
+  user_status userStatus DEFAULT 'Active',
+  deleted_at timestamptz
+);
+
+CREATE UNIQUE INDEX users_email_key ON users (lower(email)) WHERE deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX users_search_idx ON users
+  USING GIN (to_tsvector('simple', firstName || ' ' || lastName || ' ' || email));
+
+CREATE INDEX users_search_trgm_idx ON users
+  USING GIN ((firstName || ' ' || lastName || ' ' || email) gin_trgm_ops);
+
+CREATE TYPE userRole AS ENUM ('admin', 'support');
+
+CREATE TABLE user_roles (
+  userId int NOT NULL REFERENCES users (userId) ON DELETE CASCADE,
+  role userRole NOT NULL,
+  PRIMARY KEY (userId, role)
+);
+
+CREATE TABLE user_credentials (
+  userId int PRIMARY KEY REFERENCES users (userId) ON DELETE CASCADE,
+  password_hash text NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE refresh_tokens (
+  token_hash bytea PRIMARY KEY,
+  userId int NOT NULL REFERENCES users (userId) ON DELETE CASCADE,
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refresh_tokens_userid_idx ON refresh_tokens (userId);
+
+CREATE TABLE revoked_tokens (
+  jti text PRIMARY KEY,
+  expires_at timestamptz NOT NULL
+);
+
+CREATE TYPE auditAction AS ENUM ('create', 'update', 'delete', 'restore');
+
+-- audit events outlive purged users, so userId is not a foreign key
+CREATE TABLE audit_events (
+  id bigserial PRIMARY KEY,
+  userId int NOT NULL,
+  action auditAction NOT NULL,
+  actor_id int,
+  request_id text,
+  before jsonb,
+  after jsonb,
+  changes jsonb NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_userid_idx ON audit_events (userId, id);
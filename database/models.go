@@ -11,6 +11,136 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Auditaction string
+
+const (
+	AuditactionCreate  Auditaction = "create"
+	AuditactionUpdate  Auditaction = "update"
+	AuditactionDelete  Auditaction = "delete"
+	AuditactionRestore Auditaction = "restore"
+	AuditactionPurge   Auditaction = "purge"
+)
+
+func (e *Auditaction) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = Auditaction(s)
+	case string:
+		*e = Auditaction(s)
+	default:
+		return fmt.Errorf("unsupported scan type for Auditaction: %T", src)
+	}
+	return nil
+}
+
+type NullAuditaction struct {
+	Auditaction Auditaction
+	Valid       bool // Valid is true if Auditaction is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAuditaction) Scan(value interface{}) error {
+	if value == nil {
+		ns.Auditaction, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.Auditaction.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAuditaction) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.Auditaction), nil
+}
+
+type Deliverystatus string
+
+const (
+	DeliverystatusPending   Deliverystatus = "pending"
+	DeliverystatusDelivered Deliverystatus = "delivered"
+	DeliverystatusDead      Deliverystatus = "dead"
+)
+
+func (e *Deliverystatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = Deliverystatus(s)
+	case string:
+		*e = Deliverystatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for Deliverystatus: %T", src)
+	}
+	return nil
+}
+
+type NullDeliverystatus struct {
+	Deliverystatus Deliverystatus
+	Valid          bool // Valid is true if Deliverystatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDeliverystatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.Deliverystatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.Deliverystatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDeliverystatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.Deliverystatus), nil
+}
+
+type Userrole string
+
+const (
+	UserroleAdmin   Userrole = "admin"
+	UserroleSupport Userrole = "support"
+)
+
+func (e *Userrole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = Userrole(s)
+	case string:
+		*e = Userrole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for Userrole: %T", src)
+	}
+	return nil
+}
+
+type NullUserrole struct {
+	Userrole Userrole
+	Valid    bool // Valid is true if Userrole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUserrole) Scan(value interface{}) error {
+	if value == nil {
+		ns.Userrole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.Userrole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUserrole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.Userrole), nil
+}
+
 type Userstatus string
 
 const (
@@ -53,6 +183,39 @@ func (ns NullUserstatus) Value() (driver.Value, error) {
 	return string(ns.Userstatus), nil
 }
 
+type AuditEvent struct {
+	ID        int64
+	Userid    int32
+	Action    Auditaction
+	ActorID   pgtype.Int4
+	RequestID pgtype.Text
+	Before    []byte
+	After     []byte
+	Changes   []byte
+	CreatedAt pgtype.Timestamptz
+	Seq       pgtype.Int8
+}
+
+type RateLimit struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt pgtype.Timestamptz
+	FullAt    pgtype.Timestamptz
+}
+
+type RefreshToken struct {
+	TokenHash []byte
+	Userid    int32
+	ExpiresAt pgtype.Timestamptz
+	RevokedAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type RevokedToken struct {
+	Jti       string
+	ExpiresAt pgtype.Timestamptz
+}
+
 type User struct {
 	Userid     int32
 	Firstname  string
@@ -61,4 +224,48 @@ type User struct {
 	Phone      pgtype.Text
 	Age        pgtype.Int4
 	UserStatus NullUserstatus
+	DeletedAt  pgtype.Timestamptz
+	Version    int32
+}
+
+type UserCredential struct {
+	Userid       int32
+	PasswordHash string
+	UpdatedAt    pgtype.Timestamptz
+}
+
+type UserRole struct {
+	Userid int32
+	Role   Userrole
+}
+
+type Webhook struct {
+	ID        int32
+	Url       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt pgtype.Timestamptz
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int32
+	EventID        int64
+	Status         Deliverystatus
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	DeliveredAt    pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
+
+type WebhookOutbox struct {
+	ID           int64
+	EventType    string
+	Userid       int32
+	Payload      []byte
+	DispatchedAt pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
 }
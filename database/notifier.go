@@ -0,0 +1,45 @@
+package database
+
+import "sync"
+
+// Notifier wakes its subscribers when user events are recorded.
+type Notifier interface {
+	// Subscribe returns a channel that receives a value after notifications, and
+	// a function to unsubscribe. Notifications arriving while the subscriber is
+	// busy are merged into one.
+	Subscribe() (<-chan struct{}, func())
+}
+
+// Broadcaster is a Notifier woken by calling Notify. The zero value is ready to use.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func (b *Broadcaster) Subscribe() (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = map[chan struct{}]struct{}{}
+	}
+	b.subscribers[wake] = struct{}{}
+	b.mu.Unlock()
+
+	return wake, func() {
+		b.mu.Lock()
+		delete(b.subscribers, wake)
+		b.mu.Unlock()
+	}
+}
+
+// Notify wakes every subscriber.
+func (b *Broadcaster) Notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for wake := range b.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -2,9 +2,58 @@ package database
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Querier is the storage of the service. Queries implements it on Postgres,
+// the memory and sqlite packages without a database server.
+//
+// Implementations report missing rows with pgx.ErrNoRows and unique
+// violations with a *pgconn.PgError carrying the constraint name, like
+// Postgres does, so that callers handle every backend alike.
 type Querier interface {
+	// ExecTx runs fn with a Querier bound to a single transaction.
+	ExecTx(ctx context.Context, fn func(Querier) error) error
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	UpdateUser(ctx context.Context, arg UpdateUserParams) error
+	GetUser(ctx context.Context, arg GetUserParams) (User, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error)
+	RestoreUser(ctx context.Context, userid int32) (User, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]User, error)
+	ListUsersPage(ctx context.Context, arg ListUsersPageParams) ([]User, error)
+	CountUsers(ctx context.Context, arg ListUsersFilter) (int64, error)
+	ExportUsers(ctx context.Context, arg ExportUsersParams, fn func(User) error) error
+	SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error)
+	ListUserRoles(ctx context.Context, userid int32) ([]Userrole, error)
+	SetUserRoles(ctx context.Context, arg SetUserRolesParams) error
+	GetCredential(ctx context.Context, userid int32) (UserCredential, error)
+	GetCredentialByEmail(ctx context.Context, email string) (GetCredentialByEmailRow, error)
+	UpsertCredential(ctx context.Context, arg UpsertCredentialParams) error
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error
+	GetRefreshToken(ctx context.Context, tokenHash []byte) (RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash []byte) (int32, error)
+	RevokeUserRefreshTokens(ctx context.Context, userid int32) (int64, error)
+	RevokeAccessToken(ctx context.Context, arg RevokeAccessTokenParams) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context) (int64, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error
+	ListUserEvents(ctx context.Context, arg ListUserEventsParams) ([]ListUserEventsRow, error)
+	LatestUserEventID(ctx context.Context) (int64, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	ImportUsers(ctx context.Context, arg []ImportUsersParams) (int64, error)
+	ListUsersByEmails(ctx context.Context, emails []string) ([]User, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	GetWebhook(ctx context.Context, id int32) (Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error)
+	DeleteWebhook(ctx context.Context, id int32) (int64, error)
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error
+	FanOutWebhookEvents(ctx context.Context, maxEvents int32) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]ListWebhookDeliveriesRow, error)
+	RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error)
+	DeleteFinishedWebhookEvents(ctx context.Context, dispatchedBefore pgtype.Timestamptz) (int64, error)
 }
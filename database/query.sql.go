@@ -11,13 +11,144 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+  UPDATE webhook_deliveries
+    set attempts = attempts + 1,
+    next_attempt_at = now() + make_interval(secs => $1::float8)
+  WHERE webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING webhook_deliveries.id, webhook_deliveries.webhook_id, webhook_deliveries.event_id, webhook_deliveries.attempts
+)
+SELECT c.id, c.attempts, w.url, w.secret, o.id AS event_id, o.event_type, o.payload, o.created_at AS event_created_at
+FROM claimed c
+JOIN webhooks w ON w.id = c.webhook_id
+JOIN webhook_outbox o ON o.id = c.event_id
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseSeconds float64
+	MaxResults   int32
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID             int64
+	Attempts       int32
+	Url            string
+	Secret         string
+	EventID        int64
+	EventType      string
+	Payload        []byte
+	EventCreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.LeaseSeconds, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.EventCreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+  userId, action, actor_id, request_id, before, after, changes
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+`
+
+type CreateAuditEventParams struct {
+	Userid    int32
+	Action    Auditaction
+	ActorID   pgtype.Int4
+	RequestID pgtype.Text
+	Before    []byte
+	After     []byte
+	Changes   []byte
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.Exec(ctx, createAuditEvent,
+		arg.Userid,
+		arg.Action,
+		arg.ActorID,
+		arg.RequestID,
+		arg.Before,
+		arg.After,
+		arg.Changes,
+	)
+	return err
+}
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :exec
+INSERT INTO webhook_outbox (
+  event_type, userId, payload
+) VALUES (
+  $1, $2, $3
+)
+`
+
+type CreateOutboxEventParams struct {
+	EventType string
+	Userid    int32
+	Payload   []byte
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error {
+	_, err := q.db.Exec(ctx, createOutboxEvent, arg.EventType, arg.Userid, arg.Payload)
+	return err
+}
+
+const createRefreshToken = `-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (token_hash, userId, expires_at)
+VALUES ($1, $2, $3)
+`
+
+type CreateRefreshTokenParams struct {
+	TokenHash []byte
+	Userid    int32
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error {
+	_, err := q.db.Exec(ctx, createRefreshToken, arg.TokenHash, arg.Userid, arg.ExpiresAt)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
   firstName, lastName, email, phone, age, user_status
 ) VALUES (
   $1, $2, $3, $4, $5, $6
 )
-RETURNING userid, firstname, lastname, email, phone, age, user_status
+RETURNING userid, firstname, lastname, email, phone, age, user_status, deleted_at, version
 `
 
 type CreateUserParams struct {
@@ -47,27 +178,218 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Phone,
 		&i.Age,
 		&i.UserStatus,
+		&i.DeletedAt,
+		&i.Version,
 	)
 	return i, err
 }
 
-const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users
-WHERE userId = $1
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (
+  url, secret, events, active
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING id, url, secret, events, active, created_at
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, userid int32) error {
-	_, err := q.db.Exec(ctx, deleteUser, userid)
-	return err
+type CreateWebhookParams struct {
+	Url    string
+	Secret string
+	Events []string
+	Active bool
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, createWebhook,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+		arg.Active,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredTokens = `-- name: DeleteExpiredTokens :one
+WITH expired_refresh AS (
+  DELETE FROM refresh_tokens WHERE refresh_tokens.expires_at < now()
+  RETURNING 1
+), expired_revoked AS (
+  DELETE FROM revoked_tokens WHERE revoked_tokens.expires_at < now()
+  RETURNING 1
+)
+SELECT ((SELECT count(*) FROM expired_refresh) + (SELECT count(*) FROM expired_revoked))::bigint AS deleted
+`
+
+func (q *Queries) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, deleteExpiredTokens)
+	var deleted int64
+	err := row.Scan(&deleted)
+	return deleted, err
+}
+
+const deleteFinishedWebhookEvents = `-- name: DeleteFinishedWebhookEvents :execrows
+DELETE FROM webhook_outbox o
+WHERE o.dispatched_at < $1
+  AND NOT EXISTS (
+    SELECT 1 FROM webhook_deliveries d
+    WHERE d.event_id = o.id AND d.status = 'pending'
+  )
+`
+
+// Removes the events dispatched before the cutoff that have no
+// pending delivery left. Their deliveries are removed with them.
+func (q *Queries) DeleteFinishedWebhookEvents(ctx context.Context, dispatchedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteFinishedWebhookEvents, dispatchedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteFullRateLimits = `-- name: DeleteFullRateLimits :execrows
+DELETE FROM rate_limits WHERE full_at < now()
+`
+
+func (q *Queries) DeleteFullRateLimits(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteFullRateLimits)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+UPDATE users
+  set deleted_at = now(), version = version + 1
+WHERE userId = $1 AND version = $2 AND deleted_at IS NULL
+`
+
+type DeleteUserParams struct {
+	Userid  int32
+	Version int32
+}
+
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, arg.Userid, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const fanOutWebhookEvents = `-- name: FanOutWebhookEvents :execrows
+WITH events AS (
+  UPDATE webhook_outbox
+    set dispatched_at = now()
+  WHERE id IN (
+    SELECT id FROM webhook_outbox
+    WHERE dispatched_at IS NULL
+    ORDER BY id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING id, event_type, created_at
+)
+INSERT INTO webhook_deliveries (webhook_id, event_id)
+SELECT w.id, e.id FROM events e
+JOIN webhooks w ON w.active AND e.event_type = ANY(w.events) AND w.created_at <= e.created_at
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) FanOutWebhookEvents(ctx context.Context, maxEvents int32) (int64, error) {
+	result, err := q.db.Exec(ctx, fanOutWebhookEvents, maxEvents)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getCredential = `-- name: GetCredential :one
+SELECT user_credentials.userid, user_credentials.password_hash, user_credentials.updated_at FROM user_credentials
+JOIN users ON users.userId = user_credentials.userId
+WHERE user_credentials.userId = $1 AND users.deleted_at IS NULL
+`
+
+func (q *Queries) GetCredential(ctx context.Context, userid int32) (UserCredential, error) {
+	row := q.db.QueryRow(ctx, getCredential, userid)
+	var i UserCredential
+	err := row.Scan(&i.Userid, &i.PasswordHash, &i.UpdatedAt)
+	return i, err
+}
+
+const getCredentialByEmail = `-- name: GetCredentialByEmail :one
+SELECT users.userId, users.user_status, user_credentials.password_hash
+FROM users
+JOIN user_credentials ON user_credentials.userId = users.userId
+WHERE lower(users.email) = lower($1::text) AND users.deleted_at IS NULL
+`
+
+type GetCredentialByEmailRow struct {
+	Userid       int32
+	UserStatus   NullUserstatus
+	PasswordHash string
+}
+
+func (q *Queries) GetCredentialByEmail(ctx context.Context, email string) (GetCredentialByEmailRow, error) {
+	row := q.db.QueryRow(ctx, getCredentialByEmail, email)
+	var i GetCredentialByEmailRow
+	err := row.Scan(&i.Userid, &i.UserStatus, &i.PasswordHash)
+	return i, err
+}
+
+const getRefreshToken = `-- name: GetRefreshToken :one
+SELECT token_hash, userid, expires_at, revoked_at, created_at FROM refresh_tokens
+WHERE token_hash = $1
+`
+
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash []byte) (RefreshToken, error) {
+	row := q.db.QueryRow(ctx, getRefreshToken, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.Userid,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT userid, firstname, lastname, email, phone, age, user_status FROM users
-WHERE userId = $1 LIMIT 1
+SELECT userid, firstname, lastname, email, phone, age, user_status, deleted_at, version FROM users
+WHERE userId = $1 AND (deleted_at IS NULL OR $2::bool) LIMIT 1
 `
 
-func (q *Queries) GetUser(ctx context.Context, userid int32) (User, error) {
-	row := q.db.QueryRow(ctx, getUser, userid)
+type GetUserParams struct {
+	Userid         int32
+	IncludeDeleted bool
+}
+
+func (q *Queries) GetUser(ctx context.Context, arg GetUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, arg.Userid, arg.IncludeDeleted)
 	var i User
 	err := row.Scan(
 		&i.Userid,
@@ -77,12 +399,186 @@ func (q *Queries) GetUser(ctx context.Context, userid int32) (User, error) {
 		&i.Phone,
 		&i.Age,
 		&i.UserStatus,
+		&i.DeletedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, url, secret, events, active, created_at FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int32) (Webhook, error) {
+	row := q.db.QueryRow(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
+type ImportUsersParams struct {
+	Firstname  string
+	Lastname   string
+	Email      string
+	Phone      pgtype.Text
+	Age        pgtype.Int4
+	UserStatus NullUserstatus
+}
+
+const isAccessTokenRevoked = `-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+  SELECT 1 FROM revoked_tokens WHERE jti = $1
+)
+`
+
+func (q *Queries) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	row := q.db.QueryRow(ctx, isAccessTokenRevoked, jti)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const latestUserEventID = `-- name: LatestUserEventID :one
+SELECT COALESCE(max(seq), 0)::bigint AS id FROM audit_events
+`
+
+func (q *Queries) LatestUserEventID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, latestUserEventID)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, userid, action, actor_id, request_id, before, after, changes, created_at, seq FROM audit_events
+WHERE userId = $1 AND ($2::bigint = 0 OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListAuditEventsParams struct {
+	Userid     int32
+	BeforeID   int64
+	MaxResults int32
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents, arg.Userid, arg.BeforeID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Userid,
+			&i.Action,
+			&i.ActorID,
+			&i.RequestID,
+			&i.Before,
+			&i.After,
+			&i.Changes,
+			&i.CreatedAt,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserEvents = `-- name: ListUserEvents :many
+SELECT seq::bigint AS seq, userId, action, changes, created_at FROM audit_events
+WHERE seq > $1::bigint
+ORDER BY seq
+LIMIT $2
+`
+
+type ListUserEventsParams struct {
+	AfterID    int64
+	MaxResults int32
+}
+
+type ListUserEventsRow struct {
+	Seq       int64
+	Userid    int32
+	Action    Auditaction
+	Changes   []byte
+	CreatedAt pgtype.Timestamptz
+}
+
+// The event id of a stream is seq, numbered in commit order. Events of the
+// transactions still committing have no seq yet.
+func (q *Queries) ListUserEvents(ctx context.Context, arg ListUserEventsParams) ([]ListUserEventsRow, error) {
+	rows, err := q.db.Query(ctx, listUserEvents, arg.AfterID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserEventsRow
+	for rows.Next() {
+		var i ListUserEventsRow
+		if err := rows.Scan(
+			&i.Seq,
+			&i.Userid,
+			&i.Action,
+			&i.Changes,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserRoles = `-- name: ListUserRoles :many
+SELECT user_roles.role FROM user_roles
+JOIN users ON users.userId = user_roles.userId
+WHERE user_roles.userId = $1 AND users.deleted_at IS NULL
+ORDER BY user_roles.role
+`
+
+func (q *Queries) ListUserRoles(ctx context.Context, userid int32) ([]Userrole, error) {
+	rows, err := q.db.Query(ctx, listUserRoles, userid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Userrole
+	for rows.Next() {
+		var role Userrole
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		items = append(items, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT userid, firstname, lastname, email, phone, age, user_status FROM users
+SELECT userid, firstname, lastname, email, phone, age, user_status, deleted_at, version FROM users
+WHERE deleted_at IS NULL
 ORDER BY firstName
 `
 
@@ -103,6 +599,8 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.Phone,
 			&i.Age,
 			&i.UserStatus,
+			&i.DeletedAt,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -114,7 +612,408 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
-const updateUser = `-- name: UpdateUser :exec
+const listUsersByEmails = `-- name: ListUsersByEmails :many
+SELECT userid, firstname, lastname, email, phone, age, user_status, deleted_at, version FROM users
+WHERE lower(email) = ANY($1::text[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) ListUsersByEmails(ctx context.Context, emails []string) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersByEmails, emails)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Userid,
+			&i.Firstname,
+			&i.Lastname,
+			&i.Email,
+			&i.Phone,
+			&i.Age,
+			&i.UserStatus,
+			&i.DeletedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT d.id, d.webhook_id, d.event_id, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at, o.event_type FROM webhook_deliveries d
+JOIN webhook_outbox o ON o.id = d.event_id
+WHERE d.webhook_id = $1 AND ($2::bigint = 0 OR d.id < $2)
+ORDER BY d.id DESC
+LIMIT $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID  int32
+	BeforeID   int64
+	MaxResults int32
+}
+
+type ListWebhookDeliveriesRow struct {
+	ID             int64
+	WebhookID      int32
+	EventID        int64
+	Status         Deliverystatus
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	DeliveredAt    pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+	EventType      string
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]ListWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, arg.WebhookID, arg.BeforeID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWebhookDeliveriesRow
+	for rows.Next() {
+		var i ListWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+			&i.EventType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, url, secret, events, active, created_at FROM webhooks
+ORDER BY id
+`
+
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < $1
+RETURNING userid, firstname, lastname, email, phone, age, user_status, deleted_at, version
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]User, error) {
+	rows, err := q.db.Query(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Userid,
+			&i.Firstname,
+			&i.Lastname,
+			&i.Email,
+			&i.Phone,
+			&i.Age,
+			&i.UserStatus,
+			&i.DeletedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+  set status = $1,
+  next_attempt_at = $2,
+  last_status_code = $3,
+  last_error = $4,
+  delivered_at = CASE WHEN $1 = 'delivered'::deliveryStatus THEN now() END
+WHERE id = $5
+`
+
+type RecordWebhookAttemptParams struct {
+	Status         Deliverystatus
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	ID             int64
+}
+
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	_, err := q.db.Exec(ctx, recordWebhookAttempt,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+  set deleted_at = NULL, version = version + 1
+WHERE userId = $1 AND deleted_at IS NOT NULL
+RETURNING userid, firstname, lastname, email, phone, age, user_status, deleted_at, version
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, userid int32) (User, error) {
+	row := q.db.QueryRow(ctx, restoreUser, userid)
+	var i User
+	err := row.Scan(
+		&i.Userid,
+		&i.Firstname,
+		&i.Lastname,
+		&i.Email,
+		&i.Phone,
+		&i.Age,
+		&i.UserStatus,
+		&i.DeletedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+  set status = 'pending',
+  attempts = 0,
+  next_attempt_at = now()
+WHERE id = $1 AND webhook_id = $2 AND status = 'dead'
+RETURNING id, webhook_id, event_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+`
+
+type RetryWebhookDeliveryParams struct {
+	ID        int64
+	WebhookID int32
+}
+
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, retryWebhookDelivery, arg.ID, arg.WebhookID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.EventID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const revokeAccessToken = `-- name: RevokeAccessToken :exec
+INSERT INTO revoked_tokens (jti, expires_at)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type RevokeAccessTokenParams struct {
+	Jti       string
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeAccessToken(ctx context.Context, arg RevokeAccessTokenParams) error {
+	_, err := q.db.Exec(ctx, revokeAccessToken, arg.Jti, arg.ExpiresAt)
+	return err
+}
+
+const revokeRefreshToken = `-- name: RevokeRefreshToken :one
+UPDATE refresh_tokens
+  set revoked_at = now()
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > now()
+RETURNING userId
+`
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, tokenHash []byte) (int32, error) {
+	row := q.db.QueryRow(ctx, revokeRefreshToken, tokenHash)
+	var userid int32
+	err := row.Scan(&userid)
+	return userid, err
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+  set revoked_at = now()
+WHERE userId = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userid int32) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserRefreshTokens, userid)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT users.userid, users.firstname, users.lastname, users.email, users.phone, users.age, users.user_status, users.deleted_at, users.version, (
+  ts_rank(
+    to_tsvector('simple', firstName || ' ' || lastName || ' ' || email),
+    plainto_tsquery('simple', $1::text)
+  ) + word_similarity($1::text, firstName || ' ' || lastName || ' ' || email)
+)::real AS score
+FROM users
+WHERE deleted_at IS NULL
+  AND (
+    to_tsvector('simple', firstName || ' ' || lastName || ' ' || email) @@ plainto_tsquery('simple', $1::text)
+    OR $1::text <% (firstName || ' ' || lastName || ' ' || email)
+  )
+ORDER BY score DESC, userId
+LIMIT $2
+`
+
+type SearchUsersParams struct {
+	Query      string
+	MaxResults int32
+}
+
+type SearchUsersRow struct {
+	Userid     int32
+	Firstname  string
+	Lastname   string
+	Email      string
+	Phone      pgtype.Text
+	Age        pgtype.Int4
+	UserStatus NullUserstatus
+	DeletedAt  pgtype.Timestamptz
+	Version    int32
+	Score      float32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.Query(ctx, searchUsers, arg.Query, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUsersRow
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.Userid,
+			&i.Firstname,
+			&i.Lastname,
+			&i.Email,
+			&i.Phone,
+			&i.Age,
+			&i.UserStatus,
+			&i.DeletedAt,
+			&i.Version,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserRoles = `-- name: SetUserRoles :exec
+WITH removed AS (
+  DELETE FROM user_roles
+  WHERE user_roles.userId = $1 AND NOT (user_roles.role::text = ANY($2::text[]))
+)
+INSERT INTO user_roles (userId, role)
+SELECT $1, unnest($2::text[])::userRole
+ON CONFLICT DO NOTHING
+`
+
+type SetUserRolesParams struct {
+	Userid int32
+	Roles  []string
+}
+
+func (q *Queries) SetUserRoles(ctx context.Context, arg SetUserRolesParams) error {
+	_, err := q.db.Exec(ctx, setUserRoles, arg.Userid, arg.Roles)
+	return err
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+SELECT remaining::float8 AS remaining, allowed::boolean AS allowed
+FROM take_rate_limit_token($1::text, $2::float8, $3::float8)
+`
+
+type TakeRateLimitTokenParams struct {
+	BucketKey string
+	Burst     float64
+	Rate      float64
+}
+
+type TakeRateLimitTokenRow struct {
+	Remaining float64
+	Allowed   bool
+}
+
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error) {
+	row := q.db.QueryRow(ctx, takeRateLimitToken, arg.BucketKey, arg.Burst, arg.Rate)
+	var i TakeRateLimitTokenRow
+	err := row.Scan(&i.Remaining, &i.Allowed)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
 UPDATE users
   set 
   firstName = $2,
@@ -122,8 +1021,10 @@ UPDATE users
   email = $4,
   phone = $5,
   age = $6,
-  user_status = $7
-WHERE userId = $1
+  user_status = $7,
+  version = version + 1
+WHERE userId = $1 AND version = $8 AND deleted_at IS NULL
+RETURNING userid, firstname, lastname, email, phone, age, user_status, deleted_at, version
 `
 
 type UpdateUserParams struct {
@@ -134,10 +1035,11 @@ type UpdateUserParams struct {
 	Phone      pgtype.Text
 	Age        pgtype.Int4
 	UserStatus NullUserstatus
+	Version    int32
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
-	_, err := q.db.Exec(ctx, updateUser,
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUser,
 		arg.Userid,
 		arg.Firstname,
 		arg.Lastname,
@@ -145,6 +1047,71 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 		arg.Phone,
 		arg.Age,
 		arg.UserStatus,
+		arg.Version,
 	)
+	var i User
+	err := row.Scan(
+		&i.Userid,
+		&i.Firstname,
+		&i.Lastname,
+		&i.Email,
+		&i.Phone,
+		&i.Age,
+		&i.UserStatus,
+		&i.DeletedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks
+  set url = $2,
+  events = $3,
+  active = $4
+WHERE id = $1
+RETURNING id, url, secret, events, active, created_at
+`
+
+type UpdateWebhookParams struct {
+	ID     int32
+	Url    string
+	Events []string
+	Active bool
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, updateWebhook,
+		arg.ID,
+		arg.Url,
+		arg.Events,
+		arg.Active,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const upsertCredential = `-- name: UpsertCredential :exec
+INSERT INTO user_credentials (userId, password_hash)
+VALUES ($1, $2)
+ON CONFLICT (userId) DO UPDATE
+  SET password_hash = EXCLUDED.password_hash, updated_at = now()
+`
+
+type UpsertCredentialParams struct {
+	Userid       int32
+	PasswordHash string
+}
+
+func (q *Queries) UpsertCredential(ctx context.Context, arg UpsertCredentialParams) error {
+	_, err := q.db.Exec(ctx, upsertCredential, arg.Userid, arg.PasswordHash)
 	return err
 }
@@ -0,0 +1,15 @@
+package database
+
+import "strings"
+
+// QueryName returns the name of a query generated by sqlc, from its
+// "-- name: GetUser :one" first line, or "other" for the statements without
+// one, like those of the transactions.
+func QueryName(sql string) string {
+	line, ok := strings.CutPrefix(sql, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(line, " ")
+	return name
+}
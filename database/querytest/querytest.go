@@ -0,0 +1,545 @@
+// Package querytest checks that an implementation of database.Querier
+// behaves like the Postgres one, so that the service runs alike on every
+// storage backend.
+package querytest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Run runs the conformance tests, on a new empty storage per test.
+func Run(t *testing.T, open func(t *testing.T) database.Querier) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, q database.Querier)
+	}{
+		{"Users", testUsers},
+		{"UniqueEmail", testUniqueEmail},
+		{"SoftDelete", testSoftDelete},
+		{"ListUsersPage", testListUsersPage},
+		{"SearchUsers", testSearchUsers},
+		{"ImportUsers", testImportUsers},
+		{"Transactions", testTransactions},
+		{"Notifications", testNotifications},
+		{"Credentials", testCredentials},
+		{"Webhooks", testWebhooks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, q database.Querier, first, email string, age int32) database.User {
+	t.Helper()
+	user, err := q.CreateUser(t.Context(), database.CreateUserParams{
+		Firstname:  first,
+		Lastname:   "Doe",
+		Email:      email,
+		Age:        pgtype.Int4{Int32: age, Valid: true},
+		UserStatus: database.NullUserstatus{Userstatus: database.UserstatusActive, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == constraint
+}
+
+func testUsers(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	created := createUser(t, q, "Jane", "jane@example.com", 30)
+	if created.Userid == 0 || created.Version != 1 || created.DeletedAt.Valid {
+		t.Errorf("Test Failure! Unexpected created user %+v", created)
+	}
+
+	user, err := q.GetUser(ctx, database.GetUserParams{Userid: created.Userid})
+	if err != nil || user != created {
+		t.Errorf("Test Failure! Expected %+v, got %+v (%v)", created, user, err)
+	}
+	_, err = q.GetUser(ctx, database.GetUserParams{Userid: created.Userid + 1})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Expected pgx.ErrNoRows for a missing user, got %v", err)
+	}
+
+	updated, err := q.UpdateUser(ctx, database.UpdateUserParams{
+		Userid:    created.Userid,
+		Firstname: "Janet",
+		Lastname:  "Doe",
+		Email:     "janet@example.com",
+		Phone:     pgtype.Text{String: "555-0100", Valid: true},
+		Version:   created.Version,
+	})
+	if err != nil || updated.Firstname != "Janet" || updated.Version != 2 || updated.Age.Valid || updated.UserStatus.Valid {
+		t.Errorf("Test Failure! Unexpected updated user %+v (%v)", updated, err)
+	}
+	_, err = q.UpdateUser(ctx, database.UpdateUserParams{Userid: created.Userid, Email: "stale@example.com", Version: created.Version})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Expected pgx.ErrNoRows for a stale version, got %v", err)
+	}
+
+	createUser(t, q, "Adam", "adam@example.com", 40)
+	users, err := q.ListUsers(ctx)
+	if err != nil || len(users) != 2 || users[0].Firstname != "Adam" {
+		t.Errorf("Test Failure! Expected users ordered by first name, got %+v (%v)", users, err)
+	}
+}
+
+func testUniqueEmail(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	jane := createUser(t, q, "Jane", "jane@example.com", 30)
+
+	_, err := q.CreateUser(ctx, database.CreateUserParams{Firstname: "Other", Lastname: "Doe", Email: "JANE@example.com"})
+	if !isUniqueViolation(err, "users_email_key") {
+		t.Errorf("Test Failure! Expected a unique violation of users_email_key, got %v", err)
+	}
+
+	adam := createUser(t, q, "Adam", "adam@example.com", 40)
+	_, err = q.UpdateUser(ctx, database.UpdateUserParams{Userid: adam.Userid, Firstname: "Adam", Email: "jane@example.com", Version: adam.Version})
+	if !isUniqueViolation(err, "users_email_key") {
+		t.Errorf("Test Failure! Expected a unique violation on update, got %v", err)
+	}
+
+	// the email of a deleted user can be reused
+	_, err = q.DeleteUser(ctx, database.DeleteUserParams{Userid: jane.Userid, Version: jane.Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUser(t, q, "Jane", "jane@example.com", 31)
+	_, err = q.RestoreUser(ctx, jane.Userid)
+	if !isUniqueViolation(err, "users_email_key") {
+		t.Errorf("Test Failure! Expected a unique violation on restore, got %v", err)
+	}
+}
+
+func testSoftDelete(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	user := createUser(t, q, "Jane", "jane@example.com", 30)
+	err := q.SetUserRoles(ctx, database.SetUserRolesParams{Userid: user.Userid, Roles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := q.DeleteUser(ctx, database.DeleteUserParams{Userid: user.Userid, Version: user.Version + 1})
+	if err != nil || deleted != 0 {
+		t.Errorf("Test Failure! Deleted with a stale version: %d (%v)", deleted, err)
+	}
+	deleted, err = q.DeleteUser(ctx, database.DeleteUserParams{Userid: user.Userid, Version: user.Version})
+	if err != nil || deleted != 1 {
+		t.Fatalf("Test Failure! Expected 1 deleted user, got %d (%v)", deleted, err)
+	}
+
+	_, err = q.GetUser(ctx, database.GetUserParams{Userid: user.Userid})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Deleted user still found: %v", err)
+	}
+	got, err := q.GetUser(ctx, database.GetUserParams{Userid: user.Userid, IncludeDeleted: true})
+	if err != nil || !got.DeletedAt.Valid || got.Version != 2 {
+		t.Errorf("Test Failure! Unexpected deleted user %+v (%v)", got, err)
+	}
+	roles, err := q.ListUserRoles(ctx, user.Userid)
+	if err != nil || len(roles) != 0 {
+		t.Errorf("Test Failure! Deleted user has roles %v (%v)", roles, err)
+	}
+
+	restored, err := q.RestoreUser(ctx, user.Userid)
+	if err != nil || restored.DeletedAt.Valid || restored.Version != 3 {
+		t.Errorf("Test Failure! Unexpected restored user %+v (%v)", restored, err)
+	}
+	_, err = q.RestoreUser(ctx, user.Userid)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Restored a user that is not deleted: %v", err)
+	}
+
+	_, err = q.DeleteUser(ctx, database.DeleteUserParams{Userid: user.Userid, Version: restored.Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	purged, err := q.PurgeDeletedUsers(ctx, pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true})
+	if err != nil || len(purged) != 1 || purged[0].Userid != user.Userid {
+		t.Errorf("Test Failure! Expected 1 purged user, got %+v (%v)", purged, err)
+	}
+	err = q.CreateAuditEvent(ctx, database.CreateAuditEventParams{Userid: user.Userid, Action: database.AuditactionPurge, Changes: []byte("{}")})
+	if err != nil {
+		t.Errorf("Test Failure! Purge audit event not recorded: %v", err)
+	}
+	_, err = q.GetUser(ctx, database.GetUserParams{Userid: user.Userid, IncludeDeleted: true})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Purged user still found: %v", err)
+	}
+}
+
+func testListUsersPage(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	for i, age := range []int32{30, 25, 40, 25, 35} {
+		createUser(t, q, fmt.Sprintf("User%d", i), fmt.Sprintf("user%d@example.com", i), age)
+	}
+	createUser(t, q, "Ann", "ann@other.org", 9)
+
+	filter := database.ListUsersFilter{EmailPrefix: pgtype.Text{String: "USER", Valid: true}}
+	count, err := q.CountUsers(ctx, filter)
+	if err != nil || count != 5 {
+		t.Errorf("Test Failure! Expected 5 matching users, got %d (%v)", count, err)
+	}
+
+	// pages of 2 by descending age, following the keyset of the last row
+	var ages []int32
+	params := database.ListUsersPageParams{Filter: filter, SortColumn: "age", Descending: true, Limit: 2}
+	for range 4 {
+		page, err := q.ListUsersPage(ctx, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, u := range page {
+			ages = append(ages, u.Age.Int32)
+		}
+		key := database.SortKey(page[len(page)-1], "age")
+		params.After = &key
+	}
+	if fmt.Sprint(ages) != "[40 35 30 25 25]" {
+		t.Errorf("Test Failure! Expected ages [40 35 30 25 25], got %v", ages)
+	}
+
+	page, err := q.ListUsersPage(ctx, database.ListUsersPageParams{
+		Filter:     database.ListUsersFilter{MinAge: pgtype.Int4{Int32: 26, Valid: true}},
+		SortColumn: "firstName",
+		Limit:      10,
+		Offset:     1,
+	})
+	if err != nil || len(page) != 2 || page[0].Firstname != "User2" {
+		t.Errorf("Test Failure! Unexpected offset page %+v (%v)", page, err)
+	}
+
+	var exported []string
+	err = q.ExportUsers(ctx, database.ExportUsersParams{SortColumn: "email"}, func(u database.User) error {
+		exported = append(exported, u.Email)
+		return nil
+	})
+	if err != nil || len(exported) != 6 || exported[0] != "ann@other.org" {
+		t.Errorf("Test Failure! Unexpected export %v (%v)", exported, err)
+	}
+
+	_, err = q.ListUsersPage(ctx, database.ListUsersPageParams{SortColumn: "password", Limit: 1})
+	if err == nil {
+		t.Errorf("Test Failure! Expected an error for an unknown sort column")
+	}
+}
+
+func testSearchUsers(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	createUser(t, q, "Jane", "jane@example.com", 30)
+	john := createUser(t, q, "John", "johnny@example.com", 30)
+	createUser(t, q, "Adam", "adam@example.com", 30)
+
+	rows, err := q.SearchUsers(ctx, database.SearchUsersParams{Query: "john doe", MaxResults: 10})
+	if err != nil || len(rows) != 1 || rows[0].Userid != john.Userid || rows[0].Score <= 0 {
+		t.Errorf("Test Failure! Unexpected search result %+v (%v)", rows, err)
+	}
+	rows, err = q.SearchUsers(ctx, database.SearchUsersParams{Query: "doe", MaxResults: 2})
+	if err != nil || len(rows) != 2 {
+		t.Errorf("Test Failure! Expected 2 results, got %+v (%v)", rows, err)
+	}
+}
+
+func testImportUsers(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	createUser(t, q, "Jane", "jane@example.com", 30)
+
+	_, err := q.ImportUsers(ctx, []database.ImportUsersParams{
+		{Firstname: "Adam", Lastname: "Doe", Email: "adam@example.com"},
+		{Firstname: "Jane", Lastname: "Doe", Email: "Jane@Example.com"},
+	})
+	if !isUniqueViolation(err, "users_email_key") {
+		t.Errorf("Test Failure! Expected a unique violation, got %v", err)
+	}
+	count, _ := q.CountUsers(ctx, database.ListUsersFilter{})
+	if count != 1 {
+		t.Errorf("Test Failure! A failed import inserted users: %d users", count)
+	}
+
+	imported, err := q.ImportUsers(ctx, []database.ImportUsersParams{
+		{Firstname: "Adam", Lastname: "Doe", Email: "adam@example.com"},
+		{Firstname: "Eve", Lastname: "Doe", Email: "eve@example.com"},
+	})
+	if err != nil || imported != 2 {
+		t.Errorf("Test Failure! Expected 2 imported users, got %d (%v)", imported, err)
+	}
+	users, err := q.ListUsersByEmails(ctx, []string{"adam@example.com", "jane@example.com", "nobody@example.com"})
+	if err != nil || len(users) != 2 {
+		t.Errorf("Test Failure! Expected 2 users by email, got %+v (%v)", users, err)
+	}
+}
+
+func testTransactions(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	errAbort := errors.New("abort")
+
+	err := q.ExecTx(ctx, func(tx database.Querier) error {
+		createUser(t, tx, "Jane", "jane@example.com", 30)
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("Test Failure! Expected the error of fn, got %v", err)
+	}
+	count, _ := q.CountUsers(ctx, database.ListUsersFilter{})
+	if count != 0 {
+		t.Errorf("Test Failure! A rolled back transaction inserted %d users", count)
+	}
+
+	// a failed nested transaction only rolls back its own changes
+	err = q.ExecTx(ctx, func(tx database.Querier) error {
+		createUser(t, tx, "Jane", "jane@example.com", 30)
+		err := tx.ExecTx(ctx, func(nested database.Querier) error {
+			createUser(t, nested, "Adam", "adam@example.com", 40)
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			return fmt.Errorf("expected the error of the nested fn, got %v", err)
+		}
+		return tx.ExecTx(ctx, func(nested database.Querier) error {
+			createUser(t, nested, "Eve", "eve@example.com", 50)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, _ := q.ListUsers(ctx)
+	if len(users) != 2 || users[0].Firstname != "Eve" || users[1].Firstname != "Jane" {
+		t.Errorf("Test Failure! Expected Eve and Jane, got %+v", users)
+	}
+}
+
+func testNotifications(t *testing.T, q database.Querier) {
+	notifier, ok := q.(database.Notifier)
+	if !ok {
+		t.Skip("the storage does not notify user events")
+	}
+	ctx := t.Context()
+	events, unsubscribe := notifier.Subscribe()
+	defer unsubscribe()
+
+	err := q.ExecTx(ctx, func(tx database.Querier) error {
+		err := tx.CreateAuditEvent(ctx, database.CreateAuditEventParams{Userid: 1, Action: database.AuditactionCreate})
+		if err != nil {
+			return err
+		}
+		select {
+		case <-events:
+			return errors.New("notified before commit")
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Errorf("Test Failure! No notification after commit")
+	}
+
+	id, err := q.LatestUserEventID(ctx)
+	if err != nil || id == 0 {
+		t.Fatalf("Test Failure! Unexpected latest event id %d (%v)", id, err)
+	}
+	err = q.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		Userid:  2,
+		Action:  database.AuditactionUpdate,
+		Changes: []byte(`{"age":[30,31]}`),
+		ActorID: pgtype.Int4{Int32: 1, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := q.ListUserEvents(ctx, database.ListUserEventsParams{AfterID: id, MaxResults: 10})
+	if err != nil || len(after) != 1 || after[0].Seq <= id || after[0].Userid != 2 || string(after[0].Changes) != `{"age":[30,31]}` {
+		t.Errorf("Test Failure! Unexpected events %+v (%v)", after, err)
+	}
+	audit, err := q.ListAuditEvents(ctx, database.ListAuditEventsParams{Userid: 1, MaxResults: 10})
+	if err != nil || len(audit) != 1 || audit[0].Action != database.AuditactionCreate || string(audit[0].Changes) != "{}" {
+		t.Errorf("Test Failure! Unexpected audit events %+v (%v)", audit, err)
+	}
+}
+
+func testCredentials(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	user := createUser(t, q, "Jane", "jane@example.com", 30)
+
+	err := q.SetUserRoles(ctx, database.SetUserRolesParams{Userid: user.Userid, Roles: []string{"support", "admin", "admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.SetUserRoles(ctx, database.SetUserRolesParams{Userid: user.Userid, Roles: []string{"support"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err := q.ListUserRoles(ctx, user.Userid)
+	if err != nil || len(roles) != 1 || roles[0] != database.UserroleSupport {
+		t.Errorf("Test Failure! Expected the support role, got %v (%v)", roles, err)
+	}
+
+	err = q.UpsertCredential(ctx, database.UpsertCredentialParams{Userid: user.Userid, PasswordHash: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.UpsertCredential(ctx, database.UpsertCredentialParams{Userid: user.Userid, PasswordHash: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := q.GetCredentialByEmail(ctx, "JANE@example.com")
+	if err != nil || credential.Userid != user.Userid || credential.PasswordHash != "second" {
+		t.Errorf("Test Failure! Unexpected credential %+v (%v)", credential, err)
+	}
+
+	hash := []byte{1, 2, 3}
+	expires := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{TokenHash: hash, Userid: user.Userid, ExpiresAt: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{TokenHash: hash, Userid: user.Userid, ExpiresAt: expires})
+	if !isUniqueViolation(err, "refresh_tokens_pkey") {
+		t.Errorf("Test Failure! Expected a unique violation of refresh_tokens_pkey, got %v", err)
+	}
+	token, err := q.GetRefreshToken(ctx, hash)
+	if err != nil || !bytes.Equal(token.TokenHash, hash) || !token.ExpiresAt.Time.Equal(expires.Time) || token.RevokedAt.Valid {
+		t.Errorf("Test Failure! Unexpected refresh token %+v (%v)", token, err)
+	}
+	userid, err := q.RevokeRefreshToken(ctx, hash)
+	if err != nil || userid != user.Userid {
+		t.Errorf("Test Failure! Expected the token of %d revoked, got %d (%v)", user.Userid, userid, err)
+	}
+	_, err = q.RevokeRefreshToken(ctx, hash)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Revoked a token twice: %v", err)
+	}
+
+	err = q.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{Jti: "jti", ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := q.IsAccessTokenRevoked(ctx, "jti")
+	if err != nil || !revoked {
+		t.Errorf("Test Failure! Access token not revoked (%v)", err)
+	}
+	deleted, err := q.DeleteExpiredTokens(ctx)
+	if err != nil || deleted != 1 {
+		t.Errorf("Test Failure! Expected 1 expired token deleted, got %d (%v)", deleted, err)
+	}
+}
+
+func testWebhooks(t *testing.T, q database.Querier) {
+	ctx := t.Context()
+	webhook, err := q.CreateWebhook(ctx, database.CreateWebhookParams{Url: "http://example.com/hook", Secret: "secret", Events: []string{"user.created"}, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := q.CreateWebhook(ctx, database.CreateWebhookParams{Url: "http://example.com/other", Secret: "secret", Events: []string{"user.deleted"}, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks, err := q.ListWebhooks(ctx)
+	if err != nil || len(webhooks) != 2 || webhooks[0].ID != webhook.ID || len(webhooks[0].Events) != 1 {
+		t.Errorf("Test Failure! Unexpected webhooks %+v (%v)", webhooks, err)
+	}
+
+	for _, eventType := range []string{"user.created", "user.deleted", "user.created"} {
+		err = q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{EventType: eventType, Userid: 1, Payload: []byte(`{}`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	created, err := q.FanOutWebhookEvents(ctx, 2)
+	if err != nil || created != 2 {
+		t.Errorf("Test Failure! Expected 2 deliveries of the first 2 events, got %d (%v)", created, err)
+	}
+	created, err = q.FanOutWebhookEvents(ctx, 10)
+	if err != nil || created != 1 {
+		t.Errorf("Test Failure! Expected 1 delivery of the last event, got %d (%v)", created, err)
+	}
+
+	claimed, err := q.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{LeaseSeconds: 60, MaxResults: 10})
+	if err != nil || len(claimed) != 3 || claimed[0].Attempts != 1 || claimed[0].Secret != "secret" {
+		t.Fatalf("Test Failure! Unexpected claimed deliveries %+v (%v)", claimed, err)
+	}
+	again, err := q.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{LeaseSeconds: 60, MaxResults: 10})
+	if err != nil || len(again) != 0 {
+		t.Errorf("Test Failure! Leased deliveries claimed again: %+v (%v)", again, err)
+	}
+
+	for _, d := range claimed {
+		err = q.RecordWebhookAttempt(ctx, database.RecordWebhookAttemptParams{
+			ID:             d.ID,
+			Status:         database.DeliverystatusDead,
+			NextAttemptAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			LastStatusCode: pgtype.Int4{Int32: 500, Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliveries, err := q.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{WebhookID: webhook.ID, MaxResults: 10})
+	if err != nil || len(deliveries) != 2 || deliveries[0].ID < deliveries[1].ID || deliveries[0].EventType != "user.created" || deliveries[0].Status != database.DeliverystatusDead {
+		t.Fatalf("Test Failure! Unexpected deliveries %+v (%v)", deliveries, err)
+	}
+
+	retried, err := q.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{ID: deliveries[0].ID, WebhookID: webhook.ID})
+	if err != nil || retried.Status != database.DeliverystatusPending || retried.Attempts != 0 {
+		t.Errorf("Test Failure! Unexpected retried delivery %+v (%v)", retried, err)
+	}
+	_, err = q.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{ID: deliveries[0].ID, WebhookID: webhook.ID})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Retried a pending delivery: %v", err)
+	}
+
+	// only the events whose deliveries are all finished are swept
+	swept, err := q.DeleteFinishedWebhookEvents(ctx, pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true})
+	if err != nil || swept != 0 {
+		t.Errorf("Test Failure! Swept events dispatched after the cutoff: %d (%v)", swept, err)
+	}
+	swept, err = q.DeleteFinishedWebhookEvents(ctx, pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true})
+	if err != nil || swept != 2 {
+		t.Errorf("Test Failure! Expected 2 finished events swept, got %d (%v)", swept, err)
+	}
+	deliveries, err = q.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{WebhookID: webhook.ID, MaxResults: 10})
+	if err != nil || len(deliveries) != 1 || deliveries[0].ID != retried.ID {
+		t.Errorf("Test Failure! Unexpected deliveries after the sweep %+v (%v)", deliveries, err)
+	}
+
+	updated, err := q.UpdateWebhook(ctx, database.UpdateWebhookParams{ID: other.ID, Url: other.Url, Events: []string{"user.updated"}})
+	if err != nil || updated.Active || updated.Events[0] != "user.updated" || updated.Secret != "secret" {
+		t.Errorf("Test Failure! Unexpected updated webhook %+v (%v)", updated, err)
+	}
+	deleted, err := q.DeleteWebhook(ctx, webhook.ID)
+	if err != nil || deleted != 1 {
+		t.Errorf("Test Failure! Expected 1 deleted webhook, got %d (%v)", deleted, err)
+	}
+	deliveries, err = q.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{WebhookID: webhook.ID, MaxResults: 10})
+	if err != nil || len(deliveries) != 0 {
+		t.Errorf("Test Failure! Deliveries of a deleted webhook remain: %+v (%v)", deliveries, err)
+	}
+	_, err = q.GetWebhook(ctx, webhook.ID)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Test Failure! Deleted webhook still found: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MatchUser scores u against a search query, for the backends without full
+// text search. Every word of the query must appear in the names or email of u.
+// A word scores 1 when it matches a whole word, 0.5 a word prefix and 0.25
+// any other substring, and the score is their mean.
+func MatchUser(u User, query string) (float32, bool) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return 0, false
+	}
+	document := strings.ToLower(u.Firstname + " " + u.Lastname + " " + u.Email)
+	words := strings.FieldsFunc(document, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var score float32
+	for _, term := range terms {
+		if !strings.Contains(document, term) {
+			return 0, false
+		}
+		best := float32(0.25)
+		for _, word := range words {
+			if word == term {
+				best = 1
+				break
+			}
+			if strings.HasPrefix(word, term) {
+				best = 0.5
+			}
+		}
+		score += best
+	}
+	return score / float32(len(terms)), true
+}
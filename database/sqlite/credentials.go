@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"user-manager/database"
+)
+
+func (s *Store) ListUserRoles(ctx context.Context, userid int32) ([]database.Userrole, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT user_roles.role FROM user_roles
+JOIN users ON users.userId = user_roles.userId
+WHERE user_roles.userId = ? AND users.deleted_at IS NULL
+ORDER BY user_roles.role`, userid)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+	var items []database.Userrole
+	for rows.Next() {
+		var role database.Userrole
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		items = append(items, role)
+	}
+	return items, translate(rows.Err())
+}
+
+// SetUserRoles replaces the roles of a user.
+func (s *Store) SetUserRoles(ctx context.Context, arg database.SetUserRolesParams) error {
+	roles, err := listValue(arg.Roles)
+	if err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(s *Store) error {
+		_, err := s.conn.ExecContext(ctx, `DELETE FROM user_roles
+WHERE userId = ? AND role NOT IN (SELECT value FROM json_each(?))`, arg.Userid, roles)
+		if err != nil {
+			return translate(err)
+		}
+		_, err = s.conn.ExecContext(ctx, `INSERT INTO user_roles (userId, role)
+SELECT ?, value FROM json_each(?) WHERE true
+ON CONFLICT DO NOTHING`, arg.Userid, roles)
+		return translate(err)
+	})
+}
+
+func (s *Store) GetCredential(ctx context.Context, userid int32) (database.UserCredential, error) {
+	var i database.UserCredential
+	err := s.conn.QueryRowContext(ctx, `SELECT user_credentials.userId, user_credentials.password_hash, user_credentials.updated_at
+FROM user_credentials
+JOIN users ON users.userId = user_credentials.userId
+WHERE user_credentials.userId = ? AND users.deleted_at IS NULL`, userid).Scan(
+		&i.Userid,
+		&i.PasswordHash,
+		timestamp{&i.UpdatedAt},
+	)
+	return i, translate(err)
+}
+
+func (s *Store) GetCredentialByEmail(ctx context.Context, email string) (database.GetCredentialByEmailRow, error) {
+	var i database.GetCredentialByEmailRow
+	err := s.conn.QueryRowContext(ctx, `SELECT users.userId, users.user_status, user_credentials.password_hash
+FROM users
+JOIN user_credentials ON user_credentials.userId = users.userId
+WHERE lower(users.email) = lower(?) AND users.deleted_at IS NULL`, email).Scan(
+		&i.Userid,
+		&i.UserStatus,
+		&i.PasswordHash,
+	)
+	return i, translate(err)
+}
+
+func (s *Store) UpsertCredential(ctx context.Context, arg database.UpsertCredentialParams) error {
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO user_credentials (userId, password_hash, updated_at)
+VALUES (?, ?, ?)
+ON CONFLICT (userId) DO UPDATE
+  SET password_hash = excluded.password_hash, updated_at = excluded.updated_at`,
+		arg.Userid, arg.PasswordHash, now())
+	return translate(err)
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO refresh_tokens (token_hash, userId, expires_at, created_at)
+VALUES (?, ?, ?, ?)`,
+		arg.TokenHash, arg.Userid, timeValue(arg.ExpiresAt), now())
+	return translate(err)
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, tokenHash []byte) (database.RefreshToken, error) {
+	var i database.RefreshToken
+	err := s.conn.QueryRowContext(ctx, `SELECT token_hash, userId, expires_at, revoked_at, created_at
+FROM refresh_tokens
+WHERE token_hash = ?`, tokenHash).Scan(
+		&i.TokenHash,
+		&i.Userid,
+		timestamp{&i.ExpiresAt},
+		timestamp{&i.RevokedAt},
+		timestamp{&i.CreatedAt},
+	)
+	return i, translate(err)
+}
+
+// RevokeRefreshToken revokes a valid refresh token and returns its user.
+func (s *Store) RevokeRefreshToken(ctx context.Context, tokenHash []byte) (int32, error) {
+	revokedAt := now()
+	var userid int32
+	err := s.conn.QueryRowContext(ctx, `UPDATE refresh_tokens
+  SET revoked_at = ?
+WHERE token_hash = ? AND revoked_at IS NULL AND expires_at > ?
+RETURNING userId`, revokedAt, tokenHash, revokedAt).Scan(&userid)
+	return userid, translate(err)
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userid int32) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, `UPDATE refresh_tokens
+  SET revoked_at = ?
+WHERE userId = ? AND revoked_at IS NULL`, now(), userid)
+	if err != nil {
+		return 0, translate(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *Store) RevokeAccessToken(ctx context.Context, arg database.RevokeAccessTokenParams) error {
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO revoked_tokens (jti, expires_at)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING`, arg.Jti, timeValue(arg.ExpiresAt))
+	return translate(err)
+}
+
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	err := s.conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)", jti).Scan(&exists)
+	return exists, translate(err)
+}
+
+// DeleteExpiredTokens removes expired refresh tokens and revoked access tokens.
+func (s *Store) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := s.inTx(ctx, func(s *Store) error {
+		expiry := now()
+		for _, query := range []string{
+			"DELETE FROM refresh_tokens WHERE expires_at < ?",
+			"DELETE FROM revoked_tokens WHERE expires_at < ?",
+		} {
+			result, err := s.conn.ExecContext(ctx, query, expiry)
+			if err != nil {
+				return translate(err)
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			deleted += n
+		}
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,360 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (s *Store) CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) error {
+	changes := arg.Changes
+	if changes == nil {
+		changes = []byte("{}")
+	}
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO audit_events (
+  userId, action, actor_id, request_id, before, after, changes, created_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		arg.Userid, arg.Action, arg.ActorID, arg.RequestID, bytesValue(arg.Before), bytesValue(arg.After), changes, now())
+	if err != nil {
+		return translate(err)
+	}
+	s.notify()
+	return nil
+}
+
+const auditEventColumns = "id, userId, action, actor_id, request_id, before, after, changes, created_at"
+
+func (s *Store) queryAuditEvents(ctx context.Context, query string, args ...any) ([]database.AuditEvent, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+	items := []database.AuditEvent{}
+	for rows.Next() {
+		var i database.AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Userid,
+			&i.Action,
+			&i.ActorID,
+			&i.RequestID,
+			&i.Before,
+			&i.After,
+			&i.Changes,
+			timestamp{&i.CreatedAt},
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, translate(rows.Err())
+}
+
+func (s *Store) ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error) {
+	return s.queryAuditEvents(ctx, "SELECT "+auditEventColumns+` FROM audit_events
+WHERE userId = ? AND (? = 0 OR id < ?)
+ORDER BY id DESC
+LIMIT ?`, arg.Userid, arg.BeforeID, arg.BeforeID, arg.MaxResults)
+}
+
+// ListUserEvents returns the events following afterID, oldest first. Write
+// transactions take the lock when they begin, so the ids are in commit order
+// and serve as the event ids.
+func (s *Store) ListUserEvents(ctx context.Context, arg database.ListUserEventsParams) ([]database.ListUserEventsRow, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT id, userId, action, changes, created_at FROM audit_events
+WHERE id > ?
+ORDER BY id
+LIMIT ?`, arg.AfterID, arg.MaxResults)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+	items := []database.ListUserEventsRow{}
+	for rows.Next() {
+		var i database.ListUserEventsRow
+		if err := rows.Scan(
+			&i.Seq,
+			&i.Userid,
+			&i.Action,
+			&i.Changes,
+			timestamp{&i.CreatedAt},
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, translate(rows.Err())
+}
+
+func (s *Store) LatestUserEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.conn.QueryRowContext(ctx, "SELECT COALESCE(max(id), 0) FROM audit_events").Scan(&id)
+	return id, translate(err)
+}
+
+const webhookColumns = "id, url, secret, events, active, created_at"
+
+func scanWebhook(row scanner) (database.Webhook, error) {
+	var i database.Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		jsonList{&i.Events},
+		&i.Active,
+		timestamp{&i.CreatedAt},
+	)
+	return i, translate(err)
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	events, err := listValue(arg.Events)
+	if err != nil {
+		return database.Webhook{}, err
+	}
+	row := s.conn.QueryRowContext(ctx, `INSERT INTO webhooks (url, secret, events, active, created_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING `+webhookColumns, arg.Url, arg.Secret, events, arg.Active, now())
+	return scanWebhook(row)
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id int32) (database.Webhook, error) {
+	return scanWebhook(s.conn.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+}
+
+func (s *Store) ListWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+	var items []database.Webhook
+	for rows.Next() {
+		i, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, translate(rows.Err())
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, arg database.UpdateWebhookParams) (database.Webhook, error) {
+	events, err := listValue(arg.Events)
+	if err != nil {
+		return database.Webhook{}, err
+	}
+	row := s.conn.QueryRowContext(ctx, `UPDATE webhooks
+  SET url = ?, events = ?, active = ?
+WHERE id = ?
+RETURNING `+webhookColumns, arg.Url, events, arg.Active, arg.ID)
+	return scanWebhook(row)
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, id int32) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return 0, translate(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *Store) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) error {
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO webhook_outbox (event_type, userId, payload, created_at)
+VALUES (?, ?, ?, ?)`, arg.EventType, arg.Userid, arg.Payload, now())
+	return translate(err)
+}
+
+// returningIDs runs an update returning the ids of the rows it changed, as a
+// JSON array for json_each.
+func (s *Store) returningIDs(ctx context.Context, query string, args ...any) (string, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", translate(err)
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return "", translate(err)
+	}
+	return listValue(ids)
+}
+
+// FanOutWebhookEvents marks up to maxEvents outbox events as dispatched, and
+// creates a delivery of each to the active webhooks subscribed to it.
+func (s *Store) FanOutWebhookEvents(ctx context.Context, maxEvents int32) (int64, error) {
+	var created int64
+	err := s.inTx(ctx, func(s *Store) error {
+		dispatchedAt := now()
+		events, err := s.returningIDs(ctx, `UPDATE webhook_outbox
+  SET dispatched_at = ?
+WHERE id IN (
+  SELECT id FROM webhook_outbox
+  WHERE dispatched_at IS NULL
+  ORDER BY id
+  LIMIT ?
+)
+RETURNING id`, dispatchedAt, maxEvents)
+		if err != nil {
+			return err
+		}
+
+		result, err := s.conn.ExecContext(ctx, `INSERT INTO webhook_deliveries (webhook_id, event_id, next_attempt_at, created_at)
+SELECT w.id, e.id, ?, ? FROM webhook_outbox e
+JOIN webhooks w ON w.active AND w.created_at <= e.created_at
+  AND e.event_type IN (SELECT value FROM json_each(w.events))
+WHERE e.id IN (SELECT value FROM json_each(?))
+ON CONFLICT DO NOTHING`, dispatchedAt, dispatchedAt, events)
+		if err != nil {
+			return translate(err)
+		}
+		created, err = result.RowsAffected()
+		return err
+	})
+	return created, err
+}
+
+// ClaimWebhookDeliveries leases the pending deliveries that are due, counting
+// an attempt for each. Write transactions are serialized, so no two
+// dispatchers claim the same delivery.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	var items []database.ClaimWebhookDeliveriesRow
+	err := s.inTx(ctx, func(s *Store) error {
+		claimedAt := now()
+		claimed, err := s.returningIDs(ctx, `UPDATE webhook_deliveries
+  SET attempts = attempts + 1, next_attempt_at = ?
+WHERE id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending' AND next_attempt_at <= ?
+  ORDER BY next_attempt_at
+  LIMIT ?
+)
+RETURNING id`, claimedAt+int64(arg.LeaseSeconds*1e9), claimedAt, arg.MaxResults)
+		if err != nil {
+			return err
+		}
+
+		rows, err := s.conn.QueryContext(ctx, `SELECT d.id, d.attempts, w.url, w.secret, o.id, o.event_type, o.payload, o.created_at
+FROM webhook_deliveries d
+JOIN webhooks w ON w.id = d.webhook_id
+JOIN webhook_outbox o ON o.id = d.event_id
+WHERE d.id IN (SELECT value FROM json_each(?))
+ORDER BY d.id`, claimed)
+		if err != nil {
+			return translate(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var i database.ClaimWebhookDeliveriesRow
+			if err := rows.Scan(
+				&i.ID,
+				&i.Attempts,
+				&i.Url,
+				&i.Secret,
+				&i.EventID,
+				&i.EventType,
+				&i.Payload,
+				timestamp{&i.EventCreatedAt},
+			); err != nil {
+				return err
+			}
+			items = append(items, i)
+		}
+		return translate(rows.Err())
+	})
+	return items, err
+}
+
+func (s *Store) RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error {
+	var deliveredAt sql.NullInt64
+	if arg.Status == database.DeliverystatusDelivered {
+		deliveredAt = sql.NullInt64{Int64: now(), Valid: true}
+	}
+	_, err := s.conn.ExecContext(ctx, `UPDATE webhook_deliveries
+  SET status = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?
+WHERE id = ?`,
+		arg.Status, timeValue(arg.NextAttemptAt), arg.LastStatusCode, arg.LastError, deliveredAt, arg.ID)
+	return translate(err)
+}
+
+func (s *Store) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.ListWebhookDeliveriesRow, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT d.id, d.webhook_id, d.event_id, d.status, d.attempts, d.next_attempt_at,
+  d.last_status_code, d.last_error, d.delivered_at, d.created_at, o.event_type
+FROM webhook_deliveries d
+JOIN webhook_outbox o ON o.id = d.event_id
+WHERE d.webhook_id = ? AND (? = 0 OR d.id < ?)
+ORDER BY d.id DESC
+LIMIT ?`, arg.WebhookID, arg.BeforeID, arg.BeforeID, arg.MaxResults)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+	var items []database.ListWebhookDeliveriesRow
+	for rows.Next() {
+		var i database.ListWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventID,
+			&i.Status,
+			&i.Attempts,
+			timestamp{&i.NextAttemptAt},
+			&i.LastStatusCode,
+			&i.LastError,
+			timestamp{&i.DeliveredAt},
+			timestamp{&i.CreatedAt},
+			&i.EventType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, translate(rows.Err())
+}
+
+// RetryWebhookDelivery schedules a dead delivery again, with a fresh attempt count.
+func (s *Store) RetryWebhookDelivery(ctx context.Context, arg database.RetryWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	var i database.WebhookDelivery
+	err := s.conn.QueryRowContext(ctx, `UPDATE webhook_deliveries
+  SET status = 'pending', attempts = 0, next_attempt_at = ?
+WHERE id = ? AND webhook_id = ? AND status = 'dead'
+RETURNING id, webhook_id, event_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at`,
+		now(), arg.ID, arg.WebhookID).Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.EventID,
+		&i.Status,
+		&i.Attempts,
+		timestamp{&i.NextAttemptAt},
+		&i.LastStatusCode,
+		&i.LastError,
+		timestamp{&i.DeliveredAt},
+		timestamp{&i.CreatedAt},
+	)
+	return i, translate(err)
+}
+
+// DeleteFinishedWebhookEvents removes the events dispatched before
+// dispatchedBefore that have no pending delivery left, with their deliveries.
+func (s *Store) DeleteFinishedWebhookEvents(ctx context.Context, dispatchedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, `DELETE FROM webhook_outbox
+WHERE dispatched_at < ?
+  AND NOT EXISTS (
+    SELECT 1 FROM webhook_deliveries d
+    WHERE d.event_id = webhook_outbox.id AND d.status = 'pending'
+  )`, timeValue(dispatchedBefore))
+	if err != nil {
+		return 0, translate(err)
+	}
+	return result.RowsAffected()
+}
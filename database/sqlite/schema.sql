@@ -0,0 +1,96 @@
+-- The schema of database/migrations, in SQLite. Timestamps are unix
+-- nanoseconds, arrays JSON arrays, and enums checked text.
+CREATE TABLE IF NOT EXISTS users (
+  userId INTEGER PRIMARY KEY AUTOINCREMENT,
+  firstName TEXT NOT NULL,
+  lastName TEXT NOT NULL,
+  email TEXT NOT NULL,
+  phone TEXT,
+  age INTEGER,
+  user_status TEXT DEFAULT 'Active' CHECK (user_status IN ('Active', 'Inactive')),
+  deleted_at INTEGER,
+  version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (lower(email)) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  userId INTEGER NOT NULL REFERENCES users (userId) ON DELETE CASCADE,
+  role TEXT NOT NULL CHECK (role IN ('admin', 'support')),
+  PRIMARY KEY (userId, role)
+);
+
+CREATE TABLE IF NOT EXISTS user_credentials (
+  userId INTEGER PRIMARY KEY REFERENCES users (userId) ON DELETE CASCADE,
+  password_hash TEXT NOT NULL,
+  updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  token_hash BLOB PRIMARY KEY,
+  userId INTEGER NOT NULL REFERENCES users (userId) ON DELETE CASCADE,
+  expires_at INTEGER NOT NULL,
+  revoked_at INTEGER,
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_userid_idx ON refresh_tokens (userId);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+  jti TEXT PRIMARY KEY,
+  expires_at INTEGER NOT NULL
+);
+
+-- audit events outlive purged users, so userId is not a foreign key
+CREATE TABLE IF NOT EXISTS audit_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  userId INTEGER NOT NULL,
+  action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore', 'purge')),
+  actor_id INTEGER,
+  request_id TEXT,
+  before BLOB,
+  after BLOB,
+  changes BLOB NOT NULL DEFAULT '{}',
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_userid_idx ON audit_events (userId, id);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  events TEXT NOT NULL,
+  active INTEGER NOT NULL DEFAULT 1,
+  created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_type TEXT NOT NULL,
+  userId INTEGER NOT NULL,
+  payload BLOB NOT NULL,
+  dispatched_at INTEGER,
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_outbox_pending_idx ON webhook_outbox (id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS webhook_outbox_dispatched_idx ON webhook_outbox (dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  event_id INTEGER NOT NULL REFERENCES webhook_outbox (id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at INTEGER NOT NULL,
+  last_status_code INTEGER,
+  last_error TEXT,
+  delivered_at INTEGER,
+  created_at INTEGER NOT NULL,
+  UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (event_id);
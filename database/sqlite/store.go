@@ -0,0 +1,289 @@
+// Package sqlite implements database.Querier on an SQLite file, for running
+// the service on a single instance without a database server.
+//
+// The schema is created when the file is opened. User events are only
+// notified within the process, so the file must not be shared by replicas.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed schema.sql
+var schema string
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Store runs the queries on an SQLite database.
+type Store struct {
+	// Broadcaster is notified when user events are committed.
+	*database.Broadcaster
+
+	db *sql.DB
+	// conn is db, or the transaction the Store is bound to.
+	conn dbtx
+	tx   *txState
+}
+
+type txState struct {
+	tx *sql.Tx
+	// savepoints is the number of savepoints opened by nested ExecTx calls.
+	savepoints int
+	// notify is set when the transaction recorded user events.
+	notify bool
+}
+
+var _ database.Querier = (*Store)(nil)
+
+// Open opens the SQLite database at path, creating it and its schema when
+// missing. Write transactions take the lock when they begin, and wait up to
+// 5 seconds for a concurrent one to finish.
+func Open(ctx context.Context, path string) (*Store, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"},
+		"_txlock": {"immediate"},
+	}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.ExecContext(ctx, schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating the sqlite schema: %w", err)
+	}
+	err = upgradeAuditActions(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("upgrading the sqlite schema: %w", err)
+	}
+	return &Store{Broadcaster: &database.Broadcaster{}, db: db, conn: db}, nil
+}
+
+// upgradeAuditActions rebuilds the audit_events table of files created before
+// purges were audited, as SQLite can not alter its CHECK constraint.
+func upgradeAuditActions(ctx context.Context, db *sql.DB) error {
+	var table string
+	err := db.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'audit_events'").Scan(&table)
+	if err != nil || strings.Contains(table, "'purge'") {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range []string{
+		"ALTER TABLE audit_events RENAME TO audit_events_old",
+		"DROP INDEX audit_events_userid_idx",
+		schema,
+		"INSERT INTO audit_events SELECT * FROM audit_events_old",
+		"DROP TABLE audit_events_old",
+	} {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database file can still be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// ExecTx runs fn in a transaction, committing when fn succeeds and rolling
+// back otherwise. Called within a transaction it uses a savepoint.
+func (s *Store) ExecTx(ctx context.Context, fn func(database.Querier) error) error {
+	if s.tx != nil {
+		return s.execSavepoint(ctx, fn)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback()
+
+	state := &txState{tx: tx}
+	err = fn(&Store{Broadcaster: s.Broadcaster, db: s.db, conn: tx, tx: state})
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return translate(err)
+	}
+
+	if state.notify {
+		s.Notify()
+	}
+	return nil
+}
+
+func (s *Store) execSavepoint(ctx context.Context, fn func(database.Querier) error) error {
+	s.tx.savepoints++
+	name := fmt.Sprintf("sp%d", s.tx.savepoints)
+	_, err := s.tx.tx.ExecContext(ctx, "SAVEPOINT "+name)
+	if err != nil {
+		return translate(err)
+	}
+
+	err = fn(s)
+	if err != nil {
+		_, rollbackErr := s.tx.tx.ExecContext(ctx, "ROLLBACK TO "+name)
+		if rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+	}
+	_, releaseErr := s.tx.tx.ExecContext(ctx, "RELEASE "+name)
+	if err != nil {
+		return err
+	}
+	return translate(releaseErr)
+}
+
+// inTx runs fn in the transaction of s, or in a new one.
+func (s *Store) inTx(ctx context.Context, fn func(s *Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	return s.ExecTx(ctx, func(q database.Querier) error {
+		return fn(q.(*Store))
+	})
+}
+
+// notify wakes the subscribers, once the transaction commits when in one.
+func (s *Store) notify() {
+	if s.tx != nil {
+		s.tx.notify = true
+		return
+	}
+	s.Notify()
+}
+
+// translate reports errors the way Postgres does, see database.Querier.
+func translate(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return database.UniqueViolation(constraintName(sqliteErr.Error()))
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return database.ForeignKeyViolation("")
+	}
+	return err
+}
+
+// primaryKeys names the primary keys that inserts may violate, by the
+// columns SQLite reports.
+var primaryKeys = map[string]string{
+	"refresh_tokens.token_hash": "refresh_tokens_pkey",
+}
+
+// constraintName returns the name Postgres gives to the constraint of an
+// SQLite unique violation: the index, or the primary key.
+func constraintName(message string) string {
+	_, detail, _ := strings.Cut(message, "UNIQUE constraint failed: ")
+	detail, _, _ = strings.Cut(detail, " (")
+	if index, ok := strings.CutPrefix(detail, "index "); ok {
+		return strings.Trim(index, "'")
+	}
+	if name, ok := primaryKeys[detail]; ok {
+		return name
+	}
+	return detail
+}
+
+// timestamp scans a unix nanoseconds column.
+type timestamp struct {
+	t *pgtype.Timestamptz
+}
+
+func (ts timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*ts.t = pgtype.Timestamptz{}
+	case int64:
+		*ts.t = pgtype.Timestamptz{Time: time.Unix(0, v), Valid: true}
+	default:
+		return fmt.Errorf("cannot scan %T into a timestamp", src)
+	}
+	return nil
+}
+
+// timeValue returns the column value of t.
+func timeValue(t pgtype.Timestamptz) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.UnixNano()
+}
+
+func now() int64 {
+	return time.Now().UnixNano()
+}
+
+// bytesValue returns the column value of b, NULL when b is nil.
+func bytesValue(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// jsonList scans a JSON array column.
+type jsonList struct {
+	list *[]string
+}
+
+func (l jsonList) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), l.list)
+	case []byte:
+		return json.Unmarshal(v, l.list)
+	}
+	return fmt.Errorf("cannot scan %T into a list", src)
+}
+
+// listValue returns the column value of a list, a JSON array also used as
+// the argument of json_each.
+func listValue[T any](list []T) (string, error) {
+	if list == nil {
+		list = []T{}
+	}
+	b, err := json.Marshal(list)
+	return string(b), err
+}
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"user-manager/database"
+	"user-manager/database/querytest"
+)
+
+func TestStore(t *testing.T) {
+	querytest.Run(t, func(t *testing.T) database.Querier {
+		store, err := Open(t.Context(), filepath.Join(t.TempDir(), "user-manager.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestConstraintName(t *testing.T) {
+	tests := map[string]string{
+		"constraint failed: UNIQUE constraint failed: index 'users_email_key' (2067)": "users_email_key",
+		"UNIQUE constraint failed: refresh_tokens.token_hash (1555)":                  "refresh_tokens_pkey",
+		"UNIQUE constraint failed: webhooks.url":                                      "webhooks.url",
+	}
+	for message, expected := range tests {
+		if name := constraintName(message); name != expected {
+			t.Errorf("Test Failure! Expected %s for %q, got %s", expected, message, name)
+		}
+	}
+}
+
+func TestOpenUpgradesAuditActions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user-manager.db")
+	store, err := Open(t.Context(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the audit_events table of files created before purges were audited
+	_, err = store.db.ExecContext(t.Context(), `DROP TABLE audit_events;
+CREATE TABLE audit_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  userId INTEGER NOT NULL,
+  action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore')),
+  actor_id INTEGER,
+  request_id TEXT,
+  before BLOB,
+  after BLOB,
+  changes BLOB NOT NULL DEFAULT '{}',
+  created_at INTEGER NOT NULL
+);
+INSERT INTO audit_events (userId, action, created_at) VALUES (7, 'delete', 0);`)
+	store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(t.Context(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	err = store.CreateAuditEvent(t.Context(), database.CreateAuditEventParams{Userid: 7, Action: database.AuditactionPurge})
+	if err != nil {
+		t.Fatalf("Test Failure! Purge audit event not recorded: %v", err)
+	}
+	events, err := store.ListAuditEvents(t.Context(), database.ListAuditEventsParams{Userid: 7, MaxResults: 10})
+	if err != nil || len(events) != 2 || events[0].ID != 2 || events[0].Action != database.AuditactionPurge {
+		t.Errorf("Test Failure! Incorrect upgraded audit events %+v (%v)", events, err)
+	}
+}
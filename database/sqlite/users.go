@@ -0,0 +1,300 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const userColumns = "userId, firstName, lastName, email, phone, age, user_status, deleted_at, version"
+
+// userSortColumns maps the sortable dto.User fields to the SQL expression used
+// for ordering and keyset comparison, like database.ListUsersPage does.
+var userSortColumns = map[string]string{
+	"userId":    "userId",
+	"firstName": "firstName",
+	"lastName":  "lastName",
+	"email":     "email",
+	"phone":     "COALESCE(phone, '')",
+	"age":       "COALESCE(age, 0)",
+	"status":    "COALESCE(user_status, '')",
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (database.User, error) {
+	var i database.User
+	err := row.Scan(
+		&i.Userid,
+		&i.Firstname,
+		&i.Lastname,
+		&i.Email,
+		&i.Phone,
+		&i.Age,
+		&i.UserStatus,
+		timestamp{&i.DeletedAt},
+		&i.Version,
+	)
+	return i, translate(err)
+}
+
+// queryUsers runs a query selecting userColumns.
+func (s *Store) queryUsers(ctx context.Context, query string, args ...any) ([]database.User, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+	items := []database.User{}
+	for rows.Next() {
+		i, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, translate(rows.Err())
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	row := s.conn.QueryRowContext(ctx, `INSERT INTO users (
+  firstName, lastName, email, phone, age, user_status
+) VALUES (?, ?, ?, ?, ?, ?)
+RETURNING `+userColumns,
+		arg.Firstname, arg.Lastname, arg.Email, arg.Phone, arg.Age, arg.UserStatus)
+	return scanUser(row)
+}
+
+func (s *Store) GetUser(ctx context.Context, arg database.GetUserParams) (database.User, error) {
+	row := s.conn.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE userId = ? AND (deleted_at IS NULL OR ?)",
+		arg.Userid, arg.IncludeDeleted)
+	return scanUser(row)
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]database.User, error) {
+	return s.queryUsers(ctx, "SELECT "+userColumns+" FROM users WHERE deleted_at IS NULL ORDER BY firstName")
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	row := s.conn.QueryRowContext(ctx, `UPDATE users
+  SET firstName = ?, lastName = ?, email = ?, phone = ?, age = ?, user_status = ?, version = version + 1
+WHERE userId = ? AND version = ? AND deleted_at IS NULL
+RETURNING `+userColumns,
+		arg.Firstname, arg.Lastname, arg.Email, arg.Phone, arg.Age, arg.UserStatus, arg.Userid, arg.Version)
+	return scanUser(row)
+}
+
+func (s *Store) DeleteUser(ctx context.Context, arg database.DeleteUserParams) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, `UPDATE users
+  SET deleted_at = ?, version = version + 1
+WHERE userId = ? AND version = ? AND deleted_at IS NULL`,
+		now(), arg.Userid, arg.Version)
+	if err != nil {
+		return 0, translate(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *Store) RestoreUser(ctx context.Context, userid int32) (database.User, error) {
+	row := s.conn.QueryRowContext(ctx, `UPDATE users
+  SET deleted_at = NULL, version = version + 1
+WHERE userId = ? AND deleted_at IS NOT NULL
+RETURNING `+userColumns, userid)
+	return scanUser(row)
+}
+
+func (s *Store) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]database.User, error) {
+	return s.queryUsers(ctx, "DELETE FROM users WHERE deleted_at < ? RETURNING "+userColumns, timeValue(deletedBefore))
+}
+
+type queryBuilder struct {
+	where []string
+	args  []any
+}
+
+func (b *queryBuilder) applyFilter(f database.ListUsersFilter) {
+	if !f.IncludeDeleted {
+		b.where = append(b.where, "deleted_at IS NULL")
+	}
+	if f.Status.Valid {
+		b.add("user_status = ?", f.Status)
+	}
+	if f.MinAge.Valid {
+		b.add("age >= ?", f.MinAge)
+	}
+	if f.MaxAge.Valid {
+		b.add("age <= ?", f.MaxAge)
+	}
+	// LIKE ignores the case of ASCII letters, like ILIKE.
+	if f.EmailPrefix.Valid {
+		b.add(`email LIKE ? ESCAPE '\'`, likePrefix(f.EmailPrefix.String))
+	}
+	if f.NamePrefix.Valid {
+		p := likePrefix(f.NamePrefix.String)
+		b.add(`(firstName LIKE ? ESCAPE '\' OR lastName LIKE ? ESCAPE '\')`, p, p)
+	}
+}
+
+func (b *queryBuilder) add(condition string, args ...any) {
+	b.where = append(b.where, condition)
+	b.args = append(b.args, args...)
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.where, " AND ")
+}
+
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
+}
+
+// orderBy returns the ORDER BY clause of the sort column, with userId as tie breaker.
+func orderBy(column string, descending bool) (string, error) {
+	expr, ok := userSortColumns[column]
+	if !ok {
+		return "", fmt.Errorf("unsupported sort column: %s", column)
+	}
+	dir := "ASC"
+	if descending {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, userId %s", expr, dir, dir), nil
+}
+
+func (s *Store) ListUsersPage(ctx context.Context, arg database.ListUsersPageParams) ([]database.User, error) {
+	order, err := orderBy(arg.SortColumn, arg.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &queryBuilder{}
+	b.applyFilter(arg.Filter)
+	if arg.After != nil {
+		op, value := ">", "?"
+		if arg.Descending {
+			op = "<"
+		}
+		// cursor values are text, and SQLite orders any integer before text
+		if arg.SortColumn == "userId" || arg.SortColumn == "age" {
+			value = "CAST(? AS INTEGER)"
+		}
+		b.add(fmt.Sprintf("(%s, userId) %s (%s, ?)", userSortColumns[arg.SortColumn], op, value), arg.After.Value, arg.After.Userid)
+	}
+
+	query := "SELECT " + userColumns + " FROM users" + b.whereClause() + order + " LIMIT ?"
+	b.args = append(b.args, arg.Limit)
+	if arg.After == nil && arg.Offset > 0 {
+		query += " OFFSET ?"
+		b.args = append(b.args, arg.Offset)
+	}
+	return s.queryUsers(ctx, query, b.args...)
+}
+
+func (s *Store) CountUsers(ctx context.Context, arg database.ListUsersFilter) (int64, error) {
+	b := &queryBuilder{}
+	b.applyFilter(arg)
+
+	var count int64
+	err := s.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+b.whereClause(), b.args...).Scan(&count)
+	return count, translate(err)
+}
+
+// ExportUsers calls fn with every user matching the filter, in the same order
+// as ListUsersPage, reading the rows as fn consumes them.
+func (s *Store) ExportUsers(ctx context.Context, arg database.ExportUsersParams, fn func(database.User) error) error {
+	order, err := orderBy(arg.SortColumn, arg.Descending)
+	if err != nil {
+		return err
+	}
+
+	b := &queryBuilder{}
+	b.applyFilter(arg.Filter)
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+userColumns+" FROM users"+b.whereClause()+order, b.args...)
+	if err != nil {
+		return translate(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		i, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		err = fn(i)
+		if err != nil {
+			return err
+		}
+	}
+	return translate(rows.Err())
+}
+
+// SearchUsers scores users with database.MatchUser, in place of the full text
+// and trigram search of Postgres.
+func (s *Store) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.SearchUsersRow, error) {
+	users, err := s.queryUsers(ctx, "SELECT "+userColumns+" FROM users WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []database.SearchUsersRow{}
+	for _, u := range users {
+		score, ok := database.MatchUser(u, arg.Query)
+		if !ok {
+			continue
+		}
+		rows = append(rows, database.SearchUsersRow{
+			Userid:     u.Userid,
+			Firstname:  u.Firstname,
+			Lastname:   u.Lastname,
+			Email:      u.Email,
+			Phone:      u.Phone,
+			Age:        u.Age,
+			UserStatus: u.UserStatus,
+			DeletedAt:  u.DeletedAt,
+			Version:    u.Version,
+			Score:      score,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.SearchUsersRow) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Userid, b.Userid))
+	})
+	return rows[:min(int(arg.MaxResults), len(rows))], nil
+}
+
+// ImportUsers inserts the users in a single transaction, all or none.
+func (s *Store) ImportUsers(ctx context.Context, arg []database.ImportUsersParams) (int64, error) {
+	err := s.inTx(ctx, func(s *Store) error {
+		for _, row := range arg {
+			_, err := s.conn.ExecContext(ctx, `INSERT INTO users (
+  firstName, lastName, email, phone, age, user_status
+) VALUES (?, ?, ?, ?, ?, ?)`,
+				row.Firstname, row.Lastname, row.Email, row.Phone, row.Age, row.UserStatus)
+			if err != nil {
+				return translate(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(arg)), nil
+}
+
+func (s *Store) ListUsersByEmails(ctx context.Context, emails []string) ([]database.User, error) {
+	list, err := listValue(emails)
+	if err != nil {
+		return nil, err
+	}
+	return s.queryUsers(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) IN (SELECT value FROM json_each(?)) AND deleted_at IS NULL", list)
+}
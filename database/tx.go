@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// ExecTx runs fn in a transaction, committing when fn succeeds and rolling
+// back otherwise. Called within a transaction it uses a savepoint.
+func (q *Queries) ExecTx(ctx context.Context, fn func(Querier) error) error {
+	db, ok := q.db.(beginner)
+	if !ok {
+		return fmt.Errorf("database handle does not support transactions")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(q.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
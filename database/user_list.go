@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const userColumns = "userid, firstname, lastname, email, phone, age, user_status, deleted_at, version"
+
+// userSortColumns maps the sortable dto.User fields to the SQL expression used
+// for ordering and keyset comparison, together with the type the cursor value
+// is cast to. Nullable columns are coalesced so that keyset comparisons never
+// see NULL.
+var userSortColumns = map[string]struct {
+	expr string
+	cast string
+}{
+	"userId":    {"userId", "int"},
+	"firstName": {"firstName", "text"},
+	"lastName":  {"lastName", "text"},
+	"email":     {"email", "text"},
+	"phone":     {"COALESCE(phone, '')", "text"},
+	"age":       {"COALESCE(age, 0)", "int"},
+	"status":    {"COALESCE(user_status::text, '')", "text"},
+}
+
+// ListUsersFilter holds the optional filters shared by ListUsersPage and CountUsers.
+type ListUsersFilter struct {
+	Status      NullUserstatus
+	MinAge      pgtype.Int4
+	MaxAge      pgtype.Int4
+	EmailPrefix pgtype.Text
+	NamePrefix  pgtype.Text
+	// IncludeDeleted also matches soft deleted users.
+	IncludeDeleted bool
+}
+
+// UserKey is the keyset position of a row: the value of the sort column and the userId tie breaker.
+type UserKey struct {
+	Value  string
+	Userid int32
+}
+
+type ListUsersPageParams struct {
+	Filter     ListUsersFilter
+	SortColumn string
+	Descending bool
+	After      *UserKey
+	Limit      int32
+	Offset     int32
+}
+
+// IsSortColumn reports whether column can be used as ListUsersPageParams.SortColumn.
+func IsSortColumn(column string) bool {
+	_, ok := userSortColumns[column]
+	return ok
+}
+
+// SortKey returns the keyset position of u when ordering by column.
+func SortKey(u User, column string) UserKey {
+	key := UserKey{Userid: u.Userid}
+	switch column {
+	case "firstName":
+		key.Value = u.Firstname
+	case "lastName":
+		key.Value = u.Lastname
+	case "email":
+		key.Value = u.Email
+	case "phone":
+		key.Value = u.Phone.String
+	case "age":
+		key.Value = strconv.Itoa(int(u.Age.Int32))
+	case "status":
+		key.Value = string(u.UserStatus.Userstatus)
+	default:
+		key.Value = strconv.Itoa(int(u.Userid))
+	}
+	return key
+}
+
+// queryBuilder builds the dynamic user queries. Like the queries generated by
+// sqlc, they start with a "-- name:" comment naming them in the metrics and traces.
+type queryBuilder struct {
+	where []string
+	args  []interface{}
+}
+
+func (b *queryBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) applyFilter(f ListUsersFilter) {
+	if !f.IncludeDeleted {
+		b.where = append(b.where, "deleted_at IS NULL")
+	}
+	if f.Status.Valid {
+		b.where = append(b.where, "user_status = "+b.arg(f.Status))
+	}
+	if f.MinAge.Valid {
+		b.where = append(b.where, "age >= "+b.arg(f.MinAge))
+	}
+	if f.MaxAge.Valid {
+		b.where = append(b.where, "age <= "+b.arg(f.MaxAge))
+	}
+	if f.EmailPrefix.Valid {
+		b.where = append(b.where, "email ILIKE "+b.arg(likePrefix(f.EmailPrefix.String)))
+	}
+	if f.NamePrefix.Valid {
+		p := b.arg(likePrefix(f.NamePrefix.String))
+		b.where = append(b.where, fmt.Sprintf("(firstName ILIKE %s OR lastName ILIKE %s)", p, p))
+	}
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.where, " AND ")
+}
+
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
+}
+
+// ListUsersPage returns one page of users matching the filter, ordered by the
+// sort column with userId as tie breaker. When After is set the page starts
+// right after that keyset position, otherwise Offset rows are skipped.
+func (q *Queries) ListUsersPage(ctx context.Context, arg ListUsersPageParams) ([]User, error) {
+	sort, ok := userSortColumns[arg.SortColumn]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort column: %s", arg.SortColumn)
+	}
+
+	dir, cmp := "ASC", ">"
+	if arg.Descending {
+		dir, cmp = "DESC", "<"
+	}
+
+	b := &queryBuilder{}
+	b.applyFilter(arg.Filter)
+	if arg.After != nil {
+		b.where = append(b.where, fmt.Sprintf("(%s, userId) %s (%s::%s, %s)",
+			sort.expr, cmp, b.arg(arg.After.Value), sort.cast, b.arg(arg.After.Userid)))
+	}
+
+	query := "-- name: ListUsersPage :many\nSELECT " + userColumns + " FROM users" + b.whereClause() +
+		fmt.Sprintf(" ORDER BY %s %s, userId %s LIMIT %s", sort.expr, dir, dir, b.arg(arg.Limit))
+	if arg.After == nil && arg.Offset > 0 {
+		query += " OFFSET " + b.arg(arg.Offset)
+	}
+
+	rows, err := q.db.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		i, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type ExportUsersParams struct {
+	Filter     ListUsersFilter
+	SortColumn string
+	Descending bool
+}
+
+// ExportUsers calls fn with every user matching the filter, in the same order
+// as ListUsersPage. Rows are read from the connection as fn consumes them, so
+// the result is never held in memory. It stops at the first error of fn.
+func (q *Queries) ExportUsers(ctx context.Context, arg ExportUsersParams, fn func(User) error) error {
+	sort, ok := userSortColumns[arg.SortColumn]
+	if !ok {
+		return fmt.Errorf("unsupported sort column: %s", arg.SortColumn)
+	}
+
+	dir := "ASC"
+	if arg.Descending {
+		dir = "DESC"
+	}
+
+	b := &queryBuilder{}
+	b.applyFilter(arg.Filter)
+	query := "-- name: ExportUsers :many\nSELECT " + userColumns + " FROM users" + b.whereClause() +
+		fmt.Sprintf(" ORDER BY %s %s, userId %s", sort.expr, dir, dir)
+
+	rows, err := q.db.Query(ctx, query, b.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		i, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		err = fn(i)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanUser(rows pgx.Rows) (User, error) {
+	var i User
+	err := rows.Scan(
+		&i.Userid,
+		&i.Firstname,
+		&i.Lastname,
+		&i.Email,
+		&i.Phone,
+		&i.Age,
+		&i.UserStatus,
+		&i.DeletedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+// CountUsers returns the number of users matching the filter.
+func (q *Queries) CountUsers(ctx context.Context, arg ListUsersFilter) (int64, error) {
+	b := &queryBuilder{}
+	b.applyFilter(arg)
+
+	var count int64
+	err := q.db.QueryRow(ctx, "-- name: CountUsers :one\nSELECT COUNT(*) FROM users"+b.whereClause(), b.args...).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,36 @@
+package dto
+
+type LoginRequest struct {
+	//@Description User email
+	Email string `json:"email" validate:"required,email"`
+	//@Description User password
+	Password string `json:"password" validate:"required,max=1024"`
+}
+
+type RefreshRequest struct {
+	//@Description Refresh token returned by login or a previous refresh
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	//@Description Refresh token to revoke along with the access token. Optional
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenPair struct {
+	//@Description Bearer token for the API
+	AccessToken string `json:"access_token"`
+	//@Description Always Bearer
+	TokenType string `json:"token_type"`
+	//@Description Access token lifetime in seconds
+	ExpiresIn int64 `json:"expires_in"`
+	//@Description Single use token to obtain a new token pair
+	RefreshToken string `json:"refresh_token"`
+}
+
+type PasswordChange struct {
+	//@Description Current password. Required when changing your own existing password
+	CurrentPassword string `json:"current_password" validate:"max=1024"`
+	//@Description New password. Must satisfy the configured password policy
+	NewPassword string `json:"new_password" validate:"required,max=1024,password"`
+}
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"encoding/json"
+	"user-manager/database"
+)
+
+type BatchRequest struct {
+	//@Description Keep every operation or none: one failed operation rolls back the whole batch
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=1000"`
+}
+
+type BatchOperation struct {
+	//@Description create, patch or delete
+	Op string `json:"op" validate:"required,oneof=create patch delete"`
+	//@Description Id of the user to patch or delete
+	ID int32 `json:"id" validate:"required_unless=Op create,gte=0"`
+	//@Description Version the patch or delete is based on, like If-Match. Required by patch and delete
+	Version int32 `json:"version" validate:"required_unless=Op create,gte=0"`
+	//@Description The user to create, or the patch to apply: a JSON Merge Patch object or a JSON Patch array
+	User json.RawMessage `json:"user,omitempty" validate:"required_unless=Op delete" swaggertype:"object"`
+}
+
+type BatchResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	//@Description HTTP status the operation would have had on its own. 424 when it was rolled back or not run
+	Status int            `json:"status"`
+	User   *database.User `json:"user,omitempty"`
+	Error  *Problem       `json:"error,omitempty"`
+}
+
+type BatchReport struct {
+	Atomic bool `json:"atomic"`
+	//@Description Whether the successful operations were stored
+	Committed bool          `json:"committed"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
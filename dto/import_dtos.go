@@ -0,0 +1,30 @@
+package dto
+
+type ImportOptions struct {
+	//@Description Validate the rows without storing them
+	DryRun bool `json:"dry_run"`
+	//@Description Store every row or none: one rejected row rolls back the whole import
+	Atomic bool `json:"atomic"`
+}
+
+type ImportRow struct {
+	//@Description Line of the row in the uploaded file
+	Line int `json:"line"`
+	//@Description accepted or rejected
+	Status string `json:"status"`
+	//@Description Id of the created user. Not set on dry runs and rejected rows
+	UserID int32  `json:"userId,omitempty"`
+	Email  string `json:"email,omitempty"`
+	//@Description Why the row was rejected
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun bool `json:"dry_run"`
+	Atomic bool `json:"atomic"`
+	//@Description Whether the accepted rows were stored
+	Committed bool        `json:"committed"`
+	Accepted  int         `json:"accepted"`
+	Rejected  int         `json:"rejected"`
+	Rows      []ImportRow `json:"rows"`
+}
@@ -1,5 +1,11 @@
 package dto
 
+import (
+	"encoding/json"
+	"time"
+	"user-manager/database"
+)
+
 type User struct {
 	//@Description User First Name. Max length 50, min length 2
 	Firstname string `json:"firstName" validate:"required,max=50,min=2"`
@@ -7,10 +13,126 @@ type User struct {
 	Lastname string `json:"lastName" validate:"required,max=50,min=2"`
 	//@Description User email
 	Email string `json:"email" validate:"required,email"`
-	//@Description User phone. Optional
-	Phone string `json:"phone" validate:"e164"`
-	//@Description User age. Optional
-	Age int32 `json:"age" validate:"numeric,gt=0"`
+	//@Description User phone. Optional, null clears it
+	Phone *string `json:"phone" validate:"omitempty,e164"`
+	//@Description User age. Optional, null clears it
+	Age *int32 `json:"age" validate:"omitempty,numeric,gt=0"`
 	//@Description User status. Optional
-	Status string `json:"status"`
+	Status string `json:"status" validate:"omitempty,oneof=Active Inactive"`
+}
+
+type UserListParams struct {
+	Limit       int32  `json:"limit" validate:"gte=0,lte=100"`
+	Cursor      string `json:"cursor" validate:"excluded_with=Page"`
+	Page        int32  `json:"page" validate:"gte=0"`
+	Sort        string `json:"sort" validate:"omitempty,oneof=userId firstName lastName email phone age status"`
+	Order       string `json:"order" validate:"omitempty,oneof=asc desc"`
+	Status      string `json:"status" validate:"omitempty,oneof=Active Inactive"`
+	MinAge      *int32 `json:"min_age" validate:"omitempty,gte=0"`
+	MaxAge      *int32 `json:"max_age" validate:"omitempty,gte=0"`
+	EmailPrefix string `json:"email_prefix" validate:"max=254"`
+	NamePrefix  string `json:"name_prefix" validate:"max=50"`
+
+	IncludeDeleted bool `json:"include_deleted"`
+}
+
+// UserExportParams selects the users of an export with the filters and sort
+// order of UserListParams. Paging parameters are ignored: every match is exported.
+type UserExportParams struct {
+	UserListParams
+	//@Description csv, ndjson or xlsx
+	Format string `json:"format" validate:"omitempty,oneof=csv ndjson xlsx"`
+	//@Description Columns to export, in order. Defaults to every column but deletedAt and version
+	Columns []string `json:"columns" validate:"unique,dive,oneof=userId firstName lastName email phone age status deletedAt version"`
+}
+
+type UserSearchParams struct {
+	Query string `json:"q" validate:"required,max=100"`
+	Limit int32  `json:"limit" validate:"gte=0,lte=100"`
+}
+
+type UserRoles struct {
+	//@Description Roles granted to the user
+	Roles []string `json:"roles" validate:"required,unique,dive,oneof=admin support"`
+}
+
+type UserPage struct {
+	//@Description Users on this page
+	Users []database.User `json:"users"`
+	//@Description Opaque cursor for the next page. Empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	//@Description Total number of users matching the filters
+	Total int64 `json:"total"`
+}
+
+type FieldError struct {
+	//@Description Name of the invalid field
+	Field string `json:"field"`
+	//@Description Validation rule that failed
+	Tag string `json:"tag"`
+	//@Description Stable error code for the field
+	Code string `json:"code"`
+	//@Description Human readable message
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	//@Description Problem type URI
+	Type string `json:"type"`
+	//@Description Short summary of the problem type
+	Title string `json:"title"`
+	//@Description HTTP status code
+	Status int `json:"status"`
+	//@Description Explanation specific to this occurrence
+	Detail string `json:"detail,omitempty"`
+	//@Description Request path the problem occurred on
+	Instance string `json:"instance,omitempty"`
+	//@Description Stable error code
+	Code string `json:"code"`
+	//@Description Every invalid field
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+type AuditListParams struct {
+	Limit  int32  `json:"limit" validate:"gte=0,lte=100"`
+	Cursor string `json:"cursor"`
+}
+
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	//@Description Id of the audited user
+	UserID int32 `json:"userId"`
+	//@Description create, update, delete, restore or purge
+	Action string `json:"action"`
+	//@Description Id of the user who made the change. Null for changes made without a caller, such as background jobs
+	ActorID *int32 `json:"actor_id"`
+	//@Description Id of the request that made the change
+	RequestID string `json:"request_id,omitempty"`
+	//@Description User row before the change. Null for create
+	Before json.RawMessage `json:"before" swaggertype:"object"`
+	//@Description User row after the change
+	After json.RawMessage `json:"after" swaggertype:"object"`
+	//@Description Changed fields with their from and to values
+	Changes   json.RawMessage `json:"changes" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AuditPage struct {
+	//@Description Audit events, newest first
+	Events []AuditEvent `json:"events"`
+	//@Description Cursor of the next page. Empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// UserEvent is a change of a user, sent by GET /users/events.
+type UserEvent struct {
+	//@Description Event id, to resume the stream with Last-Event-ID
+	ID int64 `json:"id"`
+	//@Description user.created, user.updated, user.deleted, user.restored or user.purged
+	Type      string    `json:"type"`
+	UserID    int32     `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	//@Description Names of the changed fields, for user.created and user.updated. Read the user for their values
+	Fields []string `json:"fields"`
 }
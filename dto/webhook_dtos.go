@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookInput struct {
+	//@Description URL the events are posted to
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+	//@Description Event types to deliver: user.created, user.updated, user.deactivated, user.deleted and user.restored
+	Events []string `json:"events" validate:"required,min=1,unique,dive,oneof=user.created user.updated user.deactivated user.deleted user.restored"`
+	//@Description Whether events are delivered. Default true
+	Active *bool `json:"active"`
+}
+
+type Webhook struct {
+	ID     int32    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	//@Description Key of the HMAC-SHA256 signatures. Only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookEvent is the body posted to webhooks.
+type WebhookEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	//@Description The user after the change, or before it for user.deleted
+	Data json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+type DeliveryListParams struct {
+	Limit  int32  `json:"limit" validate:"gte=0,lte=100"`
+	Cursor string `json:"cursor"`
+}
+
+type WebhookDelivery struct {
+	ID        int64  `json:"id"`
+	EventID   int64  `json:"eventId"`
+	EventType string `json:"eventType"`
+	//@Description pending, delivered or dead
+	Status   string `json:"status"`
+	Attempts int32  `json:"attempts"`
+	//@Description When the next attempt is due. Only set while pending
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	//@Description HTTP status of the last attempt
+	LastStatusCode *int32     `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+type WebhookDeliveryPage struct {
+	//@Description Deliveries, newest first
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	//@Description Opaque cursor for the next page. Empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
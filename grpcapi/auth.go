@@ -0,0 +1,89 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"user-manager/auth"
+	services "user-manager/internal"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authenticateUnary checks the bearer token of UserService calls like the REST
+// API does, and stores the claims and the caller in the context.
+func (s *Server) authenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !strings.HasPrefix(info.FullMethod, "/usermanager.") {
+		// health checks and reflection are public
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasPrefix(info.FullMethod, "/usermanager.") {
+		return handler(srv, stream)
+	}
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var authorization string
+	if values := md.Get("authorization"); len(values) > 0 {
+		authorization = values[0]
+	}
+
+	authenticated, err := auth.Authenticate(ctx, authorization, s.Verifier, services.Sessions(s.Queries))
+	switch {
+	case err == nil:
+		return authenticated, nil
+	case errors.Is(err, auth.ErrMissingToken):
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	case errors.Is(err, auth.ErrRevokedToken):
+		return nil, status.Error(codes.Unauthenticated, "bearer token has been revoked")
+	case errors.Is(err, auth.ErrUnauthorized):
+		slog.DebugContext(ctx, "error on verifying token", "error", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired bearer token")
+	case errors.Is(err, services.ErrUnauthenticated):
+		return nil, statusError(ctx, err)
+	default:
+		slog.ErrorContext(ctx, "error on authenticating caller", "error", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+}
+
+// authorize allows the call when the caller holds one of roles. auth.RoleSelf
+// matches when id is the caller's own user id.
+func authorize(ctx context.Context, id int32, roles ...string) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if ok {
+		for _, role := range roles {
+			if (role == auth.RoleSelf && principal.IsSelf(id)) || principal.HasRole(role) {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.PermissionDenied, "not allowed to perform this operation")
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
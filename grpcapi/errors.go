@@ -0,0 +1,68 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	services "user-manager/internal"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// requireVersion refuses writes that do not name the version they are based
+// on. gRPC has no equivalent of If-Match: *, so the version is always required.
+func requireVersion(version int32) error {
+	if version <= 0 {
+		return status.Error(codes.FailedPrecondition, "version is required")
+	}
+	return nil
+}
+
+// statusError maps an error returned by the services package to a gRPC status.
+// Field errors are attached as a BadRequest detail, and the error code as the
+// reason of an ErrorInfo detail.
+func statusError(ctx context.Context, err error) error {
+	var serviceErr *services.Error
+	if !errors.As(err, &serviceErr) {
+		slog.ErrorContext(ctx, "unexpected error", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		code = codes.InvalidArgument
+	case errors.Is(err, services.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, services.ErrConflict):
+		code = codes.AlreadyExists
+	case errors.Is(err, services.ErrUnsupported):
+		code = codes.InvalidArgument
+	case errors.Is(err, services.ErrForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, services.ErrPrecondition), errors.Is(err, services.ErrPreconditionRequired):
+		code = codes.FailedPrecondition
+	case errors.Is(err, services.ErrUnauthenticated):
+		code = codes.Unauthenticated
+	case errors.Is(err, services.ErrAborted):
+		code = codes.Aborted
+	}
+
+	st := status.New(code, serviceErr.Detail)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: serviceErr.Code, Domain: "user-manager"}}
+	if len(serviceErr.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(serviceErr.Fields))
+		for _, field := range serviceErr.Fields {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field.Field, Description: field.Message})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,66 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+	"user-manager/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the metadata key of the request ID, like the X-Request-Id
+// header of the REST API.
+const requestIDKey = "x-request-id"
+
+// logUnary gives the call a request ID and logs it once it returns.
+func logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = withRequestID(ctx)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func logStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := withRequestID(stream.Context())
+	start := time.Now()
+	err := handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// withRequestID reads the request ID of the call metadata, or generates one,
+// and sends it back in the header.
+func withRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var requestID string
+	if values := md.Get(requestIDKey); len(values) > 0 && values[0] != "" {
+		requestID = values[0]
+	} else {
+		b := make([]byte, 8)
+		rand.Read(b)
+		requestID = hex.EncodeToString(b)
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+	return logging.WithRequestID(ctx, requestID)
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "call served",
+		"method", method,
+		"code", code.String(),
+		"duration", time.Since(start),
+	)
+}
@@ -0,0 +1,211 @@
+// Package grpcapi serves the UserService gRPC API defined in proto/user/v1.
+// It shares the business logic of the REST API in the services package.
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+	services "user-manager/internal"
+	userv1 "user-manager/proto/user/v1"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	userv1.UnimplementedUserServiceServer
+
+	Queries  database.Querier
+	Verifier *auth.Verifier
+	// Health reports the serving status of the server and of UserService.
+	Health *health.Server
+}
+
+func NewServer(queries database.Querier, verifier *auth.Verifier) *Server {
+	return &Server{
+		Queries:  queries,
+		Verifier: verifier,
+		Health:   health.NewServer(),
+	}
+}
+
+// GRPCServer returns a grpc.Server serving UserService, health checks and reflection.
+func (s *Server) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(logUnary, s.authenticateUnary),
+		grpc.ChainStreamInterceptor(logStream, s.authenticateStream),
+	)
+	userv1.RegisterUserServiceServer(srv, s)
+	grpc_health_v1.RegisterHealthServer(srv, s.Health)
+	reflection.Register(srv)
+
+	s.Health.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	return srv
+}
+
+func (s *Server) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.User, error) {
+	err := authorize(ctx, 0, auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := services.CreateUser(ctx, userFromInput(req.GetUser()), s.Queries)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userToProto(user), nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	err := authorize(ctx, req.GetId(), auth.RoleAdmin, auth.RoleSupport, auth.RoleSelf)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := services.GetUser(ctx, int(req.GetId()), req.GetIncludeDeleted(), s.Queries)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userToProto(user), nil
+}
+
+func (s *Server) ListUsers(req *userv1.ListUsersRequest, stream grpc.ServerStreamingServer[userv1.User]) error {
+	ctx := stream.Context()
+	err := authorize(ctx, 0, auth.RoleAdmin, auth.RoleSupport)
+	if err != nil {
+		return err
+	}
+
+	params := dto.UserListParams{
+		Sort:           req.GetSort(),
+		Order:          req.GetOrder(),
+		Status:         req.GetStatus(),
+		MinAge:         req.MinAge,
+		MaxAge:         req.MaxAge,
+		EmailPrefix:    req.GetEmailPrefix(),
+		NamePrefix:     req.GetNamePrefix(),
+		IncludeDeleted: req.GetIncludeDeleted(),
+	}
+	err = services.StreamUsers(ctx, params, func(u database.User) error {
+		return stream.Send(userToProto(&u))
+	}, s.Queries)
+	if err != nil {
+		return statusError(ctx, err)
+	}
+	return nil
+}
+
+func (s *Server) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.User, error) {
+	err := authorize(ctx, req.GetId(), auth.RoleAdmin, auth.RoleSupport, auth.RoleSelf)
+	if err != nil {
+		return nil, err
+	}
+	err = requireVersion(req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	var user *database.User
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		user, err = services.UpdateUser(ctx, int(req.GetId()), req.GetVersion(), userFromInput(req.GetUser()), s.Queries)
+	} else {
+		var patch []byte
+		patch, err = mergePatch(req.GetUser(), paths)
+		if err != nil {
+			return nil, err
+		}
+		user, err = services.PatchUser(ctx, int(req.GetId()), req.GetVersion(), services.MergePatchType, patch, s.Queries)
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userToProto(user), nil
+}
+
+func (s *Server) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*emptypb.Empty, error) {
+	err := authorize(ctx, req.GetId(), auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	err = requireVersion(req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	err = services.DeleteUser(ctx, int(req.GetId()), req.GetVersion(), s.Queries)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// maskFields maps the update_mask paths to the dto.User JSON fields and their value in a UserInput.
+var maskFields = map[string]struct {
+	field string
+	value func(in *userv1.UserInput) any
+}{
+	"first_name": {"firstName", func(in *userv1.UserInput) any { return in.GetFirstName() }},
+	"last_name":  {"lastName", func(in *userv1.UserInput) any { return in.GetLastName() }},
+	"email":      {"email", func(in *userv1.UserInput) any { return in.GetEmail() }},
+	"phone":      {"phone", func(in *userv1.UserInput) any { return in.Phone }},
+	"age":        {"age", func(in *userv1.UserInput) any { return in.Age }},
+	"status":     {"status", func(in *userv1.UserInput) any { return in.GetStatus() }},
+}
+
+// mergePatch returns the JSON Merge Patch setting the fields of paths to their
+// value in input. Unset optional fields are null, which clears them.
+func mergePatch(input *userv1.UserInput, paths []string) ([]byte, error) {
+	patch := map[string]any{}
+	for _, path := range paths {
+		mask, ok := maskFields[path]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown update_mask path %q", path)
+		}
+		patch[mask.field] = mask.value(input)
+	}
+	return json.Marshal(patch)
+}
+
+func userFromInput(in *userv1.UserInput) dto.User {
+	return dto.User{
+		Firstname: in.GetFirstName(),
+		Lastname:  in.GetLastName(),
+		Email:     in.GetEmail(),
+		Phone:     in.Phone,
+		Age:       in.Age,
+		Status:    in.GetStatus(),
+	}
+}
+
+func userToProto(u *database.User) *userv1.User {
+	user := &userv1.User{
+		Id:        u.Userid,
+		FirstName: u.Firstname,
+		LastName:  u.Lastname,
+		Email:     u.Email,
+		Status:    string(u.UserStatus.Userstatus),
+		Version:   u.Version,
+	}
+	if u.Phone.Valid {
+		user.Phone = &u.Phone.String
+	}
+	if u.Age.Valid {
+		user.Age = &u.Age.Int32
+	}
+	if u.DeletedAt.Valid {
+		user.DeletedAt = timestamppb.New(u.DeletedAt.Time)
+	}
+	return user
+}
@@ -0,0 +1,61 @@
+package grpcapi
+
+import (
+	"errors"
+	"testing"
+	"user-manager/dto"
+	services "user-manager/internal"
+	userv1 "user-manager/proto/user/v1"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMergePatch(t *testing.T) {
+	age := int32(30)
+	patch, err := mergePatch(&userv1.UserInput{FirstName: "Jay", Age: &age}, []string{"first_name", "age", "phone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(patch) != `{"age":30,"firstName":"Jay","phone":null}` {
+		t.Errorf("Test Failure! Unexpected patch %s", patch)
+	}
+
+	_, err = mergePatch(&userv1.UserInput{}, []string{"password"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Test Failure! Expected InvalidArgument for an unknown path. Received %v", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := statusError(t.Context(), &services.Error{
+		Kind:   services.ErrValidation,
+		Code:   services.CodeValidationFailed,
+		Detail: "Request has invalid fields",
+		Fields: []dto.FieldError{{Field: "email", Code: "invalid_email", Message: "email must be a valid email"}},
+	})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || st.Message() != "Request has invalid fields" {
+		t.Errorf("Test Failure! Unexpected status %v", st)
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.GetFieldViolations()
+		}
+	}
+	if len(violations) != 1 || violations[0].GetField() != "email" {
+		t.Errorf("Test Failure! Expected the email field violation. Received %v", violations)
+	}
+
+	if status.Code(statusError(t.Context(), &services.Error{Kind: services.ErrPrecondition, Code: services.CodeVersionMismatch})) != codes.FailedPrecondition {
+		t.Errorf("Test Failure! Expected FailedPrecondition for a version mismatch")
+	}
+	if status.Code(requireVersion(0)) != codes.FailedPrecondition || requireVersion(3) != nil {
+		t.Errorf("Test Failure! Expected FailedPrecondition without a version")
+	}
+	if status.Code(statusError(t.Context(), errors.New("connection refused"))) != codes.Internal {
+		t.Errorf("Test Failure! Expected Internal for unexpected errors")
+	}
+}
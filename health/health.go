@@ -0,0 +1,119 @@
+// Package health serves the liveness and readiness probes of the service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds the checks of a readiness probe.
+const checkTimeout = 3 * time.Second
+
+// Check reports why a dependency of the service is not ready, or nil.
+type Check func(ctx context.Context) error
+
+// Result is the JSON body of the probes.
+type Result struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// Checker runs the readiness checks. Readiness fails once Shutdown is called,
+// so that load balancers stop routing requests before the server stops.
+type Checker struct {
+	names        []string
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{checks: map[string]Check{}}
+}
+
+// Add registers a readiness check. Checks are added before the probes are served.
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// Shutdown makes the readiness probe fail from now on.
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Live answers the liveness probe: the process is running and serving HTTP.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, r, http.StatusOK, Result{Status: statusOK})
+}
+
+// Ready answers the readiness probe, running the checks concurrently. It fails
+// with 503 when a check fails or the server is shutting down.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	result := Result{Status: statusOK, Checks: map[string]CheckResult{}}
+	if c.shuttingDown.Load() {
+		result.Status = statusFail
+		result.Checks["shutdown"] = CheckResult{Status: statusFail, Error: "server is shutting down", Duration: "0s"}
+		writeResult(w, r, http.StatusServiceUnavailable, result)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range c.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.checks[name](ctx)
+			check := CheckResult{Status: statusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				check.Status = statusFail
+				check.Error = err.Error()
+				slog.WarnContext(ctx, "readiness check failed", "check", name, "error", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Checks[name] = check
+			if err != nil {
+				result.Status = statusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if result.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeResult(w, r, status, result)
+}
+
+func writeResult(w http.ResponseWriter, r *http.Request, status int, result Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(result)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing response", "error", err)
+	}
+}
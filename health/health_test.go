@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, Result) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var result Result
+	err := json.Unmarshal(rec.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, result
+}
+
+func TestReady(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(context.Context) error { return nil })
+
+	code, result := probe(t, checker.Ready)
+	if code != http.StatusOK || result.Status != statusOK || result.Checks["database"].Status != statusOK {
+		t.Errorf("Test Failure! Expected ready, got %d %+v", code, result)
+	}
+}
+
+func TestReadyFailingCheck(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(context.Context) error { return nil })
+	checker.Add("migrations", func(context.Context) error { return errors.New("2 migrations pending") })
+
+	code, result := probe(t, checker.Ready)
+	if code != http.StatusServiceUnavailable || result.Status != statusFail {
+		t.Errorf("Test Failure! Expected not ready, got %d %+v", code, result)
+	}
+	if result.Checks["migrations"].Error != "2 migrations pending" || result.Checks["database"].Status != statusOK {
+		t.Errorf("Test Failure! Unexpected checks: %+v", result.Checks)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(context.Context) error { return nil })
+	checker.Shutdown()
+
+	code, result := probe(t, checker.Ready)
+	if code != http.StatusServiceUnavailable || result.Checks["shutdown"].Status != statusFail {
+		t.Errorf("Test Failure! Expected not ready at shutdown, got %d %+v", code, result)
+	}
+
+	code, result = probe(t, checker.Live)
+	if code != http.StatusOK || result.Status != statusOK {
+		t.Errorf("Test Failure! Expected live at shutdown, got %d %+v", code, result)
+	}
+}
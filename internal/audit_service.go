@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fieldChange is one entry of the changes recorded by an audit event.
+type fieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// recordAudit stores an audit event for a change of a user row and queues its
+// webhook events. Pass the transaction making the change as q so that they
+// all commit together.
+func recordAudit(ctx context.Context, action database.Auditaction, before *database.User, after *database.User, q database.Querier) error {
+	event := database.CreateAuditEventParams{
+		Action:  action,
+		Changes: []byte("{}"),
+	}
+
+	var beforeFields, afterFields map[string]any
+	var err error
+	if before != nil {
+		event.Userid = before.Userid
+		event.Before, beforeFields, err = auditSnapshot(before)
+		if err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		event.Userid = after.Userid
+		event.After, afterFields, err = auditSnapshot(after)
+		if err != nil {
+			return err
+		}
+	}
+
+	changes := map[string]fieldChange{}
+	for field, to := range afterFields {
+		from := beforeFields[field]
+		if !reflect.DeepEqual(from, to) {
+			changes[field] = fieldChange{From: from, To: to}
+		}
+	}
+	event.Changes, err = json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.UserID != 0 {
+		event.ActorID = pgtype.Int4{Int32: principal.UserID, Valid: true}
+	}
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		event.RequestID = pgtype.Text{String: requestID, Valid: true}
+	}
+
+	err = q.CreateAuditEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+	return enqueueWebhookEvents(ctx, action, before, after, q)
+}
+
+// auditSnapshot returns the JSON of a user row and its fields.
+func auditSnapshot(u *database.User) ([]byte, map[string]any, error) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return data, fields, err
+}
+
+// ListAuditEvents returns a page of the audit events of a user, newest first.
+// Events are kept after the user is purged.
+func ListAuditEvents(ctx context.Context, id int, params dto.AuditListParams, q database.Querier) (*dto.AuditPage, error) {
+	ctx, span := startSpan(ctx, "ListAuditEvents", userIDAttr(id))
+	defer span.End()
+
+	err := validate(params)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	var beforeID int64
+	if params.Cursor != "" {
+		beforeID, err = decodeAuditCursor(params.Cursor)
+		if err != nil {
+			return nil, validationError(CodeInvalidCursor, "cursor is malformed")
+		}
+	}
+
+	events, err := q.ListAuditEvents(ctx, database.ListAuditEventsParams{
+		Userid:     int32(id),
+		BeforeID:   beforeID,
+		MaxResults: limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dto.AuditPage{Events: []dto.AuditEvent{}}
+	if int32(len(events)) > limit {
+		events = events[:limit]
+		page.NextCursor = encodeAuditCursor(events[len(events)-1].ID)
+	}
+	for _, event := range events {
+		page.Events = append(page.Events, auditEventFromDB(event))
+	}
+	return page, nil
+}
+
+func auditEventFromDB(e database.AuditEvent) dto.AuditEvent {
+	event := dto.AuditEvent{
+		ID:        e.ID,
+		UserID:    e.Userid,
+		Action:    string(e.Action),
+		RequestID: e.RequestID.String,
+		Before:    jsonOrNull(e.Before),
+		After:     jsonOrNull(e.After),
+		Changes:   jsonOrNull(e.Changes),
+		CreatedAt: e.CreatedAt.Time,
+	}
+	if e.ActorID.Valid {
+		event.ActorID = &e.ActorID.Int32
+	}
+	return event
+}
+
+func jsonOrNull(data []byte) json.RawMessage {
+	if data == nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+func encodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeAuditCursor(s string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
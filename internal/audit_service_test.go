@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+func TestUpdateUserRecordsAudit(t *testing.T) {
+	db := &MockDb{}
+	ctx := asCaller(t.Context(), 7, auth.RoleAdmin)
+
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "jay.vas@gmail.com",
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
+		Status:    string(database.UserstatusActive),
+	}
+
+	_, err := UpdateUser(ctx, 1, AnyVersion, user, db)
+	fmt.Println("error: ", err)
+
+	if err != nil || len(db.Audit) != 1 {
+		t.Fatalf("Test Failure! Audit event not recorded")
+	}
+
+	event := db.Audit[0]
+	var changes map[string]fieldChange
+	err = json.Unmarshal(event.Changes, &changes)
+	if err != nil || len(changes) != 2 || changes["Email"].To != "jay.vas@gmail.com" {
+		t.Errorf("Test Failure! Incorrect changes %s", event.Changes)
+	}
+	if event.Action != database.AuditactionUpdate || event.ActorID.Int32 != 7 || event.Before == nil {
+		t.Errorf("Test Failure! Incorrect audit event")
+	}
+}
+
+func TestCreateUserRecordsAudit(t *testing.T) {
+	db := &MockDb{}
+
+	_, err := CreateUser(t.Context(), dto.User{Firstname: "Jay", Lastname: "Vas", Email: "jay@gmail.com"}, db)
+	fmt.Println("error: ", err)
+
+	if err != nil || len(db.Audit) != 1 || db.Audit[0].Before != nil || db.Audit[0].ActorID.Valid {
+		t.Errorf("Test Failure! Incorrect audit event")
+	}
+}
+
+func TestListAuditEventsPages(t *testing.T) {
+	db := &MockDb{}
+
+	page, err := ListAuditEvents(t.Context(), 1, dto.AuditListParams{Limit: 20}, db)
+	fmt.Println("error: ", err)
+	if err != nil || len(page.Events) != 20 || page.NextCursor == "" {
+		t.Fatalf("Test Failure! Incorrect first page")
+	}
+
+	next, err := ListAuditEvents(t.Context(), 1, dto.AuditListParams{Limit: 20, Cursor: page.NextCursor}, db)
+	fmt.Println("error: ", err)
+	if err != nil || next.Events[0].ID != page.Events[19].ID-1 {
+		t.Errorf("Test Failure! Incorrect next page")
+	}
+}
+
+func TestListAuditEventsInvalidCursor(t *testing.T) {
+	_, err := ListAuditEvents(t.Context(), 1, dto.AuditListParams{Cursor: "not a cursor"}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
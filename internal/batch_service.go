@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+// errBatchFailed rolls back an atomic batch with a failed operation.
+var errBatchFailed = errors.New("batch operation failed")
+
+// BatchResult is the outcome of one operation of a batch. Err is nil when the
+// operation succeeded.
+type BatchResult struct {
+	User *database.User
+	Err  error
+}
+
+// RunBatch runs the operations of a batch in order in a single transaction
+// and returns their results and whether the transaction was committed.
+// Atomic batches are rolled back by the first failed operation. Otherwise every
+// operation runs in its own savepoint, so a failure only undoes that operation.
+func RunBatch(ctx context.Context, req dto.BatchRequest, q database.Querier) ([]BatchResult, bool, error) {
+	ctx, span := startSpan(ctx, "RunBatch")
+	defer span.End()
+
+	err := validate(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	results := make([]BatchResult, len(req.Operations))
+	failed := -1
+	err = q.ExecTx(ctx, func(tx database.Querier) error {
+		for i, op := range req.Operations {
+			if req.Atomic {
+				results[i].User, results[i].Err = runOperation(ctx, op, tx)
+				if results[i].Err != nil {
+					failed = i
+					return errBatchFailed
+				}
+				continue
+			}
+
+			err := tx.ExecTx(ctx, func(opTx database.Querier) error {
+				var err error
+				results[i].User, err = runOperation(ctx, op, opTx)
+				return err
+			})
+			if err != nil {
+				results[i] = BatchResult{Err: err}
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		return nil, false, err
+	}
+
+	if failed < 0 {
+		return results, true, nil
+	}
+	for i := range results {
+		switch {
+		case i < failed:
+			results[i] = BatchResult{Err: &Error{Kind: ErrAborted, Code: CodeBatchAborted, Detail: fmt.Sprintf("Rolled back because operation %d failed", failed)}}
+		case i > failed:
+			results[i] = BatchResult{Err: &Error{Kind: ErrAborted, Code: CodeBatchAborted, Detail: fmt.Sprintf("Not run because operation %d failed", failed)}}
+		}
+	}
+	return results, false, nil
+}
+
+func runOperation(ctx context.Context, op dto.BatchOperation, q database.Querier) (*database.User, error) {
+	err := validate(op)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "create":
+		var user dto.User
+		decoder := json.NewDecoder(bytes.NewReader(op.User))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&user)
+		if err != nil {
+			return nil, &Error{Kind: ErrValidation, Code: CodeMalformedOp, Detail: "Invalid user: " + err.Error(), Err: err}
+		}
+		return CreateUser(ctx, user, q)
+	case "patch":
+		patchType := MergePatchType
+		if bytes.HasPrefix(bytes.TrimSpace(op.User), []byte("[")) {
+			patchType = JSONPatchType
+		}
+		return PatchUser(ctx, int(op.ID), op.Version, patchType, op.User, q)
+	default:
+		return nil, DeleteUser(ctx, int(op.ID), op.Version, q)
+	}
+}
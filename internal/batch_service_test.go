@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"user-manager/dto"
+)
+
+func batchOperations() []dto.BatchOperation {
+	return []dto.BatchOperation{
+		{Op: "create", User: json.RawMessage(`{"firstName": "Jay", "lastName": "Vas", "email": "jay@gmail.com"}`)},
+		{Op: "create", User: json.RawMessage(`{"firstName": "Jay", "lastName": "Vas"}`)},
+		{Op: "patch", ID: 1, Version: 1, User: json.RawMessage(`[{"op": "replace", "path": "/age", "value": 31}]`)},
+		{Op: "delete", ID: 2, Version: 1},
+		{Op: "delete", ID: 1, Version: 1},
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	results, committed, err := RunBatch(t.Context(), dto.BatchRequest{Operations: batchOperations()}, &MockDb{})
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !committed || len(results) != 5 {
+		t.Fatalf("Test Failure! Batch not committed")
+	}
+	if results[0].Err != nil || results[0].User == nil || results[2].Err != nil || results[4].Err != nil {
+		t.Errorf("Test Failure! Valid operations failed: %v %v %v", results[0].Err, results[2].Err, results[4].Err)
+	}
+	if !errors.Is(results[1].Err, ErrValidation) || !errors.Is(results[3].Err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect errors: %v %v", results[1].Err, results[3].Err)
+	}
+}
+
+func TestRunBatchAtomic(t *testing.T) {
+	results, committed, err := RunBatch(t.Context(), dto.BatchRequest{Atomic: true, Operations: batchOperations()}, &MockDb{})
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if committed {
+		t.Errorf("Test Failure! Atomic batch with a failed operation committed")
+	}
+	if !errors.Is(results[0].Err, ErrAborted) || !errors.Is(results[1].Err, ErrValidation) || !errors.Is(results[4].Err, ErrAborted) {
+		t.Errorf("Test Failure! Incorrect errors: %v %v %v", results[0].Err, results[1].Err, results[4].Err)
+	}
+}
+
+func TestRunBatchInvalidOperation(t *testing.T) {
+	results, _, err := RunBatch(t.Context(), dto.BatchRequest{Operations: []dto.BatchOperation{{Op: "delete"}, {Op: "upsert"}}}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil || !errors.Is(results[0].Err, ErrValidation) || !errors.Is(results[1].Err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect errors")
+	}
+
+	// patches and deletes must name the version they are based on
+	results, _, err = RunBatch(t.Context(), dto.BatchRequest{Operations: []dto.BatchOperation{
+		{Op: "delete", ID: 1},
+		{Op: "patch", ID: 1, Version: -1, User: json.RawMessage(`{"age": 40}`)},
+	}}, &MockDb{})
+	if err != nil || !errors.Is(results[0].Err, ErrValidation) || !errors.Is(results[1].Err, ErrValidation) {
+		t.Errorf("Test Failure! Operations without a version accepted: %+v", results)
+	}
+
+	_, _, err = RunBatch(t.Context(), dto.BatchRequest{}, &MockDb{})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Empty batch accepted")
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash is verified against when the email is unknown, so that
+// response times do not reveal which emails have an account.
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = auth.HashPassword("user-manager-dummy-password", passwordHash)
+	})
+	return dummyHash
+}
+
+func invalidCredentials() *Error {
+	return &Error{Kind: ErrUnauthenticated, Code: CodeInvalidCredential, Detail: "Invalid email or password"}
+}
+
+func invalidRefreshToken(err error) *Error {
+	return &Error{Kind: ErrUnauthenticated, Code: CodeInvalidToken, Detail: "Invalid or expired refresh token", Err: err}
+}
+
+// Login checks the email and password and issues a new token pair.
+func Login(ctx context.Context, login dto.LoginRequest, issuer *auth.Issuer, q database.Querier) (*dto.TokenPair, error) {
+	ctx, span := startSpan(ctx, "Login")
+	defer span.End()
+
+	err := validate(login)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	credential, err := q.GetCredentialByEmail(ctx, login.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		auth.VerifyPassword(dummyPasswordHash(), login.Password, passwordHash)
+		return nil, invalidCredentials()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ok, needsRehash := auth.VerifyPassword(credential.PasswordHash, login.Password, passwordHash)
+	if !ok {
+		return nil, invalidCredentials()
+	}
+	if credential.UserStatus.Valid && credential.UserStatus.Userstatus == database.UserstatusInactive {
+		return nil, &Error{Kind: ErrForbidden, Code: CodeAccountInactive, Detail: "The account is inactive"}
+	}
+
+	if needsRehash {
+		err = setPasswordHash(ctx, credential.Userid, login.Password, q)
+		if err != nil {
+			slog.WarnContext(ctx, "error on rehashing password", "user_id", credential.Userid, "error", err)
+		}
+	}
+
+	return issueTokens(ctx, credential.Userid, issuer, q)
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. Refresh tokens
+// are single use: presenting a revoked token again revokes every refresh
+// token of the user, as the token was likely stolen.
+func RefreshTokens(ctx context.Context, refresh dto.RefreshRequest, issuer *auth.Issuer, q database.Querier) (*dto.TokenPair, error) {
+	ctx, span := startSpan(ctx, "RefreshTokens")
+	defer span.End()
+
+	err := validate(refresh)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	hash := auth.HashRefreshToken(refresh.RefreshToken)
+	userID, err := q.RevokeRefreshToken(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		token, lookupErr := q.GetRefreshToken(ctx, hash)
+		if lookupErr == nil && token.RevokedAt.Valid {
+			slog.WarnContext(ctx, "revoked refresh token reused", "user_id", token.Userid)
+			_, lookupErr = q.RevokeUserRefreshTokens(ctx, token.Userid)
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+		}
+		return nil, invalidRefreshToken(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := q.GetUser(ctx, database.GetUserParams{Userid: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, invalidRefreshToken(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.UserStatus.Valid && user.UserStatus.Userstatus == database.UserstatusInactive {
+		return nil, &Error{Kind: ErrForbidden, Code: CodeAccountInactive, Detail: "The account is inactive"}
+	}
+
+	return issueTokens(ctx, userID, issuer, q)
+}
+
+// Logout revokes the access token in claims and, when given, the refresh token.
+func Logout(ctx context.Context, claims *auth.Claims, logout dto.LogoutRequest, q database.Querier) error {
+	ctx, span := startSpan(ctx, "Logout")
+	defer span.End()
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		err := q.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+			Jti:       claims.ID,
+			ExpiresAt: pgtype.Timestamptz{Time: claims.ExpiresAt.Time, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if logout.RefreshToken == "" {
+		return nil
+	}
+	_, err := q.RevokeRefreshToken(ctx, auth.HashRefreshToken(logout.RefreshToken))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// IsTokenRevoked reports whether the access token was revoked by a logout.
+func IsTokenRevoked(ctx context.Context, claims *auth.Claims, q database.Querier) (bool, error) {
+	ctx, span := startSpan(ctx, "IsTokenRevoked")
+	defer span.End()
+
+	if claims.ID == "" {
+		return false, nil
+	}
+	return q.IsAccessTokenRevoked(ctx, claims.ID)
+}
+
+// SetPassword sets the password of the user. Callers changing their own
+// existing password must also supply the current one.
+func SetPassword(ctx context.Context, id int, change dto.PasswordChange, q database.Querier) error {
+	ctx, span := startSpan(ctx, "SetPassword", userIDAttr(id))
+	defer span.End()
+
+	err := validate(change)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return err
+	}
+
+	_, err = GetUser(ctx, id, false, q)
+	if err != nil {
+		return err
+	}
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if ok && principal.IsSelf(int32(id)) {
+		credential, err := q.GetCredential(ctx, int32(id))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			valid, _ := auth.VerifyPassword(credential.PasswordHash, change.CurrentPassword, passwordHash)
+			if !valid {
+				return validationError(CodeInvalidCredential, "Current password is incorrect", dto.FieldError{
+					Field:   "current_password",
+					Tag:     "password",
+					Code:    "incorrect_password",
+					Message: "current_password is incorrect",
+				})
+			}
+		}
+	}
+
+	err = setPasswordHash(ctx, int32(id), change.NewPassword, q)
+	if err != nil {
+		return err
+	}
+
+	// sessions started with the old password must sign in again
+	_, err = q.RevokeUserRefreshTokens(ctx, int32(id))
+	return err
+}
+
+func setPasswordHash(ctx context.Context, userID int32, password string, q database.Querier) error {
+	hash, err := auth.HashPassword(password, passwordHash)
+	if err != nil {
+		return err
+	}
+	return q.UpsertCredential(ctx, database.UpsertCredentialParams{
+		Userid:       userID,
+		PasswordHash: hash,
+	})
+}
+
+func issueTokens(ctx context.Context, userID int32, issuer *auth.Issuer, q database.Querier) (*dto.TokenPair, error) {
+	accessToken, _, err := issuer.AccessToken(strconv.Itoa(int(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := issuer.RefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: hash,
+		Userid:    userID,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(issuer.RefreshTTL), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(issuer.AccessTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"user-manager/auth"
+	"user-manager/config"
+	"user-manager/dto"
+)
+
+const testSecret = "user-manager-unit-test-secret-value"
+
+func testIssuer(t *testing.T) *auth.Issuer {
+	issuer, err := auth.NewIssuer(testSecret, "", "", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+func mockWithPassword(t *testing.T, password string, algorithm string) *MockDb {
+	hash, err := auth.HashPassword(password, algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MockDb{PasswordHash: hash}
+}
+
+func TestLogin(t *testing.T) {
+	db := mockWithPassword(t, "correct horse battery", auth.Argon2id)
+
+	tokens, err := Login(t.Context(), dto.LoginRequest{Email: "jay@gmail.com", Password: "correct horse battery"}, testIssuer(t), db)
+	fmt.Println("error: ", err)
+
+	if err != nil || tokens.AccessToken == "" || tokens.RefreshToken == "" || len(db.RefreshTokens) != 1 {
+		t.Errorf("Test Failure! Valid login rejected")
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	db := mockWithPassword(t, "correct horse battery", auth.Argon2id)
+
+	_, err := Login(t.Context(), dto.LoginRequest{Email: "jay@gmail.com", Password: "wrong horse battery"}, testIssuer(t), db)
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestLoginUnknownEmail(t *testing.T) {
+	db := mockWithPassword(t, "correct horse battery", auth.Argon2id)
+
+	_, err := Login(t.Context(), dto.LoginRequest{Email: "nobody@gmail.com", Password: "correct horse battery"}, testIssuer(t), db)
+	fmt.Println("error: ", err)
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || serviceErr.Code != CodeInvalidCredential {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestLoginRehashesBcrypt(t *testing.T) {
+	db := mockWithPassword(t, "correct horse battery", auth.Bcrypt)
+	bcryptHash := db.PasswordHash
+
+	_, err := Login(t.Context(), dto.LoginRequest{Email: "jay@gmail.com", Password: "correct horse battery"}, testIssuer(t), db)
+	fmt.Println("error: ", err)
+
+	if err != nil || db.PasswordHash == bcryptHash {
+		t.Errorf("Test Failure! bcrypt hash not upgraded")
+	}
+}
+
+func TestRefreshTokensRotates(t *testing.T) {
+	db := mockWithPassword(t, "correct horse battery", auth.Argon2id)
+	issuer := testIssuer(t)
+
+	tokens, err := Login(t.Context(), dto.LoginRequest{Email: "jay@gmail.com", Password: "correct horse battery"}, issuer, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := RefreshTokens(t.Context(), dto.RefreshRequest{RefreshToken: tokens.RefreshToken}, issuer, db)
+	fmt.Println("error: ", err)
+	if err != nil || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("Test Failure! Refresh token not rotated")
+	}
+
+	// reusing the old token revokes every token of the user
+	_, err = RefreshTokens(t.Context(), dto.RefreshRequest{RefreshToken: tokens.RefreshToken}, issuer, db)
+	fmt.Println("error: ", err)
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Test Failure! Reused refresh token accepted")
+	}
+	_, err = RefreshTokens(t.Context(), dto.RefreshRequest{RefreshToken: refreshed.RefreshToken}, issuer, db)
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Test Failure! Tokens not revoked after reuse")
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	db := &MockDb{}
+	_, claims, err := testIssuer(t).AccessToken("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Logout(t.Context(), claims, dto.LogoutRequest{}, db)
+	fmt.Println("error: ", err)
+
+	revoked, _ := IsTokenRevoked(t.Context(), claims, db)
+	if err != nil || !revoked {
+		t.Errorf("Test Failure! Access token not revoked")
+	}
+}
+
+func TestSetPasswordPolicy(t *testing.T) {
+	ConfigurePasswords(config.PasswordPolicy{MinLength: 12, RequireDigit: true}, auth.Argon2id)
+	defer ConfigurePasswords(config.PasswordPolicy{MinLength: 12}, auth.Argon2id)
+
+	err := SetPassword(t.Context(), 1, dto.PasswordChange{NewPassword: "longbutnodigits"}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || serviceErr.Fields[0].Code != "weak_password" {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestSetPasswordSelfRequiresCurrent(t *testing.T) {
+	db := mockWithPassword(t, "correct horse battery", auth.Argon2id)
+	ctx := asCaller(t.Context(), 1)
+
+	err := SetPassword(ctx, 1, dto.PasswordChange{CurrentPassword: "wrong", NewPassword: "another horse battery"}, db)
+	fmt.Println("error: ", err)
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+
+	err = SetPassword(ctx, 1, dto.PasswordChange{CurrentPassword: "correct horse battery", NewPassword: "another horse battery"}, db)
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Errorf("Test Failure! Password change rejected")
+	}
+}
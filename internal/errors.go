@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error kinds returned by the services package. Use errors.Is to classify an error.
+var (
+	ErrValidation  = errors.New("validation failed")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrUnsupported = errors.New("unsupported media type")
+	ErrForbidden   = errors.New("forbidden")
+	// ErrPrecondition is returned when the user changed since the version the caller read.
+	ErrPrecondition = errors.New("precondition failed")
+	// ErrPreconditionRequired is returned for writes that do not name the version they are based on.
+	ErrPreconditionRequired = errors.New("precondition required")
+	// ErrUnauthenticated is returned when credentials or tokens are not accepted.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrAborted is returned for the operations of an atomic batch rolled back or not run.
+	ErrAborted = errors.New("aborted")
+)
+
+// Stable, machine readable error codes.
+const (
+	CodeValidationFailed  = "validation_failed"
+	CodeInvalidCursor     = "invalid_cursor"
+	CodeInvalidPatch      = "invalid_patch"
+	CodeUserNotFound      = "user_not_found"
+	CodeDuplicateField    = "duplicate_field"
+	CodeUnsupportedFormat = "unsupported_media_type"
+	CodeForbidden         = "forbidden"
+	CodeInvalidCredential = "invalid_credentials"
+	CodeInvalidToken      = "invalid_refresh_token"
+	CodeUnknownSubject    = "unknown_subject"
+	CodeAccountInactive   = "account_inactive"
+	CodeVersionMismatch   = "version_mismatch"
+	CodeVersionRequired   = "precondition_required"
+	CodeInvalidImport     = "invalid_import"
+	CodeMalformedOp       = "malformed_operation"
+	CodeBatchAborted      = "batch_aborted"
+	CodeWebhookNotFound   = "webhook_not_found"
+	CodeWebhookURL        = "webhook_url_not_allowed"
+	CodeDeliveryNotFound  = "delivery_not_found"
+)
+
+// Error is a domain error. Kind is one of the Err* sentinels, Code is a
+// stable identifier for clients and Fields lists every offending field.
+type Error struct {
+	Kind   error
+	Code   string
+	Detail string
+	Fields []dto.FieldError
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Detail)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+func validationError(code string, detail string, fields ...dto.FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Detail: detail, Fields: fields}
+}
+
+func notFoundError(err error) *Error {
+	return &Error{Kind: ErrNotFound, Code: CodeUserNotFound, Detail: "User not found", Err: err}
+}
+
+func versionMismatchError(err error) *Error {
+	return &Error{Kind: ErrPrecondition, Code: CodeVersionMismatch, Detail: "User was modified since it was read", Err: err}
+}
+
+// AnyVersion is the version of writes that apply to the user whatever its
+// version, like If-Match: *. The zero version is refused rather than skipping
+// the check, so that overwriting unseen changes is always asked for.
+const AnyVersion int32 = -1
+
+// checkVersion fails when the user is at another version than version.
+func checkVersion(current database.User, version int32) error {
+	switch {
+	case version == AnyVersion:
+		return nil
+	case version <= 0:
+		return &Error{Kind: ErrPreconditionRequired, Code: CodeVersionRequired, Detail: "The version of the user is required"}
+	case current.Version != version:
+		return versionMismatchError(nil)
+	}
+	return nil
+}
+
+// newValidator returns a validator that reports fields by their json name.
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	validate.RegisterValidation("password", validatePassword)
+	return validate
+}
+
+// validate checks v against its validate tags and reports every failing field.
+func validate(v any) error {
+	err := newValidator().Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fields := make([]dto.FieldError, 0, len(errs))
+	for _, fieldErr := range errs {
+		fields = append(fields, dto.FieldError{
+			Field:   fieldErr.Field(),
+			Tag:     fieldErr.Tag(),
+			Code:    fieldCode(fieldErr.Tag()),
+			Message: formatError(fieldErr),
+		})
+	}
+	return validationError(CodeValidationFailed, "Validation failed on "+fmt.Sprint(len(fields))+" field(s)", fields...)
+}
+
+// fieldCodes maps validator tags to stable field error codes.
+var fieldCodes = map[string]string{
+	"required":      "required",
+	"email":         "invalid_email",
+	"min":           "too_short",
+	"max":           "too_long",
+	"gt":            "out_of_range",
+	"gte":           "out_of_range",
+	"lte":           "out_of_range",
+	"e164":          "invalid_phone",
+	"oneof":         "invalid_choice",
+	"excluded_with": "mutually_exclusive",
+	"unique":        "duplicate",
+	"password":      "weak_password",
+	"http_url":      "invalid_url",
+}
+
+func fieldCode(tag string) string {
+	code, ok := fieldCodes[tag]
+	if !ok {
+		return "invalid"
+	}
+	return code
+}
+
+func formatError(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is a required field", err.Field())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", err.Field())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s long", err.Field(), err.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s long", err.Field(), err.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be positive value", err.Field())
+	case "e164":
+		return fmt.Sprintf("%s must be a valid phone number", err.Field())
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", err.Field(), err.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be at least %s", err.Field(), err.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", err.Field(), err.Param())
+	case "unique":
+		return fmt.Sprintf("%s must not contain duplicates", err.Field())
+	case "password":
+		return fmt.Sprintf("%s must %s", err.Field(), passwordPolicy.describe())
+	case "http_url":
+		return fmt.Sprintf("%s must be an http or https URL", err.Field())
+	case "excluded_with":
+		return fmt.Sprintf("%s cannot be combined with %s", err.Field(), strings.ToLower(err.Param()))
+	default:
+		return fmt.Sprintf("%s failed validation with tag %s", err.Field(), err.Tag())
+	}
+}
+
+// uniqueFields maps the unique constraints of the users table to the dto.User field they guard.
+var uniqueFields = map[string]string{
+	"users_email_key": "email",
+}
+
+// storeError translates a database error into a domain error. Errors that
+// carry no domain meaning are returned unchanged.
+func storeError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return notFoundError(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgerrcode.UniqueViolation {
+		return err
+	}
+	field, ok := uniqueFields[pgErr.ConstraintName]
+	if !ok {
+		field = pgErr.ConstraintName
+	}
+	return &Error{
+		Kind:   ErrConflict,
+		Code:   CodeDuplicateField,
+		Detail: field + " is already in use",
+		Fields: []dto.FieldError{{
+			Field:   field,
+			Tag:     "unique",
+			Code:    "already_exists",
+			Message: field + " is already in use",
+		}},
+		Err: err,
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+// eventTypes maps the audit actions to the user event types.
+var eventTypes = map[database.Auditaction]string{
+	database.AuditactionCreate:  EventUserCreated,
+	database.AuditactionUpdate:  EventUserUpdated,
+	database.AuditactionDelete:  EventUserDeleted,
+	database.AuditactionRestore: EventUserRestored,
+	database.AuditactionPurge:   EventUserPurged,
+}
+
+// ListUserEvents returns up to limit user events following afterID, oldest
+// first. Events are read from the audit log, so a stream can be resumed from
+// any earlier event id. They carry the names of the changed fields only, not
+// the audited rows, so subscribers read the user to get its values.
+func ListUserEvents(ctx context.Context, afterID int64, limit int32, q database.Querier) ([]dto.UserEvent, error) {
+	ctx, span := startSpan(ctx, "ListUserEvents")
+	defer span.End()
+
+	rows, err := q.ListUserEvents(ctx, database.ListUserEventsParams{AfterID: afterID, MaxResults: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]dto.UserEvent, 0, len(rows))
+	for _, row := range rows {
+		var changes map[string]json.RawMessage
+		err := json.Unmarshal(row.Changes, &changes)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, dto.UserEvent{
+			ID:        row.Seq,
+			Type:      eventTypes[row.Action],
+			UserID:    row.Userid,
+			CreatedAt: row.CreatedAt.Time,
+			Fields:    slices.Sorted(maps.Keys(changes)),
+		})
+	}
+	return events, nil
+}
+
+// LatestUserEventID returns the id of the last user event, 0 when there is none.
+func LatestUserEventID(ctx context.Context, q database.Querier) (int64, error) {
+	ctx, span := startSpan(ctx, "LatestUserEventID")
+	defer span.End()
+
+	return q.LatestUserEventID(ctx)
+}
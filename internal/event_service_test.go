@@ -0,0 +1,20 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListUserEvents(t *testing.T) {
+	events, err := ListUserEvents(t.Context(), 10, 100, &MockDb{})
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Test Failure! Incorrect events")
+	}
+	// events name the changed fields, without their values
+	if events[0].ID != 11 || events[0].Type != EventUserUpdated || !slices.Equal(events[0].Fields, []string{"age", "email"}) {
+		t.Errorf("Test Failure! Incorrect update event %+v", events[0])
+	}
+	if events[1].ID != 12 || events[1].Type != EventUserDeleted || len(events[1].Fields) != 0 {
+		t.Errorf("Test Failure! Incorrect delete event %+v", events[1])
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+// Export formats and their media types.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportXLSX   = "xlsx"
+
+	XLSXType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var ExportTypes = map[string]string{
+	ExportCSV:    CSVType,
+	ExportNDJSON: NDJSONType,
+	ExportXLSX:   XLSXType,
+}
+
+// exportColumns maps the exportable columns to their value in a user row.
+// Values are nil for NULL, int32 for numbers and string or time.Time otherwise.
+var exportColumns = map[string]func(u database.User) any{
+	"userId":    func(u database.User) any { return u.Userid },
+	"firstName": func(u database.User) any { return u.Firstname },
+	"lastName":  func(u database.User) any { return u.Lastname },
+	"email":     func(u database.User) any { return u.Email },
+	"phone": func(u database.User) any {
+		if !u.Phone.Valid {
+			return nil
+		}
+		return u.Phone.String
+	},
+	"age": func(u database.User) any {
+		if !u.Age.Valid {
+			return nil
+		}
+		return u.Age.Int32
+	},
+	"status": func(u database.User) any {
+		if !u.UserStatus.Valid {
+			return nil
+		}
+		return string(u.UserStatus.Userstatus)
+	},
+	"deletedAt": func(u database.User) any {
+		if !u.DeletedAt.Valid {
+			return nil
+		}
+		return u.DeletedAt.Time.UTC()
+	},
+	"version": func(u database.User) any { return u.Version },
+}
+
+var defaultExportColumns = []string{"userId", "firstName", "lastName", "email", "phone", "age", "status"}
+
+// exportWriter encodes the rows of an export in one format.
+type exportWriter interface {
+	header(columns []string) error
+	row(values []any) error
+	close() error
+}
+
+// ValidateExport checks the parameters of an export, so that errors can be
+// reported before the response starts.
+func ValidateExport(params dto.UserExportParams) error {
+	err := validate(params)
+	if err != nil {
+		return err
+	}
+	if params.MinAge != nil && params.MaxAge != nil && *params.MinAge > *params.MaxAge {
+		return validationError(CodeValidationFailed, "Validation failed on 1 field(s)", dto.FieldError{
+			Field:   "min_age",
+			Tag:     "ltefield",
+			Code:    "out_of_range",
+			Message: "min_age must not be greater than max_age",
+		})
+	}
+	return nil
+}
+
+// ExportUsers writes every user matching the filters of params to w. Users are
+// streamed from the database as they are written, so exports of any size use
+// constant memory.
+func ExportUsers(ctx context.Context, params dto.UserExportParams, w io.Writer, q database.Querier) error {
+	ctx, span := startSpan(ctx, "ExportUsers")
+	defer span.End()
+
+	err := ValidateExport(params)
+	if err != nil {
+		return err
+	}
+
+	columns := params.Columns
+	if len(columns) == 0 {
+		columns = defaultExportColumns
+	}
+
+	var out exportWriter
+	switch params.Format {
+	case ExportNDJSON:
+		out = &ndjsonExport{w: bufio.NewWriter(w)}
+	case ExportXLSX:
+		out = newXLSXExport(w)
+	default:
+		out = &csvExport{w: csv.NewWriter(w)}
+	}
+
+	err = out.header(columns)
+	if err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	err = StreamUsers(ctx, params.UserListParams, func(u database.User) error {
+		for i, column := range columns {
+			values[i] = exportColumns[column](u)
+		}
+		return out.row(values)
+	}, q)
+	if err != nil {
+		return err
+	}
+	return out.close()
+}
+
+// StreamUsers calls fn with every user matching the filters and sort order of
+// params, as they are read from the database. Paging parameters are ignored.
+func StreamUsers(ctx context.Context, params dto.UserListParams, fn func(database.User) error, q database.Querier) error {
+	ctx, span := startSpan(ctx, "StreamUsers")
+	defer span.End()
+
+	err := ValidateExport(dto.UserExportParams{UserListParams: params})
+	if err != nil {
+		return err
+	}
+
+	sort := params.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	return q.ExportUsers(ctx, database.ExportUsersParams{
+		Filter:     listFilter(params),
+		SortColumn: sort,
+		Descending: params.Order == "desc",
+	}, fn)
+}
+
+type csvExport struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (e *csvExport) header(columns []string) error {
+	e.record = make([]string, len(columns))
+	return e.w.Write(columns)
+}
+
+func (e *csvExport) row(values []any) error {
+	for i, value := range values {
+		e.record[i] = csvCell(value)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvExport) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvCell formats a value for CSV. Text that spreadsheets would evaluate as a
+// formula is prefixed with a quote. Signed numbers such as phone numbers are
+// left as they are.
+func csvCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case int32:
+		return strconv.Itoa(int(v))
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		if v == "" {
+			return v
+		}
+		formula := strings.ContainsRune("=@\t\r", rune(v[0]))
+		if v[0] == '+' || v[0] == '-' {
+			formula = strings.Trim(v[1:], "0123456789 ") != ""
+		}
+		if formula {
+			return "'" + v
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type ndjsonExport struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (e *ndjsonExport) header(columns []string) error {
+	e.columns = make([]string, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		e.columns[i] = string(key)
+	}
+	return nil
+}
+
+// row writes the values as one JSON object, keeping the column order.
+func (e *ndjsonExport) row(values []any) error {
+	e.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		e.w.WriteString(e.columns[i])
+		e.w.WriteByte(':')
+		e.w.Write(data)
+	}
+	// bufio errors are sticky: this reports any failed write of the row
+	_, err := e.w.WriteString("}\n")
+	return err
+}
+
+func (e *ndjsonExport) close() error {
+	return e.w.Flush()
+}
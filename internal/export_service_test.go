@@ -0,0 +1,80 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"user-manager/dto"
+)
+
+func TestExportUsersCSV(t *testing.T) {
+	var out bytes.Buffer
+	err := ExportUsers(t.Context(), dto.UserExportParams{}, &out, &MockDb{})
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "userId,firstName,lastName,email,phone,age,status\n" +
+		"1,Jay,Vas,jay@gmail.com,+0722134567,30,Active\n" +
+		"2,'=SUM(A1),<Vas>,eq@gmail.com,,,\n"
+	if out.String() != expected {
+		t.Errorf("Test Failure! Incorrect CSV %q", out.String())
+	}
+}
+
+func TestExportUsersNDJSONColumns(t *testing.T) {
+	var out bytes.Buffer
+	params := dto.UserExportParams{Format: ExportNDJSON, Columns: []string{"version", "email", "deletedAt"}}
+	err := ExportUsers(t.Context(), params, &out, &MockDb{})
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"version":1,"email":"jay@gmail.com","deletedAt":null}` + "\n" +
+		`{"version":3,"email":"eq@gmail.com","deletedAt":null}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Test Failure! Incorrect NDJSON %q", out.String())
+	}
+}
+
+func TestExportUsersXLSX(t *testing.T) {
+	var out bytes.Buffer
+	params := dto.UserExportParams{Format: ExportXLSX, Columns: []string{"userId", "lastName"}}
+	err := ExportUsers(t.Context(), params, &out, &MockDb{})
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			data, _ := io.ReadAll(r)
+			sheet = string(data)
+		}
+	}
+	if len(archive.File) != 5 || !strings.Contains(sheet, "<row><c><v>2</v></c><c t=\"inlineStr\"><is><t xml:space=\"preserve\">&lt;Vas&gt;</t></is></c></row>") {
+		t.Errorf("Test Failure! Incorrect worksheet %s", sheet)
+	}
+}
+
+func TestExportUsersUnknownColumn(t *testing.T) {
+	var out bytes.Buffer
+	err := ExportUsers(t.Context(), dto.UserExportParams{Columns: []string{"password"}}, &out, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) || out.Len() != 0 {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
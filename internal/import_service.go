@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+const (
+	CSVType    = "text/csv"
+	NDJSONType = "application/x-ndjson"
+)
+
+// importBatchSize is the number of rows loaded per CopyFrom.
+const importBatchSize = 1000
+
+// maxImportLine is the longest NDJSON line accepted.
+const maxImportLine = 64 * 1024
+
+// errImportRejected rolls back an atomic import that has rejected rows.
+var errImportRejected = errors.New("import has rejected rows")
+
+// importColumns maps the CSV header names to the dto.User field they set.
+var importColumns = map[string]func(user *dto.User, value string) *dto.FieldError{
+	"firstname": func(user *dto.User, value string) *dto.FieldError {
+		user.Firstname = value
+		return nil
+	},
+	"lastname": func(user *dto.User, value string) *dto.FieldError {
+		user.Lastname = value
+		return nil
+	},
+	"email": func(user *dto.User, value string) *dto.FieldError {
+		user.Email = value
+		return nil
+	},
+	"phone": func(user *dto.User, value string) *dto.FieldError {
+		if value != "" {
+			user.Phone = &value
+		}
+		return nil
+	},
+	"age": func(user *dto.User, value string) *dto.FieldError {
+		if value == "" {
+			return nil
+		}
+		age, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return &dto.FieldError{Field: "age", Tag: "numeric", Code: "invalid_number", Message: "age must be a whole number"}
+		}
+		age32 := int32(age)
+		user.Age = &age32
+		return nil
+	},
+	"status": func(user *dto.User, value string) *dto.FieldError {
+		user.Status = value
+		return nil
+	},
+}
+
+// importRow is a parsed row of an import file. fields holds the errors found while parsing it.
+type importRow struct {
+	line   int
+	user   dto.User
+	fields []dto.FieldError
+}
+
+// rowReader returns the next row of an import file, or io.EOF after the last one.
+type rowReader func() (importRow, error)
+
+// ImportUsers creates the users of a CSV or NDJSON file, validating each row
+// like CreateUser. Valid rows are loaded in batches with CopyFrom. Unless the
+// import is atomic, rejected rows do not stop the valid ones from being stored.
+func ImportUsers(ctx context.Context, contentType string, body io.Reader, opts dto.ImportOptions, q database.Querier) (*dto.ImportReport, error) {
+	ctx, span := startSpan(ctx, "ImportUsers")
+	defer span.End()
+
+	next, err := importReader(contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &importer{
+		opts:    opts,
+		seen:    map[string]int{},
+		pending: map[int]dto.User{},
+		report:  &dto.ImportReport{DryRun: opts.DryRun, Atomic: opts.Atomic, Rows: []dto.ImportRow{}},
+	}
+
+	if opts.Atomic && !opts.DryRun {
+		err = q.ExecTx(ctx, func(tx database.Querier) error {
+			return imp.run(ctx, next, tx)
+		})
+	} else {
+		err = imp.run(ctx, next, q)
+	}
+	if err != nil && !errors.Is(err, errImportRejected) {
+		return nil, err
+	}
+
+	imp.report.Committed = !opts.DryRun && !(opts.Atomic && imp.report.Rejected > 0)
+	return imp.report, nil
+}
+
+type importer struct {
+	opts   dto.ImportOptions
+	report *dto.ImportReport
+	// seen maps the lower case emails of the file to their row index, to reject duplicates
+	seen map[string]int
+	// pending holds the valid rows waiting for the next flush by row index
+	pending map[int]dto.User
+}
+
+func (imp *importer) run(ctx context.Context, next rowReader, q database.Querier) error {
+	batch := make([]int, 0, importBatchSize)
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		index := len(imp.report.Rows)
+		imp.report.Rows = append(imp.report.Rows, dto.ImportRow{Line: row.line, Email: row.user.Email})
+
+		fields := row.fields
+		if len(fields) == 0 {
+			fields = rowErrors(row.user)
+		}
+		email := strings.ToLower(row.user.Email)
+		if first, ok := imp.seen[email]; ok && len(fields) == 0 {
+			fields = append(fields, dto.FieldError{
+				Field:   "email",
+				Tag:     "unique",
+				Code:    "duplicate",
+				Message: fmt.Sprintf("email is already used on line %d", imp.report.Rows[first].Line),
+			})
+		}
+		if len(fields) > 0 {
+			imp.reject(index, fields...)
+			continue
+		}
+
+		imp.seen[email] = index
+		imp.pending[index] = row.user
+		batch = append(batch, index)
+		if len(batch) == importBatchSize {
+			err = imp.flush(ctx, batch, q)
+			if err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	err := imp.flush(ctx, batch, q)
+	if err != nil {
+		return err
+	}
+	if imp.opts.Atomic && imp.report.Rejected > 0 {
+		return errImportRejected
+	}
+	return nil
+}
+
+// flush stores a batch of valid rows, rejecting those whose email is already taken.
+func (imp *importer) flush(ctx context.Context, batch []int, q database.Querier) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	emails := make([]string, 0, len(batch))
+	for _, index := range batch {
+		emails = append(emails, strings.ToLower(imp.pending[index].Email))
+	}
+	existing, err := q.ListUsersByEmails(ctx, emails)
+	if err != nil {
+		return err
+	}
+	taken := map[string]bool{}
+	for _, u := range existing {
+		taken[strings.ToLower(u.Email)] = true
+	}
+
+	params := make([]database.ImportUsersParams, 0, len(batch))
+	rows := make([]int, 0, len(batch))
+	for _, index := range batch {
+		user := imp.pending[index]
+		if taken[strings.ToLower(user.Email)] {
+			imp.reject(index, dto.FieldError{Field: "email", Tag: "unique", Code: "already_exists", Message: "email is already in use"})
+			continue
+		}
+		params = append(params, database.ImportUsersParams{
+			Firstname:  user.Firstname,
+			Lastname:   user.Lastname,
+			Email:      user.Email,
+			Phone:      nullableText(user.Phone),
+			Age:        nullableInt4(user.Age),
+			UserStatus: userStatus(user.Status),
+		})
+		rows = append(rows, index)
+	}
+	if imp.opts.DryRun || len(params) == 0 {
+		imp.accept(rows, nil)
+		return nil
+	}
+
+	var created map[string]int32
+	write := func(tx database.Querier) error {
+		created, err = importBatch(ctx, params, tx)
+		return err
+	}
+	if imp.opts.Atomic {
+		err = write(q)
+	} else {
+		err = q.ExecTx(ctx, write)
+	}
+
+	err = storeError(err)
+	var serviceErr *Error
+	if errors.Is(err, ErrConflict) && errors.As(err, &serviceErr) && !imp.opts.Atomic {
+		// an email was taken since it was checked; the whole batch was rolled back
+		for _, index := range rows {
+			imp.reject(index, serviceErr.Fields...)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	imp.accept(rows, created)
+	return nil
+}
+
+// importBatch copies the users into the users table and records their audit
+// events. It returns the ids of the created users by lower case email.
+func importBatch(ctx context.Context, params []database.ImportUsersParams, q database.Querier) (map[string]int32, error) {
+	_, err := q.ImportUsers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make([]string, 0, len(params))
+	for _, p := range params {
+		emails = append(emails, strings.ToLower(p.Email))
+	}
+	users, err := q.ListUsersByEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	created := map[string]int32{}
+	for i := range users {
+		err = recordAudit(ctx, database.AuditactionCreate, nil, &users[i], q)
+		if err != nil {
+			return nil, err
+		}
+		created[strings.ToLower(users[i].Email)] = users[i].Userid
+	}
+	return created, nil
+}
+
+func (imp *importer) accept(rows []int, created map[string]int32) {
+	for _, index := range rows {
+		row := &imp.report.Rows[index]
+		row.Status = "accepted"
+		row.UserID = created[strings.ToLower(row.Email)]
+		delete(imp.pending, index)
+		imp.report.Accepted++
+	}
+}
+
+func (imp *importer) reject(index int, fields ...dto.FieldError) {
+	row := &imp.report.Rows[index]
+	row.Status = "rejected"
+	row.Errors = fields
+	delete(imp.pending, index)
+	imp.report.Rejected++
+}
+
+// rowErrors returns the validation errors of a row, nil when it is valid.
+func rowErrors(user dto.User) []dto.FieldError {
+	var serviceErr *Error
+	if errors.As(validate(user), &serviceErr) {
+		return serviceErr.Fields
+	}
+	return nil
+}
+
+func importReader(contentType string, body io.Reader) (rowReader, error) {
+	switch contentType {
+	case CSVType:
+		return csvRows(body)
+	case NDJSONType:
+		return ndjsonRows(body), nil
+	default:
+		return nil, &Error{Kind: ErrUnsupported, Code: CodeUnsupportedFormat, Detail: "Import files must be " + CSVType + " or " + NDJSONType}
+	}
+}
+
+// csvRows reads a CSV file whose header names the dto.User fields of each column.
+func csvRows(body io.Reader) (rowReader, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, validationError(CodeInvalidImport, "CSV header is missing or malformed")
+	}
+	setters := make([]func(*dto.User, string) *dto.FieldError, len(header))
+	for i, name := range header {
+		setter, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, validationError(CodeInvalidImport, fmt.Sprintf("Unknown CSV column %q", name))
+		}
+		setters[i] = setter
+	}
+
+	return func() (importRow, error) {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return importRow{}, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return importRow{}, validationError(CodeInvalidImport, "Malformed CSV: "+parseErr.Error())
+		}
+		if err != nil {
+			return importRow{}, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line}
+		if len(record) != len(setters) {
+			row.fields = []dto.FieldError{malformedRow(fmt.Sprintf("row has %d columns, the header has %d", len(record), len(setters)))}
+			return row, nil
+		}
+		for i, value := range record {
+			fieldErr := setters[i](&row.user, strings.TrimSpace(value))
+			if fieldErr != nil {
+				row.fields = append(row.fields, *fieldErr)
+			}
+		}
+		return row, nil
+	}, nil
+}
+
+// ndjsonRows reads one JSON encoded dto.User per line. Blank lines are skipped.
+func ndjsonRows(body io.Reader) rowReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLine)
+	line := 0
+
+	return func() (importRow, error) {
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			row := importRow{line: line}
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.DisallowUnknownFields()
+			err := decoder.Decode(&row.user)
+			if err != nil {
+				row.fields = []dto.FieldError{malformedRow(err.Error())}
+			}
+			return row, nil
+		}
+		if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+			return importRow{}, validationError(CodeInvalidImport, fmt.Sprintf("Line %d is longer than %d bytes", line+1, maxImportLine))
+		}
+		if scanner.Err() != nil {
+			return importRow{}, scanner.Err()
+		}
+		return importRow{}, io.EOF
+	}
+}
+
+func malformedRow(message string) dto.FieldError {
+	return dto.FieldError{Tag: "format", Code: "malformed_row", Message: message}
+}
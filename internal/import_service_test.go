@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"user-manager/dto"
+)
+
+const importCSV = `firstName,lastName,email,phone,age,status
+Ann,Perera,ann@gmail.com,+94771234567,31,Active
+B,Silva,bob@gmail.com,,,
+Cal,Fern,jay@gmail.com,,40,Inactive
+Dan,Fern,ANN@gmail.com,,,
+Eve,Dias,eve@gmail.com,,old,
+`
+
+func TestImportUsersCSV(t *testing.T) {
+	db := &MockDb{}
+
+	report, err := ImportUsers(t.Context(), CSVType, strings.NewReader(importCSV), dto.ImportOptions{}, db)
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// B is too short, jay@gmail.com exists, ANN@ repeats line 2 and old is no age
+	expected := []string{"accepted", "rejected", "rejected", "rejected", "rejected"}
+	for i, row := range report.Rows {
+		if row.Status != expected[i] || row.Line != i+2 {
+			t.Errorf("Test Failure! Incorrect report row %+v", row)
+		}
+	}
+	if report.Accepted != 1 || report.Rejected != 4 || !report.Committed || len(db.Imported) != 1 || report.Rows[0].UserID == 0 {
+		t.Errorf("Test Failure! Incorrect report %+v", report)
+	}
+	if len(db.Audit) != 1 {
+		t.Errorf("Test Failure! Imported users not audited")
+	}
+}
+
+func TestImportUsersAtomic(t *testing.T) {
+	db := &MockDb{}
+
+	report, err := ImportUsers(t.Context(), CSVType, strings.NewReader(importCSV), dto.ImportOptions{Atomic: true}, db)
+	fmt.Println("error: ", err)
+
+	if err != nil || report.Committed {
+		t.Errorf("Test Failure! Atomic import with rejected rows committed")
+	}
+}
+
+func TestImportUsersNDJSONDryRun(t *testing.T) {
+	db := &MockDb{}
+	body := `{"firstName": "Ann", "lastName": "Perera", "email": "ann@gmail.com"}
+
+{"firstName": "Bob", "lastName": "Silva", "email": "bob@gmail.com", "role": "admin"}
+`
+
+	report, err := ImportUsers(t.Context(), NDJSONType, strings.NewReader(body), dto.ImportOptions{DryRun: true}, db)
+	fmt.Println("error: ", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Accepted != 1 || report.Rejected != 1 || report.Rows[1].Line != 3 || report.Committed || len(db.Imported) != 0 {
+		t.Errorf("Test Failure! Incorrect dry run report %+v", report)
+	}
+}
+
+func TestImportUsersUnknownColumn(t *testing.T) {
+	_, err := ImportUsers(t.Context(), CSVType, strings.NewReader("firstName,role\nAnn,admin\n"), dto.ImportOptions{}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
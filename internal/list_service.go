@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	DefaultPageSize = 20
+	defaultSort     = "userId"
+)
+
+// listCursor is the decoded form of the opaque next_cursor value.
+type listCursor struct {
+	Sort   string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Value  string `json:"v"`
+	Userid int32  `json:"id"`
+}
+
+func ListUsers(ctx context.Context, params dto.UserListParams, q database.Querier) (*dto.UserPage, error) {
+	ctx, span := startSpan(ctx, "ListUsers")
+	defer span.End()
+
+	err := validate(params)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	if params.MinAge != nil && params.MaxAge != nil && *params.MinAge > *params.MaxAge {
+		return nil, validationError(CodeValidationFailed, "Validation failed on 1 field(s)", dto.FieldError{
+			Field:   "min_age",
+			Tag:     "ltefield",
+			Code:    "out_of_range",
+			Message: "min_age must not be greater than max_age",
+		})
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	sort := params.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	desc := params.Order == "desc"
+
+	filter := listFilter(params)
+	pageParams := database.ListUsersPageParams{
+		Filter:     filter,
+		SortColumn: sort,
+		Descending: desc,
+		Limit:      limit + 1,
+	}
+
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(params.Cursor)
+		if err != nil || cursor.Sort != sort || cursor.Desc != desc {
+			return nil, validationError(CodeInvalidCursor, "cursor is malformed or does not match the sort order")
+		}
+		// the value is cast to the type of the sort column by the query
+		if sort == "userId" || sort == "age" {
+			_, err = strconv.ParseInt(cursor.Value, 10, 32)
+			if err != nil {
+				return nil, validationError(CodeInvalidCursor, "cursor is malformed or does not match the sort order")
+			}
+		}
+		pageParams.After = &database.UserKey{Value: cursor.Value, Userid: cursor.Userid}
+	} else if params.Page > 1 {
+		pageParams.Offset = (params.Page - 1) * limit
+	}
+
+	users, err := q.ListUsersPage(ctx, pageParams)
+	if err != nil {
+		slog.DebugContext(ctx, "error on listing users", "error", err)
+		return nil, err
+	}
+
+	total, err := q.CountUsers(ctx, filter)
+	if err != nil {
+		slog.DebugContext(ctx, "error on counting users", "error", err)
+		return nil, err
+	}
+
+	page := &dto.UserPage{Users: users, Total: total}
+	if len(users) > int(limit) {
+		page.Users = users[:limit]
+		key := database.SortKey(page.Users[limit-1], sort)
+		page.NextCursor = encodeCursor(listCursor{Sort: sort, Desc: desc, Value: key.Value, Userid: key.Userid})
+	}
+
+	return page, nil
+}
+
+func listFilter(params dto.UserListParams) database.ListUsersFilter {
+	return database.ListUsersFilter{
+		Status: database.NullUserstatus{
+			Userstatus: database.Userstatus(params.Status),
+			Valid:      params.Status != "",
+		},
+		MinAge:      nullableInt4(params.MinAge),
+		MaxAge:      nullableInt4(params.MaxAge),
+		EmailPrefix: pgtype.Text{String: params.EmailPrefix, Valid: params.EmailPrefix != ""},
+		NamePrefix:  pgtype.Text{String: params.NamePrefix, Valid: params.NamePrefix != ""},
+
+		IncludeDeleted: params.IncludeDeleted,
+	}
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
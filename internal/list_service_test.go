@@ -0,0 +1,93 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"user-manager/dto"
+)
+
+func TestListUsersInvalidSort(t *testing.T) {
+	params := dto.UserListParams{Sort: "password"}
+
+	_, err := ListUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestListUsersCursorWithPage(t *testing.T) {
+	params := dto.UserListParams{Cursor: "abc", Page: 2}
+
+	_, err := ListUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestListUsersInvalidAgeRange(t *testing.T) {
+	params := dto.UserListParams{MinAge: ptr[int32](40), MaxAge: ptr[int32](30)}
+
+	_, err := ListUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestListFilterZeroAge(t *testing.T) {
+	filter := listFilter(dto.UserListParams{MinAge: ptr[int32](0), MaxAge: ptr[int32](0)})
+	if !filter.MinAge.Valid || filter.MinAge.Int32 != 0 || !filter.MaxAge.Valid || filter.MaxAge.Int32 != 0 {
+		t.Errorf("Test Failure! Zero age bounds ignored %+v", filter)
+	}
+
+	filter = listFilter(dto.UserListParams{})
+	if filter.MinAge.Valid || filter.MaxAge.Valid {
+		t.Errorf("Test Failure! Omitted age bounds filter %+v", filter)
+	}
+}
+
+func TestListUsersNonNumericCursor(t *testing.T) {
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"age","v":"abc","id":3}`))
+	params := dto.UserListParams{Sort: "age", Cursor: cursor}
+
+	_, err := ListUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || !errors.Is(err, ErrValidation) || serviceErr.Code != CodeInvalidCursor {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestListUsersNextCursor(t *testing.T) {
+	params := dto.UserListParams{Limit: 2, Sort: "firstName"}
+
+	page, err := ListUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Fatalf("Test Failure! Incorrect error")
+	}
+	if len(page.Users) != 2 || page.Total != 50 {
+		t.Errorf("Test Failure! Incorrect page %d of %d", len(page.Users), page.Total)
+	}
+
+	cursor, err := decodeCursor(page.NextCursor)
+	if err != nil || cursor.Userid != 2 || cursor.Value != "Jay2" || cursor.Sort != "firstName" {
+		t.Errorf("Test Failure! Incorrect cursor %+v", cursor)
+	}
+
+	params.Cursor = page.NextCursor
+	params.Order = "desc"
+	_, err = ListUsers(t.Context(), params, &MockDb{})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Cursor accepted for a different sort order")
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"user-manager/auth"
+	"user-manager/config"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// passwordPolicy is enforced by the "password" validate tag.
+var passwordPolicy = passwordRules{MinLength: 12}
+
+// passwordHash is the algorithm new password hashes are made with.
+var passwordHash = auth.Argon2id
+
+// ConfigurePasswords sets the password policy and the hashing algorithm used for new hashes.
+func ConfigurePasswords(policy config.PasswordPolicy, algorithm string) {
+	passwordPolicy = passwordRules(policy)
+	passwordHash = algorithm
+}
+
+func validatePassword(fl validator.FieldLevel) bool {
+	return passwordPolicy.check(fl.Field().String())
+}
+
+type passwordRules config.PasswordPolicy
+
+func (p passwordRules) check(password string) bool {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	return len([]rune(password)) >= p.MinLength &&
+		(upper || !p.RequireUpper) &&
+		(lower || !p.RequireLower) &&
+		(digit || !p.RequireDigit) &&
+		(symbol || !p.RequireSymbol)
+}
+
+func (p passwordRules) describe() string {
+	rules := []string{fmt.Sprintf("be at least %d characters long", p.MinLength)}
+	if p.RequireUpper {
+		rules = append(rules, "contain an upper case letter")
+	}
+	if p.RequireLower {
+		rules = append(rules, "contain a lower case letter")
+	}
+	if p.RequireDigit {
+		rules = append(rules, "contain a digit")
+	}
+	if p.RequireSymbol {
+		rules = append(rules, "contain a symbol")
+	}
+	return strings.Join(rules, " and ")
+}
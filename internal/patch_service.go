@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"user-manager/database"
+	"user-manager/dto"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types accepted by PatchUser.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// PatchUser applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902)
+// document to the current state of the user and stores the result. Fields
+// the patch does not touch keep their value, and optional fields set to null
+// are cleared. The version must match the current version of the user, or be AnyVersion.
+func PatchUser(ctx context.Context, id int, version int32, patchType string, patch []byte, q database.Querier) (*database.User, error) {
+	ctx, span := startSpan(ctx, "PatchUser", userIDAttr(id))
+	defer span.End()
+
+	current, err := q.GetUser(ctx, database.GetUserParams{Userid: int32(id)})
+	if err != nil {
+		slog.DebugContext(ctx, "error on loading user for patch", "user_id", id, "error", err)
+		return nil, storeError(err)
+	}
+	err = checkVersion(current, version)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(UserFromDB(current))
+	if err != nil {
+		return nil, err
+	}
+
+	switch patchType {
+	case MergePatchType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	case JSONPatchType:
+		var ops jsonpatch.Patch
+		ops, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			doc, err = ops.Apply(doc)
+		}
+	default:
+		return nil, &Error{Kind: ErrUnsupported, Code: CodeUnsupportedFormat, Detail: "Unsupported patch type: " + patchType}
+	}
+	if err != nil {
+		return nil, &Error{Kind: ErrValidation, Code: CodeInvalidPatch, Detail: "Invalid patch: " + err.Error(), Err: err}
+	}
+
+	var user dto.User
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&user)
+	if err != nil {
+		return nil, &Error{Kind: ErrValidation, Code: CodeInvalidPatch, Detail: "Invalid patch: " + err.Error(), Err: err}
+	}
+
+	err = validate(user)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	return replaceUser(ctx, current, version, user, q)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestPatchUserMergePatchClearsPhone(t *testing.T) {
+	patch := []byte(`{"phone": null, "age": 31}`)
+
+	dbUser, err := PatchUser(t.Context(), 1, AnyVersion, MergePatchType, patch, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Fatalf("Test Failure! Incorrect error")
+	}
+	if dbUser.Phone.Valid || dbUser.Age.Int32 != 31 || dbUser.Firstname != "Jay" {
+		t.Errorf("Test Failure! Incorrect patched user %+v", dbUser)
+	}
+}
+
+func TestPatchUserJSONPatch(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/lastName", "value": "Vasquez"}, {"op": "remove", "path": "/age"}]`)
+
+	dbUser, err := PatchUser(t.Context(), 1, AnyVersion, JSONPatchType, patch, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Fatalf("Test Failure! Incorrect error")
+	}
+	if dbUser.Lastname != "Vasquez" || dbUser.Age.Valid || !dbUser.Phone.Valid {
+		t.Errorf("Test Failure! Incorrect patched user %+v", dbUser)
+	}
+}
+
+func TestPatchUserInvalidField(t *testing.T) {
+	patch := []byte(`{"email": "jaygmail.com"}`)
+
+	_, err := PatchUser(t.Context(), 1, AnyVersion, MergePatchType, patch, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestPatchUserUnknownField(t *testing.T) {
+	patch := []byte(`{"userId": 5}`)
+
+	_, err := PatchUser(t.Context(), 1, AnyVersion, MergePatchType, patch, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestPatchUserUnsupportedType(t *testing.T) {
+	_, err := PatchUser(t.Context(), 1, AnyVersion, "text/plain", []byte(`age=3`), &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestPatchUserVersion(t *testing.T) {
+	_, err := PatchUser(t.Context(), 1, 0, MergePatchType, []byte(`{"age": 40}`), &MockDb{})
+	if !errors.Is(err, ErrPreconditionRequired) {
+		t.Errorf("Test Failure! Expected the version to be required, got %v", err)
+	}
+
+	_, err = PatchUser(t.Context(), 1, 2, MergePatchType, []byte(`{"age": 40}`), &MockDb{})
+	if !errors.Is(err, ErrPrecondition) {
+		t.Errorf("Test Failure! Expected a version mismatch, got %v", err)
+	}
+
+	_, err = PatchUser(t.Context(), 1, 1, MergePatchType, []byte(`{"age": 40}`), &MockDb{})
+	if err != nil {
+		t.Errorf("Test Failure! Expected the current version to match, got %v", err)
+	}
+}
+
+func TestPatchUserNotFound(t *testing.T) {
+	_, err := PatchUser(t.Context(), 9, AnyVersion, MergePatchType, []byte(`{}`), &MockDb{Err: pgx.ErrNoRows})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
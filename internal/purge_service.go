@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PurgeDeletedUsers permanently removes users that were soft deleted more than
+// retention ago, and records a purge audit event for each of them in the same
+// transaction.
+func PurgeDeletedUsers(ctx context.Context, retention time.Duration, q database.Querier) (int64, error) {
+	ctx, span := startSpan(ctx, "PurgeDeletedUsers")
+	defer span.End()
+
+	deletedBefore := pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+	var purged int64
+	err := q.ExecTx(ctx, func(tx database.Querier) error {
+		users, err := tx.PurgeDeletedUsers(ctx, deletedBefore)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			err = recordAudit(ctx, database.AuditactionPurge, &user, nil, tx)
+			if err != nil {
+				return err
+			}
+		}
+		purged = int64(len(users))
+		return nil
+	})
+	return purged, err
+}
+
+// DeleteFinishedWebhookEvents removes the webhook events dispatched more than
+// retention ago whose deliveries are all delivered or dead, with their deliveries.
+func DeleteFinishedWebhookEvents(ctx context.Context, retention time.Duration, q database.Querier) (int64, error) {
+	ctx, span := startSpan(ctx, "DeleteFinishedWebhookEvents")
+	defer span.End()
+
+	dispatchedBefore := pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+	return q.DeleteFinishedWebhookEvents(ctx, dispatchedBefore)
+}
+
+// RunPurgeJob purges soft deleted users, expired tokens and finished webhook
+// events every interval until ctx is cancelled.
+func RunPurgeJob(ctx context.Context, interval time.Duration, retention time.Duration, webhookRetention time.Duration, q database.Querier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := PurgeDeletedUsers(ctx, retention, q)
+		if err != nil {
+			slog.ErrorContext(ctx, "error on purging deleted users", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "purged deleted users", "count", purged)
+		}
+
+		expired, err := q.DeleteExpiredTokens(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "error on deleting expired tokens", "error", err)
+		} else if expired > 0 {
+			slog.InfoContext(ctx, "deleted expired tokens", "count", expired)
+		}
+
+		events, err := DeleteFinishedWebhookEvents(ctx, webhookRetention, q)
+		if err != nil {
+			slog.ErrorContext(ctx, "error on deleting finished webhook events", "error", err)
+		} else if events > 0 {
+			slog.InfoContext(ctx, "deleted finished webhook events", "count", events)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
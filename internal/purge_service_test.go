@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestRestoreUserNotDeleted(t *testing.T) {
+	_, err := RestoreUser(t.Context(), 1, &MockDb{Err: pgx.ErrNoRows})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestPurgeDeletedUsersRetention(t *testing.T) {
+	mockDb := &MockDb{}
+
+	purged, err := PurgeDeletedUsers(t.Context(), 48*time.Hour, mockDb)
+	fmt.Println("error: ", err)
+
+	if err != nil || purged != 3 {
+		t.Fatalf("Test Failure! Incorrect purge result")
+	}
+	if len(mockDb.Audit) != 3 || mockDb.Audit[2].Action != database.AuditactionPurge || mockDb.Audit[2].Userid != 3 || mockDb.Audit[2].Before == nil || mockDb.Audit[2].After != nil {
+		t.Errorf("Test Failure! Incorrect purge audit events %+v", mockDb.Audit)
+	}
+	if len(mockDb.Outbox) != 0 {
+		t.Errorf("Test Failure! Purge queued webhook events")
+	}
+
+	age := time.Since(mockDb.PurgedBefore)
+	if age < 48*time.Hour || age > 49*time.Hour {
+		t.Errorf("Test Failure! Incorrect purge cutoff %s", mockDb.PurgedBefore)
+	}
+}
+
+func TestDeleteFinishedWebhookEventsRetention(t *testing.T) {
+	mockDb := &MockDb{}
+
+	deleted, err := DeleteFinishedWebhookEvents(t.Context(), 24*time.Hour, mockDb)
+	fmt.Println("error: ", err)
+
+	if err != nil || deleted != 2 {
+		t.Fatalf("Test Failure! Incorrect sweep result")
+	}
+
+	age := time.Since(mockDb.PurgedBefore)
+	if age < 24*time.Hour || age > 25*time.Hour {
+		t.Errorf("Test Failure! Incorrect sweep cutoff %s", mockDb.PurgedBefore)
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// editableFields lists the dto.User fields each role may change. Admins may change every field.
+var editableFields = map[string][]string{
+	auth.RoleSupport: {"email", "phone"},
+	auth.RoleSelf:    {"firstName", "lastName", "email", "phone", "age"},
+}
+
+// LoadPrincipal resolves the caller of verified token claims. Only tokens
+// issued by this service name a local user, whose roles are loaded; the
+// callers of other tokens hold no role. Tokens of deleted users are rejected.
+func LoadPrincipal(ctx context.Context, claims *auth.Claims, q database.Querier) (*auth.Principal, error) {
+	ctx, span := startSpan(ctx, "LoadPrincipal")
+	defer span.End()
+
+	principal := &auth.Principal{Roles: []string{}}
+	userID, ok := claims.UserID()
+	if !ok {
+		return principal, nil
+	}
+
+	_, err := q.GetUser(ctx, database.GetUserParams{Userid: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, &Error{Kind: ErrUnauthenticated, Code: CodeUnknownSubject, Detail: "The user of the bearer token does not exist", Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := q.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	principal.UserID = userID
+	for _, role := range roles {
+		principal.Roles = append(principal.Roles, string(role))
+	}
+	return principal, nil
+}
+
+// Sessions returns the token revocations and callers stored in q, for auth.Authenticate.
+func Sessions(q database.Querier) auth.Sessions {
+	return sessions{q: q}
+}
+
+type sessions struct {
+	q database.Querier
+}
+
+func (s sessions) IsTokenRevoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	return IsTokenRevoked(ctx, claims, s.q)
+}
+
+func (s sessions) LoadPrincipal(ctx context.Context, claims *auth.Claims) (*auth.Principal, error) {
+	return LoadPrincipal(ctx, claims, s.q)
+}
+
+func ListUserRoles(ctx context.Context, id int, q database.Querier) (*dto.UserRoles, error) {
+	ctx, span := startSpan(ctx, "ListUserRoles", userIDAttr(id))
+	defer span.End()
+
+	_, err := GetUser(ctx, id, false, q)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := q.ListUserRoles(ctx, int32(id))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.UserRoles{Roles: []string{}}
+	for _, role := range roles {
+		result.Roles = append(result.Roles, string(role))
+	}
+	return result, nil
+}
+
+// SetUserRoles replaces the roles granted to the user.
+func SetUserRoles(ctx context.Context, id int, roles dto.UserRoles, q database.Querier) (*dto.UserRoles, error) {
+	ctx, span := startSpan(ctx, "SetUserRoles", userIDAttr(id))
+	defer span.End()
+
+	err := validate(roles)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	_, err = GetUser(ctx, id, false, q)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.SetUserRoles(ctx, database.SetUserRolesParams{
+		Userid: int32(id),
+		Roles:  roles.Roles,
+	})
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	return ListUserRoles(ctx, id, q)
+}
+
+// BootstrapAdmin grants the admin role to the user with the email of user,
+// creating the user when there is none, and sets its password when one is
+// given. Roles can only be granted by an admin, so the first one is made this way.
+func BootstrapAdmin(ctx context.Context, user dto.User, password string, q database.Querier) (*database.User, error) {
+	ctx, span := startSpan(ctx, "BootstrapAdmin")
+	defer span.End()
+
+	if password != "" {
+		err := validate(dto.PasswordChange{NewPassword: password})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var admin database.User
+	err := q.ExecTx(ctx, func(tx database.Querier) error {
+		existing, err := tx.ListUsersByEmails(ctx, []string{strings.ToLower(user.Email)})
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			admin = existing[0]
+		} else {
+			created, err := CreateUser(ctx, user, tx)
+			if err != nil {
+				return err
+			}
+			admin = *created
+		}
+
+		roles, err := tx.ListUserRoles(ctx, admin.Userid)
+		if err != nil {
+			return err
+		}
+		granted := []string{auth.RoleAdmin}
+		for _, role := range roles {
+			if string(role) != auth.RoleAdmin {
+				granted = append(granted, string(role))
+			}
+		}
+		err = tx.SetUserRoles(ctx, database.SetUserRolesParams{Userid: admin.Userid, Roles: granted})
+		if err != nil {
+			return err
+		}
+
+		if password == "" {
+			return nil
+		}
+		return setPasswordHash(ctx, admin.Userid, password, tx)
+	})
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return &admin, nil
+}
+
+// checkEditable rejects changes to fields the caller in ctx may not edit.
+// Calls without a caller, such as background jobs, are not restricted.
+func checkEditable(ctx context.Context, id int, current dto.User, updated dto.User) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || principal.HasRole(auth.RoleAdmin) {
+		return nil
+	}
+
+	allowed := map[string]bool{}
+	for _, role := range principal.Roles {
+		for _, field := range editableFields[role] {
+			allowed[field] = true
+		}
+	}
+	if principal.IsSelf(int32(id)) {
+		for _, field := range editableFields[auth.RoleSelf] {
+			allowed[field] = true
+		}
+	}
+
+	var fields []dto.FieldError
+	for _, field := range changedFields(current, updated) {
+		if !allowed[field] {
+			fields = append(fields, dto.FieldError{
+				Field:   field,
+				Tag:     "role",
+				Code:    "forbidden_field",
+				Message: field + " can not be changed by the caller",
+			})
+		}
+	}
+	if len(fields) > 0 {
+		return &Error{Kind: ErrForbidden, Code: CodeForbidden, Detail: "Not allowed to change some fields", Fields: fields}
+	}
+	return nil
+}
+
+func changedFields(current dto.User, updated dto.User) []string {
+	var fields []string
+	if current.Firstname != updated.Firstname {
+		fields = append(fields, "firstName")
+	}
+	if current.Lastname != updated.Lastname {
+		fields = append(fields, "lastName")
+	}
+	if current.Email != updated.Email {
+		fields = append(fields, "email")
+	}
+	if !equalPtr(current.Phone, updated.Phone) {
+		fields = append(fields, "phone")
+	}
+	if !equalPtr(current.Age, updated.Age) {
+		fields = append(fields, "age")
+	}
+	// an omitted status is stored as Active, like userStatus does
+	if current.Status != string(userStatus(updated.Status).Userstatus) {
+		fields = append(fields, "status")
+	}
+	return fields
+}
+
+func equalPtr[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"user-manager/auth"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func asCaller(ctx context.Context, userID int32, roles ...string) context.Context {
+	return auth.NewPrincipalContext(ctx, &auth.Principal{UserID: userID, Roles: roles})
+}
+
+func TestPatchUserSupportContactFields(t *testing.T) {
+	ctx := asCaller(t.Context(), 7, auth.RoleSupport)
+
+	_, err := PatchUser(ctx, 1, AnyVersion, MergePatchType, []byte(`{"phone": "+94771234567"}`), &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Errorf("Test Failure! Support could not change phone")
+	}
+}
+
+func TestPatchUserSupportCannotChangeStatus(t *testing.T) {
+	ctx := asCaller(t.Context(), 7, auth.RoleSupport)
+
+	_, err := PatchUser(ctx, 1, AnyVersion, MergePatchType, []byte(`{"status": "Inactive", "age": 40}`), &MockDb{})
+	fmt.Println("error: ", err)
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || !errors.Is(err, ErrForbidden) || len(serviceErr.Fields) != 2 {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestPatchUserSelf(t *testing.T) {
+	ctx := asCaller(t.Context(), 1)
+
+	_, err := PatchUser(ctx, 1, AnyVersion, MergePatchType, []byte(`{"age": 40}`), &MockDb{})
+	if err != nil {
+		t.Errorf("Test Failure! User could not change own age: %v", err)
+	}
+
+	_, err = PatchUser(ctx, 1, AnyVersion, MergePatchType, []byte(`{"status": "Inactive"}`), &MockDb{})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Test Failure! User changed own status")
+	}
+}
+
+func TestUpdateUserSelfWithoutStatus(t *testing.T) {
+	ctx := asCaller(t.Context(), 1)
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "jay@gmail.com",
+		Phone:     ptr("+0722134567"),
+		Age:       ptr[int32](31),
+	}
+
+	_, err := UpdateUser(ctx, 1, AnyVersion, user, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Errorf("Test Failure! User could not replace own record without status: %v", err)
+	}
+}
+
+func TestUpdateUserAdminChangesStatus(t *testing.T) {
+	ctx := asCaller(t.Context(), 7, auth.RoleAdmin)
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "jay@gmail.com",
+		Status:    string(database.UserstatusInactive),
+	}
+
+	_, err := UpdateUser(ctx, 1, AnyVersion, user, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Errorf("Test Failure! Admin could not change status")
+	}
+}
+
+func TestSetUserRolesInvalidRole(t *testing.T) {
+	_, err := SetUserRoles(t.Context(), 1, dto.UserRoles{Roles: []string{"admin", "root"}}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	mockDb := &MockDb{}
+
+	roles, err := SetUserRoles(t.Context(), 1, dto.UserRoles{Roles: []string{"support"}}, mockDb)
+	fmt.Println("error: ", err)
+
+	if err != nil || len(roles.Roles) != 1 || roles.Roles[0] != auth.RoleSupport {
+		t.Errorf("Test Failure! Incorrect roles")
+	}
+
+	_, claims, err := testIssuer(t).AccessToken("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := LoadPrincipal(t.Context(), claims, mockDb)
+	if err != nil || principal.UserID != 1 || !principal.HasRole(auth.RoleSupport) || principal.HasRole(auth.RoleAdmin) {
+		t.Errorf("Test Failure! Incorrect principal")
+	}
+}
+
+func TestLoadPrincipalDeletedUser(t *testing.T) {
+	_, claims, err := testIssuer(t).AccessToken("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadPrincipal(t.Context(), claims, &MockDb{Roles: []database.Userrole{database.UserroleAdmin}})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Test Failure! Token of a deleted user accepted: %v", err)
+	}
+}
+
+func TestLoadPrincipalExternalToken(t *testing.T) {
+	// claims of a token not issued by this service, such as a JWKS verified one
+	claims := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+
+	principal, err := LoadPrincipal(t.Context(), claims, &MockDb{Roles: []database.Userrole{database.UserroleAdmin}})
+	fmt.Println("error: ", err)
+
+	if err != nil || principal.UserID != 0 || principal.IsSelf(1) || len(principal.Roles) != 0 {
+		t.Errorf("Test Failure! External subject mapped to a local user %+v", principal)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	mockDb := &MockDb{}
+
+	admin, err := BootstrapAdmin(t.Context(), dto.User{Firstname: "Root", Lastname: "Admin", Email: "root@example.com"}, "a-long-enough-password", mockDb)
+	fmt.Println("error: ", err)
+
+	if err != nil || len(mockDb.Created) != 1 || mockDb.Created[0].Email != "root@example.com" {
+		t.Fatalf("Test Failure! Admin not created %+v", mockDb.Created)
+	}
+	if admin.Userid != 1 || len(mockDb.Roles) != 1 || mockDb.Roles[0] != database.UserroleAdmin || mockDb.PasswordHash == "" {
+		t.Errorf("Test Failure! Admin role or password not set %+v %s", mockDb.Roles, mockDb.PasswordHash)
+	}
+}
+
+func TestBootstrapAdminExistingUser(t *testing.T) {
+	mockDb := &MockDb{Roles: []database.Userrole{database.UserroleSupport}}
+
+	_, err := BootstrapAdmin(t.Context(), dto.User{Email: "Jay@gmail.com"}, "", mockDb)
+	fmt.Println("error: ", err)
+
+	for _, created := range mockDb.Created {
+		if created.Email != "" {
+			t.Errorf("Test Failure! Existing user created again")
+		}
+	}
+	if err != nil || len(mockDb.Roles) != 2 || mockDb.PasswordHash != "" {
+		t.Errorf("Test Failure! Incorrect roles %v or password changed", mockDb.Roles)
+	}
+
+	_, err = BootstrapAdmin(t.Context(), dto.User{Email: "jay@gmail.com"}, "short", mockDb)
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Weak password accepted")
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+// SearchUsers runs a ranked full-text and trigram search over user names and emails.
+func SearchUsers(ctx context.Context, params dto.UserSearchParams, q database.Querier) ([]database.SearchUsersRow, error) {
+	ctx, span := startSpan(ctx, "SearchUsers")
+	defer span.End()
+
+	params.Query = strings.TrimSpace(params.Query)
+
+	err := validate(params)
+	if err != nil {
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	results, err := q.SearchUsers(ctx, database.SearchUsersParams{
+		Query:      params.Query,
+		MaxResults: limit,
+	})
+	if err != nil {
+		slog.DebugContext(ctx, "error on searching users", "error", err)
+		return nil, err
+	}
+
+	if results == nil {
+		results = []database.SearchUsersRow{}
+	}
+	return results, nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"user-manager/dto"
+)
+
+func TestSearchUsersEmptyQuery(t *testing.T) {
+	params := dto.UserSearchParams{Query: "   "}
+
+	_, err := SearchUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestSearchUsersNoResults(t *testing.T) {
+	params := dto.UserSearchParams{Query: "vasquez"}
+
+	results, err := SearchUsers(t.Context(), params, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil || results == nil {
+		t.Errorf("Test Failure! Expected an empty result list")
+	}
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts the span of a service call, as a child of the HTTP or gRPC
+// request span of ctx. Callers end it when they return.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer("user-manager/internal").Start(ctx, "services."+name, trace.WithAttributes(attrs...))
+}
+
+func userIDAttr(id int) attribute.KeyValue {
+	return attribute.Int("user.id", id)
+}
+
+func webhookIDAttr(id int) attribute.KeyValue {
+	return attribute.Int("webhook.id", id)
+}
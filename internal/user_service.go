@@ -3,99 +3,214 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
-	"net/http"
+	"log/slog"
 	"user-manager/database"
 	"user-manager/dto"
 
-	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-func CreateUser(ctx context.Context, user dto.User, q database.Querier) (*database.User, string, int) {
-	validate := validator.New()
-	err := validate.Struct(user)
+func CreateUser(ctx context.Context, user dto.User, q database.Querier) (*database.User, error) {
+	ctx, span := startSpan(ctx, "CreateUser")
+	defer span.End()
 
+	err := validate(user)
 	if err != nil {
-		var errs validator.ValidationErrors
-		var validationFiledErr validator.FieldError
-		errors.As(err, &errs)
-		for _, validationError := range errs {
-			validationFiledErr = validationError
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	var dbUser database.User
+	err = q.ExecTx(ctx, func(tx database.Querier) error {
+		dbUser, err = tx.CreateUser(ctx, database.CreateUserParams{
+			Firstname:  user.Firstname,
+			Lastname:   user.Lastname,
+			Email:      user.Email,
+			Phone:      nullableText(user.Phone),
+			Age:        nullableInt4(user.Age),
+			UserStatus: userStatus(user.Status),
+		})
+		if err != nil {
+			return err
 		}
-		msg := "Validation Failed on: " + formatError(validationFiledErr)
-		fmt.Println(msg)
-		return nil, msg, http.StatusBadRequest
-	}
-
-	dbUser, err := q.CreateUser(ctx, database.CreateUserParams{
-		Firstname: user.Firstname,
-		Lastname:  user.Lastname,
-		Email:     user.Email,
-		Phone:     pgtype.Text{String: user.Phone, Valid: true},
-		Age:       pgtype.Int4{Int32: user.Age, Valid: true},
-		UserStatus: database.NullUserstatus{
-			Userstatus: database.Userstatus(user.Status),
-			Valid:      true,
-		},
+		return recordAudit(ctx, database.AuditactionCreate, nil, &dbUser, tx)
 	})
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return &dbUser, nil
+}
+
+// GetUser returns the user with the given id. Soft deleted users are only returned when includeDeleted is set.
+func GetUser(ctx context.Context, id int, includeDeleted bool, q database.Querier) (*database.User, error) {
+	ctx, span := startSpan(ctx, "GetUser", userIDAttr(id))
+	defer span.End()
 
+	dbUser, err := q.GetUser(ctx, database.GetUserParams{
+		Userid:         int32(id),
+		IncludeDeleted: includeDeleted,
+	})
 	if err != nil {
-		return nil, "Internal Server Error", http.StatusInternalServerError
+		return nil, storeError(err)
 	}
-	return &dbUser, "", http.StatusCreated
+	return &dbUser, nil
 }
 
-func UpdateUser(ctx context.Context, id int, user dto.User, q database.Querier) (string, int) {
-	validate := validator.New()
-	err := validate.Struct(user)
+// UpdateUser replaces every field of the user. Optional fields left out of user are cleared.
+// The version must match the current version of the user, or be AnyVersion.
+func UpdateUser(ctx context.Context, id int, version int32, user dto.User, q database.Querier) (*database.User, error) {
+	ctx, span := startSpan(ctx, "UpdateUser", userIDAttr(id))
+	defer span.End()
 
+	err := validate(user)
 	if err != nil {
-		var errs validator.ValidationErrors
-		var validationFiledErr validator.FieldError
-		errors.As(err, &errs)
-		for _, validationError := range errs {
-			validationFiledErr = validationError
+		slog.DebugContext(ctx, "validation failed", "error", err)
+		return nil, err
+	}
+
+	current, err := q.GetUser(ctx, database.GetUserParams{Userid: int32(id)})
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	return replaceUser(ctx, current, version, user, q)
+}
+
+// replaceUser stores user over the current row after checking the caller may make the change.
+// The row is only written while it is still at the version of current.
+func replaceUser(ctx context.Context, current database.User, version int32, user dto.User, q database.Querier) (*database.User, error) {
+	err := checkVersion(current, version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkEditable(ctx, int(current.Userid), UserFromDB(current), user)
+	if err != nil {
+		return nil, err
+	}
+
+	var dbUser database.User
+	err = q.ExecTx(ctx, func(tx database.Querier) error {
+		dbUser, err = tx.UpdateUser(ctx, database.UpdateUserParams{
+			Userid:     current.Userid,
+			Firstname:  user.Firstname,
+			Lastname:   user.Lastname,
+			Email:      user.Email,
+			Phone:      nullableText(user.Phone),
+			Age:        nullableInt4(user.Age),
+			UserStatus: userStatus(user.Status),
+			Version:    current.Version,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// changed or deleted by another request since current was read
+			return versionMismatchError(err)
+		}
+		if err != nil {
+			return err
 		}
-		msg := "Validation Failed on: " + formatError(validationFiledErr)
-		fmt.Println(msg)
-		return msg, http.StatusBadRequest
-	}
-
-	updateErr := q.UpdateUser(ctx, database.UpdateUserParams{
-		Userid:    int32(id),
-		Firstname: user.Firstname,
-		Lastname:  user.Lastname,
-		Email:     user.Email,
-		Phone:     pgtype.Text{String: user.Phone, Valid: true},
-		Age:       pgtype.Int4{Int32: user.Age, Valid: true},
-		UserStatus: database.NullUserstatus{
-			Userstatus: database.Userstatus(user.Status),
-			Valid:      true,
-		},
+		return recordAudit(ctx, database.AuditactionUpdate, &current, &dbUser, tx)
 	})
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return &dbUser, nil
+}
+
+// DeleteUser soft deletes the user. It can be brought back with RestoreUser until it is purged.
+// The version must match the current version of the user, or be AnyVersion.
+func DeleteUser(ctx context.Context, id int, version int32, q database.Querier) error {
+	ctx, span := startSpan(ctx, "DeleteUser", userIDAttr(id))
+	defer span.End()
+
+	return q.ExecTx(ctx, func(tx database.Querier) error {
+		before, err := tx.GetUser(ctx, database.GetUserParams{Userid: int32(id)})
+		if err != nil {
+			return storeError(err)
+		}
+		err = checkVersion(before, version)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := tx.DeleteUser(ctx, database.DeleteUserParams{Userid: int32(id), Version: before.Version})
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return versionMismatchError(nil)
+		}
+
+		after, err := tx.GetUser(ctx, database.GetUserParams{Userid: int32(id), IncludeDeleted: true})
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, database.AuditactionDelete, &before, &after, tx)
+	})
+}
+
+// RestoreUser undoes the soft delete of a user.
+func RestoreUser(ctx context.Context, id int, q database.Querier) (*database.User, error) {
+	ctx, span := startSpan(ctx, "RestoreUser", userIDAttr(id))
+	defer span.End()
+
+	var dbUser database.User
+	err := q.ExecTx(ctx, func(tx database.Querier) error {
+		before, err := tx.GetUser(ctx, database.GetUserParams{Userid: int32(id), IncludeDeleted: true})
+		if err != nil {
+			return err
+		}
+
+		dbUser, err = tx.RestoreUser(ctx, int32(id))
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, database.AuditactionRestore, &before, &dbUser, tx)
+	})
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return &dbUser, nil
+}
+
+// UserFromDB converts a users row to its dto.User representation.
+func UserFromDB(u database.User) dto.User {
+	user := dto.User{
+		Firstname: u.Firstname,
+		Lastname:  u.Lastname,
+		Email:     u.Email,
+	}
+	if u.Phone.Valid {
+		user.Phone = &u.Phone.String
+	}
+	if u.Age.Valid {
+		user.Age = &u.Age.Int32
+	}
+	if u.UserStatus.Valid {
+		user.Status = string(u.UserStatus.Userstatus)
+	}
+	return user
+}
+
+func nullableText(s *string) pgtype.Text {
+	if s == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: *s, Valid: true}
+}
 
-	if updateErr != nil {
-		return "Internal Server Error", http.StatusInternalServerError
+func nullableInt4(i *int32) pgtype.Int4 {
+	if i == nil {
+		return pgtype.Int4{}
 	}
-	return "", http.StatusOK
+	return pgtype.Int4{Int32: *i, Valid: true}
 }
 
-func formatError(err validator.FieldError) string {
-	switch err.Tag() {
-	case "required":
-		return fmt.Sprintf("%s is a required field", err.Field())
-	case "email":
-		return fmt.Sprintf("%s must be a valid email address", err.Field())
-	case "min":
-		return fmt.Sprintf("%s must be at least %s long", err.Field(), err.Param())
-	case "max":
-		return fmt.Sprintf("%s must be at most %s long", err.Field(), err.Param())
-	case "gt":
-		return fmt.Sprintf("%s must be positive value", err.Field())
-	case "e164":
-		return fmt.Sprintf("%s must be a valid phone number", err.Field())
-	default:
-		return fmt.Sprintf("%s failed validation with tag %s", err.Field(), err.Tag())
+// userStatus returns the status to store, Active when omitted like the
+// column default, which an explicit NULL would bypass.
+func userStatus(status string) database.NullUserstatus {
+	if status == "" {
+		status = string(database.UserstatusActive)
 	}
+	return database.NullUserstatus{Userstatus: database.Userstatus(status), Valid: true}
 }
@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"strings"
 	"testing"
+	"time"
 	"user-manager/database"
 	"user-manager/dto"
 
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -16,16 +21,16 @@ func TestCreateUserInvalidFirstName(t *testing.T) {
 		Firstname: "a",
 		Lastname:  "abd",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
-	_, msg, status := CreateUser(t.Context(), user, nil)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := CreateUser(t.Context(), user, nil)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusBadRequest {
-		t.Errorf("Test Failure! Incorrect status")
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
 	}
 }
 
@@ -34,16 +39,16 @@ func TestCreateUserInvalidLastName(t *testing.T) {
 		Firstname: "abc",
 		Lastname:  "a",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
-	_, msg, status := CreateUser(t.Context(), user, nil)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := CreateUser(t.Context(), user, nil)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusBadRequest {
-		t.Errorf("Test Failure! Incorrect status")
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
 	}
 }
 
@@ -52,16 +57,16 @@ func TestCreateUserInvalidEmail(t *testing.T) {
 		Firstname: "abc",
 		Lastname:  "ajuuoi",
 		Email:     "jaygmail.com",
-		Phone:     "+0722134567",
-		Age:       30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
-	_, msg, status := CreateUser(t.Context(), user, nil)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := CreateUser(t.Context(), user, nil)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusBadRequest {
-		t.Errorf("Test Failure! Incorrect status")
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
 	}
 }
 
@@ -70,16 +75,16 @@ func TestCreateUserInvalidPhone(t *testing.T) {
 		Firstname: "abc",
 		Lastname:  "anhgd",
 		Email:     "jay@gmail.com",
-		Phone:     "722134567",
-		Age:       30,
+		Phone:     ptr("722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
-	_, msg, status := CreateUser(t.Context(), user, nil)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := CreateUser(t.Context(), user, nil)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusBadRequest {
-		t.Errorf("Test Failure! Incorrect status")
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
 	}
 }
 
@@ -88,16 +93,16 @@ func TestUpdateUserInvalidAge(t *testing.T) {
 		Firstname: "abc",
 		Lastname:  "abdsd",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       -30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(-30)),
 		Status:    string(database.UserstatusActive),
 	}
 
-	msg, status := UpdateUser(t.Context(), 2, user, nil)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := UpdateUser(t.Context(), 2, AnyVersion, user, nil)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusBadRequest {
-		t.Errorf("Test Failure! Incorrect status")
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Incorrect error")
 	}
 }
 
@@ -106,18 +111,39 @@ func TestCreateUserSuccess(t *testing.T) {
 		Firstname: "Jay",
 		Lastname:  "Vas",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
 	mockDb := &MockDb{}
 
-	_, msg, status := CreateUser(t.Context(), user, mockDb)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := CreateUser(t.Context(), user, mockDb)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusCreated {
-		t.Errorf("Test Failure! Incorrect status")
+	if err != nil {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestCreateUserWithoutStatus(t *testing.T) {
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "jay@gmail.com",
+	}
+
+	mockDb := &MockDb{}
+
+	_, err := CreateUser(t.Context(), user, mockDb)
+	if err != nil {
+		t.Fatalf("Test Failure! Incorrect error %v", err)
+	}
+
+	// an explicit NULL would bypass the column default
+	status := mockDb.Created[0].UserStatus
+	if !status.Valid || status.Userstatus != database.UserstatusActive {
+		t.Errorf("Test Failure! Expected the Active status, got %+v", status)
 	}
 }
 
@@ -126,25 +152,129 @@ func TestUpdateUserSuccess(t *testing.T) {
 		Firstname: "Jay",
 		Lastname:  "Vas",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
 	mockDb := &MockDb{}
 
-	msg, status := UpdateUser(t.Context(), 1, user, mockDb)
-	fmt.Println("error message: ", msg, " status: ", status)
+	_, err := UpdateUser(t.Context(), 1, AnyVersion, user, mockDb)
+	fmt.Println("error: ", err)
+
+	if err != nil {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestCreateUserDuplicateEmail(t *testing.T) {
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "Jay@gmail.com",
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
+		Status:    string(database.UserstatusActive),
+	}
+
+	mockDb := &MockDb{Err: &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: "users_email_key"}}
+
+	_, err := CreateUser(t.Context(), user, mockDb)
+	fmt.Println("error: ", err)
 
-	if status != http.StatusOK {
-		t.Errorf("Test Failure! Incorrect status")
+	if !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "email") {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestUpdateUserDuplicateEmail(t *testing.T) {
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "Jay@gmail.com",
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
+		Status:    string(database.UserstatusActive),
+	}
+
+	mockDb := &MockDb{Err: &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: "users_email_key"}}
+
+	_, err := UpdateUser(t.Context(), 1, AnyVersion, user, mockDb)
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "email") {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestCreateUserReportsEveryInvalidField(t *testing.T) {
+	user := dto.User{
+		Firstname: "a",
+		Email:     "jaygmail.com",
+		Phone:     ptr("722134567"),
+		Age:       ptr(int32(30)),
+	}
+
+	_, err := CreateUser(t.Context(), user, nil)
+	fmt.Println("error: ", err)
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || serviceErr.Code != CodeValidationFailed {
+		t.Fatalf("Test Failure! Incorrect error")
+	}
+
+	codes := map[string]string{}
+	for _, field := range serviceErr.Fields {
+		codes[field.Field] = field.Code
+	}
+	expected := map[string]string{
+		"firstName": "too_short",
+		"lastName":  "required",
+		"email":     "invalid_email",
+		"phone":     "invalid_phone",
+	}
+	for field, code := range expected {
+		if codes[field] != code {
+			t.Errorf("Test Failure! Expected %s for %s. Received %s", code, field, codes[field])
+		}
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	err := DeleteUser(t.Context(), 2, AnyVersion, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect error")
 	}
 }
 
 type MockDb struct {
+	Err error
+
+	PurgedBefore time.Time
+	Roles        []database.Userrole
+
+	PasswordHash  string
+	RefreshTokens map[string]database.RefreshToken
+	RevokedTokens map[string]bool
+
+	Audit []database.CreateAuditEventParams
+
+	Created  []database.CreateUserParams
+	Imported []database.ImportUsersParams
+
+	Outbox     []database.CreateOutboxEventParams
+	Deliveries []database.ClaimWebhookDeliveriesRow
+	Attempts   []database.RecordWebhookAttemptParams
 }
 
 func (m *MockDb) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	if m.Err != nil {
+		return database.User{}, m.Err
+	}
+	m.Created = append(m.Created, arg)
+
 	dbUser := database.User{
 		Userid:    1,
 		Firstname: "Jay",
@@ -156,11 +286,355 @@ func (m *MockDb) CreateUser(ctx context.Context, arg database.CreateUserParams)
 			Userstatus: database.UserstatusActive,
 			Valid:      true,
 		},
+		Version: 1,
 	}
 
 	return dbUser, nil
 }
 
-func (m *MockDb) UpdateUser(ctx context.Context, arg database.UpdateUserParams) error {
+func (m *MockDb) GetUser(ctx context.Context, arg database.GetUserParams) (database.User, error) {
+	if m.Err != nil {
+		return database.User{}, m.Err
+	}
+	if arg.Userid != 1 {
+		return database.User{}, pgx.ErrNoRows
+	}
+
+	return m.CreateUser(ctx, database.CreateUserParams{})
+}
+
+func (m *MockDb) ListUsers(ctx context.Context) ([]database.User, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	user, _ := m.CreateUser(ctx, database.CreateUserParams{})
+	return []database.User{user}, nil
+}
+
+func (m *MockDb) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	if m.Err != nil {
+		return database.User{}, m.Err
+	}
+
+	dbUser := database.User{
+		Userid:     arg.Userid,
+		Firstname:  arg.Firstname,
+		Lastname:   arg.Lastname,
+		Email:      arg.Email,
+		Phone:      arg.Phone,
+		Age:        arg.Age,
+		UserStatus: arg.UserStatus,
+		Version:    arg.Version + 1,
+	}
+
+	return dbUser, nil
+}
+
+func (m *MockDb) DeleteUser(ctx context.Context, arg database.DeleteUserParams) (int64, error) {
+	if arg.Userid != 1 || arg.Version != 1 {
+		return 0, m.Err
+	}
+	return 1, m.Err
+}
+
+func (m *MockDb) RestoreUser(ctx context.Context, userid int32) (database.User, error) {
+	if m.Err != nil {
+		return database.User{}, m.Err
+	}
+
+	return m.CreateUser(ctx, database.CreateUserParams{})
+}
+
+func (m *MockDb) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]database.User, error) {
+	m.PurgedBefore = deletedBefore.Time
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	users := []database.User{}
+	for id := range int32(3) {
+		users = append(users, database.User{Userid: id + 1, DeletedAt: deletedBefore})
+	}
+	return users, nil
+}
+
+func (m *MockDb) ListUserRoles(ctx context.Context, userid int32) ([]database.Userrole, error) {
+	return m.Roles, m.Err
+}
+
+func (m *MockDb) SetUserRoles(ctx context.Context, arg database.SetUserRolesParams) error {
+	m.Roles = nil
+	for _, role := range arg.Roles {
+		m.Roles = append(m.Roles, database.Userrole(role))
+	}
+	return m.Err
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func (m *MockDb) ListUsersPage(ctx context.Context, arg database.ListUsersPageParams) ([]database.User, error) {
+	users := []database.User{}
+	for i := int32(1); i <= arg.Limit; i++ {
+		users = append(users, database.User{
+			Userid:    i,
+			Firstname: fmt.Sprintf("Jay%d", i),
+			Lastname:  "Vas",
+			Email:     fmt.Sprintf("jay%d@gmail.com", i),
+		})
+	}
+	return users, nil
+}
+
+func (m *MockDb) CountUsers(ctx context.Context, arg database.ListUsersFilter) (int64, error) {
+	return 50, nil
+}
+
+// ExportUsers streams the CreateUser fixture and a user with a formula as name.
+func (m *MockDb) ExportUsers(ctx context.Context, arg database.ExportUsersParams, fn func(database.User) error) error {
+	fixture, _ := m.CreateUser(ctx, database.CreateUserParams{})
+	users := []database.User{fixture, {Userid: 2, Firstname: "=SUM(A1)", Lastname: "<Vas>", Email: "eq@gmail.com", Version: 3}}
+	for _, u := range users {
+		err := fn(u)
+		if err != nil {
+			return err
+		}
+	}
+	return m.Err
+}
+
+func (m *MockDb) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.SearchUsersRow, error) {
+	return nil, nil
+}
+
+func (m *MockDb) GetCredential(ctx context.Context, userid int32) (database.UserCredential, error) {
+	if m.PasswordHash == "" {
+		return database.UserCredential{}, pgx.ErrNoRows
+	}
+	return database.UserCredential{Userid: userid, PasswordHash: m.PasswordHash}, m.Err
+}
+
+func (m *MockDb) GetCredentialByEmail(ctx context.Context, email string) (database.GetCredentialByEmailRow, error) {
+	if m.PasswordHash == "" || email != "jay@gmail.com" {
+		return database.GetCredentialByEmailRow{}, pgx.ErrNoRows
+	}
+	return database.GetCredentialByEmailRow{
+		Userid:       1,
+		UserStatus:   database.NullUserstatus{Userstatus: database.UserstatusActive, Valid: true},
+		PasswordHash: m.PasswordHash,
+	}, m.Err
+}
+
+func (m *MockDb) UpsertCredential(ctx context.Context, arg database.UpsertCredentialParams) error {
+	m.PasswordHash = arg.PasswordHash
+	return m.Err
+}
+
+func (m *MockDb) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	if m.RefreshTokens == nil {
+		m.RefreshTokens = map[string]database.RefreshToken{}
+	}
+	m.RefreshTokens[string(arg.TokenHash)] = database.RefreshToken{
+		TokenHash: arg.TokenHash,
+		Userid:    arg.Userid,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	return m.Err
+}
+
+func (m *MockDb) GetRefreshToken(ctx context.Context, tokenHash []byte) (database.RefreshToken, error) {
+	token, ok := m.RefreshTokens[string(tokenHash)]
+	if !ok {
+		return database.RefreshToken{}, pgx.ErrNoRows
+	}
+	return token, m.Err
+}
+
+func (m *MockDb) RevokeRefreshToken(ctx context.Context, tokenHash []byte) (int32, error) {
+	token, ok := m.RefreshTokens[string(tokenHash)]
+	if !ok || token.RevokedAt.Valid {
+		return 0, pgx.ErrNoRows
+	}
+	token.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	m.RefreshTokens[string(tokenHash)] = token
+	return token.Userid, m.Err
+}
+
+func (m *MockDb) RevokeUserRefreshTokens(ctx context.Context, userid int32) (int64, error) {
+	var revoked int64
+	for hash, token := range m.RefreshTokens {
+		if token.Userid == userid && !token.RevokedAt.Valid {
+			token.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			m.RefreshTokens[hash] = token
+			revoked++
+		}
+	}
+	return revoked, m.Err
+}
+
+func (m *MockDb) RevokeAccessToken(ctx context.Context, arg database.RevokeAccessTokenParams) error {
+	if m.RevokedTokens == nil {
+		m.RevokedTokens = map[string]bool{}
+	}
+	m.RevokedTokens[arg.Jti] = true
+	return m.Err
+}
+
+func (m *MockDb) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return m.RevokedTokens[jti], m.Err
+}
+
+func (m *MockDb) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	return 0, m.Err
+}
+
+func (m *MockDb) ExecTx(ctx context.Context, fn func(database.Querier) error) error {
+	return fn(m)
+}
+
+func (m *MockDb) CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) error {
+	m.Audit = append(m.Audit, arg)
 	return nil
 }
+
+func (m *MockDb) ListAuditEvents(ctx context.Context, arg database.ListAuditEventsParams) ([]database.AuditEvent, error) {
+	events := []database.AuditEvent{}
+	for i := int64(50); i > 0 && int32(len(events)) < arg.MaxResults; i-- {
+		if arg.BeforeID != 0 && i >= arg.BeforeID {
+			continue
+		}
+		events = append(events, database.AuditEvent{ID: i, Userid: arg.Userid, Action: database.AuditactionUpdate})
+	}
+	return events, m.Err
+}
+
+func TestUpdateUserStaleVersion(t *testing.T) {
+	user := dto.User{
+		Firstname: "Jay",
+		Lastname:  "Vas",
+		Email:     "jay@gmail.com",
+	}
+
+	_, err := UpdateUser(t.Context(), 1, 5, user, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrPrecondition) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestDeleteUserStaleVersion(t *testing.T) {
+	err := DeleteUser(t.Context(), 1, 5, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if !errors.Is(err, ErrPrecondition) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func (m *MockDb) ImportUsers(ctx context.Context, arg []database.ImportUsersParams) (int64, error) {
+	if m.Err != nil {
+		return 0, m.Err
+	}
+	m.Imported = append(m.Imported, arg...)
+	return int64(len(arg)), nil
+}
+
+// ListUsersByEmails finds the CreateUser fixture and the imported users.
+func (m *MockDb) ListUsersByEmails(ctx context.Context, emails []string) ([]database.User, error) {
+	users := []database.User{}
+	for _, email := range emails {
+		if email == "jay@gmail.com" {
+			fixture, _ := m.CreateUser(ctx, database.CreateUserParams{})
+			users = append(users, fixture)
+		}
+		for i, imported := range m.Imported {
+			if strings.EqualFold(imported.Email, email) {
+				users = append(users, database.User{Userid: int32(100 + i), Email: imported.Email, Version: 1})
+			}
+		}
+	}
+	return users, nil
+}
+
+func (m *MockDb) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	return database.Webhook{ID: 1, Url: arg.Url, Secret: arg.Secret, Events: arg.Events, Active: arg.Active}, m.Err
+}
+
+func (m *MockDb) GetWebhook(ctx context.Context, id int32) (database.Webhook, error) {
+	if id != 1 {
+		return database.Webhook{}, pgx.ErrNoRows
+	}
+	return database.Webhook{ID: 1, Url: "https://example.com/hook", Secret: "secret", Events: []string{EventUserCreated}, Active: true}, m.Err
+}
+
+func (m *MockDb) ListWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	hook, err := m.GetWebhook(ctx, 1)
+	return []database.Webhook{hook}, err
+}
+
+func (m *MockDb) UpdateWebhook(ctx context.Context, arg database.UpdateWebhookParams) (database.Webhook, error) {
+	if arg.ID != 1 {
+		return database.Webhook{}, pgx.ErrNoRows
+	}
+	return database.Webhook{ID: 1, Url: arg.Url, Secret: "secret", Events: arg.Events, Active: arg.Active}, m.Err
+}
+
+func (m *MockDb) DeleteWebhook(ctx context.Context, id int32) (int64, error) {
+	if id != 1 {
+		return 0, m.Err
+	}
+	return 1, m.Err
+}
+
+func (m *MockDb) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) error {
+	m.Outbox = append(m.Outbox, arg)
+	return m.Err
+}
+
+func (m *MockDb) FanOutWebhookEvents(ctx context.Context, maxEvents int32) (int64, error) {
+	return int64(len(m.Outbox)), m.Err
+}
+
+func (m *MockDb) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	return m.Deliveries, m.Err
+}
+
+func (m *MockDb) RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error {
+	m.Attempts = append(m.Attempts, arg)
+	return m.Err
+}
+
+func (m *MockDb) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.ListWebhookDeliveriesRow, error) {
+	rows := []database.ListWebhookDeliveriesRow{}
+	for id := int64(30); id > 0 && int32(len(rows)) < arg.MaxResults; id-- {
+		if arg.BeforeID == 0 || id < arg.BeforeID {
+			rows = append(rows, database.ListWebhookDeliveriesRow{ID: id, WebhookID: arg.WebhookID, Status: database.DeliverystatusDelivered, EventType: EventUserCreated})
+		}
+	}
+	return rows, m.Err
+}
+
+func (m *MockDb) DeleteFinishedWebhookEvents(ctx context.Context, dispatchedBefore pgtype.Timestamptz) (int64, error) {
+	m.PurgedBefore = dispatchedBefore.Time
+	return 2, m.Err
+}
+
+func (m *MockDb) RetryWebhookDelivery(ctx context.Context, arg database.RetryWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	if arg.ID != 7 {
+		return database.WebhookDelivery{}, pgx.ErrNoRows
+	}
+	return database.WebhookDelivery{ID: 7, WebhookID: arg.WebhookID, Status: database.DeliverystatusPending}, m.Err
+}
+
+// ListUserEvents yields an update and a delete event after arg.AfterID.
+func (m *MockDb) ListUserEvents(ctx context.Context, arg database.ListUserEventsParams) ([]database.ListUserEventsRow, error) {
+	return []database.ListUserEventsRow{
+		{Seq: arg.AfterID + 1, Userid: 1, Action: database.AuditactionUpdate, Changes: []byte(`{"email":{"from":"a@example.com","to":"b@example.com"},"age":{"from":30,"to":31}}`)},
+		{Seq: arg.AfterID + 2, Userid: 1, Action: database.AuditactionDelete, Changes: []byte(`{}`)},
+	}, m.Err
+}
+
+func (m *MockDb) LatestUserEventID(ctx context.Context) (int64, error) {
+	return 50, m.Err
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"user-manager/dto"
+)
+
+// allowPrivateWebhooks lets webhooks target loopback, private and link-local
+// addresses, which are refused by default so that webhooks can not be used to
+// reach internal services.
+var allowPrivateWebhooks = false
+
+// lookupWebhookHost resolves the host of a webhook URL.
+var lookupWebhookHost = net.DefaultResolver.LookupNetIP
+
+// ConfigureWebhooks sets whether webhooks may target internal addresses.
+func ConfigureWebhooks(allowPrivate bool) {
+	allowPrivateWebhooks = allowPrivate
+}
+
+// checkWebhookURL refuses webhook URLs that are not http or https, or whose
+// host resolves to an internal address. The dispatcher checks the address
+// again when connecting, as the host may resolve differently by then.
+func checkWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return webhookURLError("URL must be an http or https URL")
+	}
+	if allowPrivateWebhooks {
+		return nil
+	}
+
+	addrs, err := lookupWebhookHost(ctx, "ip", u.Hostname())
+	if err != nil {
+		return webhookURLError("URL host can not be resolved")
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return webhookURLError(fmt.Sprintf("URL host resolves to the internal address %s", addr.Unmap()))
+		}
+	}
+	return nil
+}
+
+func webhookURLError(message string) *Error {
+	return validationError(CodeValidationFailed, "Validation failed on 1 field(s)", dto.FieldError{
+		Field:   "URL",
+		Tag:     "webhook_url",
+		Code:    CodeWebhookURL,
+		Message: message,
+	})
+}
+
+// publicAddress reports whether addr may be called by webhooks.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// dialWebhookAddress is the net.Dialer Control function of the dispatcher. It
+// refuses connections to internal addresses, whatever the URL resolved to.
+func dialWebhookAddress(network, address string, c syscall.RawConn) error {
+	if allowPrivateWebhooks {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is not allowed", addrPort.Addr().Unmap())
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// webhookBatchSize is the number of deliveries attempted concurrently.
+	webhookBatchSize = 20
+	// webhookFanOutSize is the number of outbox events fanned out per round.
+	webhookFanOutSize = 500
+	webhookBaseDelay  = 10 * time.Second
+	webhookMaxDelay   = 6 * time.Hour
+)
+
+// WebhookDispatcher delivers the events of the outbox to the subscribed webhooks.
+type WebhookDispatcher struct {
+	Client *http.Client
+	// MaxAttempts is the number of failed attempts after which a delivery is dead.
+	MaxAttempts int32
+}
+
+// NewWebhookDispatcher returns a dispatcher whose requests time out after timeout.
+// Redirects are not followed, and connections to internal addresses are refused.
+func NewWebhookDispatcher(timeout time.Duration, maxAttempts int) *WebhookDispatcher {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialWebhookAddress}
+	return &WebhookDispatcher{
+		Client: &http.Client{
+			Timeout: timeout,
+			// no proxy, so that the dialed address is the one of the webhook
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: timeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		MaxAttempts: int32(maxAttempts),
+	}
+}
+
+// Run dispatches webhooks every interval until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context, interval time.Duration, q database.Querier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			attempted, err := d.Dispatch(ctx, q)
+			if err != nil {
+				slog.ErrorContext(ctx, "error on dispatching webhooks", "error", err)
+			}
+			// a full batch means more deliveries may be due
+			if err != nil || attempted < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch fans new outbox events out to the subscribed webhooks, then attempts
+// one batch of due deliveries. It returns the number of deliveries attempted.
+// Claimed deliveries are leased, so that several replicas can dispatch together.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, q database.Querier) (int, error) {
+	ctx, span := startSpan(ctx, "WebhookDispatcher.Dispatch")
+	defer span.End()
+
+	_, err := q.FanOutWebhookEvents(ctx, webhookFanOutSize)
+	if err != nil {
+		return 0, err
+	}
+
+	deliveries, err := q.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseSeconds: 2 * d.Client.Timeout.Seconds(),
+		MaxResults:   webhookBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(deliveries))
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statusCode, err := d.deliver(ctx, delivery)
+			errs[i] = q.RecordWebhookAttempt(ctx, d.attemptResult(delivery, statusCode, err))
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver posts the event of a delivery and returns the response status code.
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) (int, error) {
+	body, err := json.Marshal(dto.WebhookEvent{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.EventCreatedAt.Time,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "user-manager-webhooks")
+	req.Header.Set("Webhook-Id", strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set("Webhook-Event", delivery.EventType)
+	req.Header.Set("Webhook-Timestamp", timestamp)
+	req.Header.Set("Webhook-Signature", SignWebhook(delivery.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// attemptResult decides the state of a delivery after an attempt: delivered,
+// retried later with exponential backoff, or dead after MaxAttempts failures.
+func (d *WebhookDispatcher) attemptResult(delivery database.ClaimWebhookDeliveriesRow, statusCode int, err error) database.RecordWebhookAttemptParams {
+	result := database.RecordWebhookAttemptParams{
+		ID:             delivery.ID,
+		Status:         database.DeliverystatusDelivered,
+		NextAttemptAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		LastStatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0},
+	}
+	if err == nil {
+		return result
+	}
+
+	result.LastError = pgtype.Text{String: err.Error(), Valid: true}
+	if delivery.Attempts >= d.MaxAttempts {
+		result.Status = database.DeliverystatusDead
+		return result
+	}
+	result.Status = database.DeliverystatusPending
+	result.NextAttemptAt.Time = time.Now().Add(webhookBackoff(delivery.Attempts))
+	return result
+}
+
+// webhookBackoff returns the delay before the attempt following attempt: it
+// doubles from webhookBaseDelay up to webhookMaxDelay, with jitter so that
+// retries of many deliveries spread out.
+func webhookBackoff(attempt int32) time.Duration {
+	delay := webhookMaxDelay
+	if attempt < 20 {
+		delay = min(webhookBaseDelay<<(attempt-1), webhookMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// SignWebhook returns the Webhook-Signature header of a payload: the hex
+// encoded HMAC-SHA256 of the timestamp, a dot and the body, keyed by the
+// secret of the webhook.
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
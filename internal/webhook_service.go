@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"user-manager/database"
+	"user-manager/dto"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Webhook event types.
+const (
+	EventUserCreated     = "user.created"
+	EventUserUpdated     = "user.updated"
+	EventUserDeactivated = "user.deactivated"
+	EventUserDeleted     = "user.deleted"
+	EventUserRestored    = "user.restored"
+	// EventUserPurged is only sent by the user event stream.
+	EventUserPurged = "user.purged"
+)
+
+// enqueueWebhookEvents writes the webhook events of a change of a user row to
+// the outbox. Pass the transaction making the change as q, so that events are
+// only delivered for committed changes.
+func enqueueWebhookEvents(ctx context.Context, action database.Auditaction, before *database.User, after *database.User, q database.Querier) error {
+	user := after
+	var types []string
+	switch action {
+	case database.AuditactionCreate:
+		types = []string{EventUserCreated}
+	case database.AuditactionUpdate:
+		types = []string{EventUserUpdated}
+		if after.UserStatus.Userstatus == database.UserstatusInactive && before.UserStatus != after.UserStatus {
+			types = append(types, EventUserDeactivated)
+		}
+	case database.AuditactionDelete:
+		types = []string{EventUserDeleted}
+		user = before
+	case database.AuditactionRestore:
+		types = []string{EventUserRestored}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+
+	payload, _, err := auditSnapshot(user)
+	if err != nil {
+		return err
+	}
+	for _, eventType := range types {
+		err = q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+			EventType: eventType,
+			Userid:    user.Userid,
+			Payload:   payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateWebhook subscribes a URL to user events. The returned webhook holds
+// the generated signing secret, which is not returned again.
+func CreateWebhook(ctx context.Context, input dto.WebhookInput, q database.Querier) (*dto.Webhook, error) {
+	ctx, span := startSpan(ctx, "CreateWebhook")
+	defer span.End()
+
+	err := validate(input)
+	if err != nil {
+		return nil, err
+	}
+	err = checkWebhookURL(ctx, input.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := q.CreateWebhook(ctx, database.CreateWebhookParams{
+		Url:    input.URL,
+		Secret: "whsec_" + hex.EncodeToString(secret),
+		Events: input.Events,
+		Active: input.Active == nil || *input.Active,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := webhookFromDB(hook)
+	webhook.Secret = hook.Secret
+	return &webhook, nil
+}
+
+func ListWebhooks(ctx context.Context, q database.Querier) ([]dto.Webhook, error) {
+	ctx, span := startSpan(ctx, "ListWebhooks")
+	defer span.End()
+
+	hooks, err := q.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]dto.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		webhooks = append(webhooks, webhookFromDB(hook))
+	}
+	return webhooks, nil
+}
+
+func GetWebhook(ctx context.Context, id int, q database.Querier) (*dto.Webhook, error) {
+	ctx, span := startSpan(ctx, "GetWebhook", webhookIDAttr(id))
+	defer span.End()
+
+	hook, err := q.GetWebhook(ctx, int32(id))
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	webhook := webhookFromDB(hook)
+	return &webhook, nil
+}
+
+// UpdateWebhook replaces the URL, events and active flag of a webhook. The secret is kept.
+func UpdateWebhook(ctx context.Context, id int, input dto.WebhookInput, q database.Querier) (*dto.Webhook, error) {
+	ctx, span := startSpan(ctx, "UpdateWebhook", webhookIDAttr(id))
+	defer span.End()
+
+	err := validate(input)
+	if err != nil {
+		return nil, err
+	}
+	err = checkWebhookURL(ctx, input.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := q.UpdateWebhook(ctx, database.UpdateWebhookParams{
+		ID:     int32(id),
+		Url:    input.URL,
+		Events: input.Events,
+		Active: input.Active == nil || *input.Active,
+	})
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	webhook := webhookFromDB(hook)
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook with its delivery history.
+func DeleteWebhook(ctx context.Context, id int, q database.Querier) error {
+	ctx, span := startSpan(ctx, "DeleteWebhook", webhookIDAttr(id))
+	defer span.End()
+
+	deleted, err := q.DeleteWebhook(ctx, int32(id))
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return webhookError(pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns a page of the deliveries of a webhook, newest first.
+func ListWebhookDeliveries(ctx context.Context, id int, params dto.DeliveryListParams, q database.Querier) (*dto.WebhookDeliveryPage, error) {
+	ctx, span := startSpan(ctx, "ListWebhookDeliveries", webhookIDAttr(id))
+	defer span.End()
+
+	err := validate(params)
+	if err != nil {
+		return nil, err
+	}
+	_, err = q.GetWebhook(ctx, int32(id))
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	var beforeID int64
+	if params.Cursor != "" {
+		beforeID, err = decodeAuditCursor(params.Cursor)
+		if err != nil {
+			return nil, validationError(CodeInvalidCursor, "cursor is malformed")
+		}
+	}
+
+	rows, err := q.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{
+		WebhookID:  int32(id),
+		BeforeID:   beforeID,
+		MaxResults: limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dto.WebhookDeliveryPage{Deliveries: []dto.WebhookDelivery{}}
+	if int32(len(rows)) > limit {
+		rows = rows[:limit]
+		page.NextCursor = encodeAuditCursor(rows[len(rows)-1].ID)
+	}
+	for _, row := range rows {
+		delivery := deliveryFromDB(database.WebhookDelivery{
+			ID:             row.ID,
+			WebhookID:      row.WebhookID,
+			EventID:        row.EventID,
+			Status:         row.Status,
+			Attempts:       row.Attempts,
+			NextAttemptAt:  row.NextAttemptAt,
+			LastStatusCode: row.LastStatusCode,
+			LastError:      row.LastError,
+			DeliveredAt:    row.DeliveredAt,
+			CreatedAt:      row.CreatedAt,
+		})
+		delivery.EventType = row.EventType
+		page.Deliveries = append(page.Deliveries, delivery)
+	}
+	return page, nil
+}
+
+// RetryWebhookDelivery queues a dead delivery again with a fresh set of attempts.
+func RetryWebhookDelivery(ctx context.Context, id int, deliveryID int64, q database.Querier) (*dto.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "RetryWebhookDelivery", webhookIDAttr(id))
+	defer span.End()
+
+	row, err := q.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{ID: deliveryID, WebhookID: int32(id)})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, &Error{Kind: ErrNotFound, Code: CodeDeliveryNotFound, Detail: "Webhook has no dead delivery with this id", Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	delivery := deliveryFromDB(row)
+	return &delivery, nil
+}
+
+func webhookError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Code: CodeWebhookNotFound, Detail: "Webhook not found", Err: err}
+	}
+	return err
+}
+
+func webhookFromDB(w database.Webhook) dto.Webhook {
+	return dto.Webhook{
+		ID:        w.ID,
+		URL:       w.Url,
+		Events:    w.Events,
+		Active:    w.Active,
+		CreatedAt: w.CreatedAt.Time,
+	}
+}
+
+func deliveryFromDB(d database.WebhookDelivery) dto.WebhookDelivery {
+	delivery := dto.WebhookDelivery{
+		ID:        d.ID,
+		EventID:   d.EventID,
+		Status:    string(d.Status),
+		Attempts:  d.Attempts,
+		LastError: d.LastError.String,
+		CreatedAt: d.CreatedAt.Time,
+	}
+	if d.Status == database.DeliverystatusPending {
+		delivery.NextAttemptAt = &d.NextAttemptAt.Time
+	}
+	if d.LastStatusCode.Valid {
+		delivery.LastStatusCode = &d.LastStatusCode.Int32
+	}
+	if d.DeliveredAt.Valid {
+		delivery.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return delivery
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"user-manager/database"
+	"user-manager/dto"
+)
+
+// stubWebhookLookup resolves every webhook host to addrs during the test.
+func stubWebhookLookup(t *testing.T, addrs ...string) {
+	lookup := lookupWebhookHost
+	t.Cleanup(func() { lookupWebhookHost = lookup })
+	lookupWebhookHost = func(ctx context.Context, network string, host string) ([]netip.Addr, error) {
+		var ips []netip.Addr
+		for _, addr := range addrs {
+			ips = append(ips, netip.MustParseAddr(addr))
+		}
+		return ips, nil
+	}
+}
+
+// allowPrivateWebhooksInTest lets the dispatcher call httptest servers on loopback.
+func allowPrivateWebhooksInTest(t *testing.T) {
+	t.Cleanup(func() { ConfigureWebhooks(false) })
+	ConfigureWebhooks(true)
+}
+
+func TestCreateWebhook(t *testing.T) {
+	stubWebhookLookup(t, "93.184.215.14")
+	webhook, err := CreateWebhook(t.Context(), dto.WebhookInput{URL: "https://example.com/hook", Events: []string{EventUserCreated}}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	if err != nil || !strings.HasPrefix(webhook.Secret, "whsec_") || !webhook.Active {
+		t.Errorf("Test Failure! Incorrect webhook %+v", webhook)
+	}
+}
+
+func TestCreateWebhookInvalid(t *testing.T) {
+	_, err := CreateWebhook(t.Context(), dto.WebhookInput{URL: "ftp://example.com", Events: []string{"user.viewed"}}, &MockDb{})
+	fmt.Println("error: ", err)
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || len(serviceErr.Fields) != 2 || serviceErr.Fields[0].Code != "invalid_url" {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestCreateWebhookInternalAddress(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.0.0.5", "169.254.169.254", "::1", "::ffff:192.168.1.1", "0.0.0.0"} {
+		stubWebhookLookup(t, "93.184.215.14", addr)
+		_, err := CreateWebhook(t.Context(), dto.WebhookInput{URL: "https://example.com/hook", Events: []string{EventUserCreated}}, &MockDb{})
+
+		var serviceErr *Error
+		if !errors.As(err, &serviceErr) || len(serviceErr.Fields) != 1 || serviceErr.Fields[0].Code != CodeWebhookURL {
+			t.Errorf("Test Failure! Webhook to %s accepted: %v", addr, err)
+		}
+	}
+
+	_, err := UpdateWebhook(t.Context(), 1, dto.WebhookInput{URL: "http://localhost:8080/hook", Events: []string{EventUserCreated}}, &MockDb{})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Test Failure! Webhook to localhost accepted: %v", err)
+	}
+
+	allowPrivateWebhooksInTest(t)
+	_, err = UpdateWebhook(t.Context(), 1, dto.WebhookInput{URL: "http://localhost:8080/hook", Events: []string{EventUserCreated}}, &MockDb{})
+	if err != nil {
+		t.Errorf("Test Failure! Webhook to localhost refused while allowed: %v", err)
+	}
+}
+
+func TestWebhookNotFound(t *testing.T) {
+	_, err := ListWebhookDeliveries(t.Context(), 2, dto.DeliveryListParams{}, &MockDb{})
+	fmt.Println("error: ", err)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+
+	err = DeleteWebhook(t.Context(), 2, &MockDb{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+
+	_, err = RetryWebhookDelivery(t.Context(), 1, 8, &MockDb{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Failure! Incorrect error")
+	}
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	page, err := ListWebhookDeliveries(t.Context(), 1, dto.DeliveryListParams{Limit: 20}, &MockDb{})
+	fmt.Println("error: ", err)
+	if err != nil || len(page.Deliveries) != 20 || page.NextCursor == "" {
+		t.Fatalf("Test Failure! Incorrect first page")
+	}
+
+	page, err = ListWebhookDeliveries(t.Context(), 1, dto.DeliveryListParams{Limit: 20, Cursor: page.NextCursor}, &MockDb{})
+	if err != nil || len(page.Deliveries) != 10 || page.Deliveries[0].ID != 10 || page.NextCursor != "" {
+		t.Errorf("Test Failure! Incorrect last page")
+	}
+}
+
+func TestUserChangesQueueWebhookEvents(t *testing.T) {
+	db := &MockDb{}
+
+	_, err := CreateUser(t.Context(), dto.User{Firstname: "Jay", Lastname: "Vas", Email: "jay@gmail.com"}, db)
+	fmt.Println("error: ", err)
+	_, err = PatchUser(t.Context(), 1, 1, MergePatchType, []byte(`{"status": "Inactive"}`), db)
+	fmt.Println("error: ", err)
+
+	types := []string{}
+	for _, event := range db.Outbox {
+		types = append(types, event.EventType)
+	}
+	if strings.Join(types, ",") != "user.created,user.updated,user.deactivated" || db.Outbox[0].Userid != 1 {
+		t.Errorf("Test Failure! Incorrect events %v", types)
+	}
+}
+
+func TestDispatchWebhooks(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Webhook-Signature") != SignWebhook("secret", r.Header.Get("Webhook-Timestamp"), body) {
+			t.Errorf("Test Failure! Incorrect signature")
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	allowPrivateWebhooksInTest(t)
+
+	db := &MockDb{Deliveries: []database.ClaimWebhookDeliveriesRow{
+		{ID: 1, Attempts: 1, Url: server.URL + "/ok", Secret: "secret", EventID: 9, EventType: EventUserCreated, Payload: []byte(`{}`)},
+		{ID: 2, Attempts: 1, Url: server.URL + "/fail", Secret: "secret", EventID: 9, EventType: EventUserCreated, Payload: []byte(`{}`)},
+		{ID: 3, Attempts: 3, Url: server.URL + "/fail", Secret: "secret", EventID: 9, EventType: EventUserCreated, Payload: []byte(`{}`)},
+	}}
+	dispatcher := NewWebhookDispatcher(time.Second, 3)
+
+	attempted, err := dispatcher.Dispatch(t.Context(), db)
+	fmt.Println("error: ", err)
+	if err != nil || attempted != 3 || calls.Load() != 3 || len(db.Attempts) != 3 {
+		t.Fatalf("Test Failure! Deliveries not attempted")
+	}
+
+	results := map[int64]database.RecordWebhookAttemptParams{}
+	for _, attempt := range db.Attempts {
+		results[attempt.ID] = attempt
+	}
+	if results[1].Status != database.DeliverystatusDelivered || results[1].LastStatusCode.Int32 != 200 {
+		t.Errorf("Test Failure! Delivery not delivered %+v", results[1])
+	}
+	if results[2].Status != database.DeliverystatusPending || !results[2].NextAttemptAt.Time.After(time.Now()) || results[2].LastStatusCode.Int32 != 503 {
+		t.Errorf("Test Failure! Delivery not retried %+v", results[2])
+	}
+	if results[3].Status != database.DeliverystatusDead {
+		t.Errorf("Test Failure! Delivery not dead lettered %+v", results[3])
+	}
+}
+
+func TestDispatchWebhooksInternalAddress(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	// registered while the host resolved to a public address
+	db := &MockDb{Deliveries: []database.ClaimWebhookDeliveriesRow{
+		{ID: 1, Attempts: 1, Url: server.URL, Secret: "secret", EventID: 9, EventType: EventUserCreated, Payload: []byte(`{}`)},
+	}}
+	_, err := NewWebhookDispatcher(time.Second, 3).Dispatch(t.Context(), db)
+	fmt.Println("error: ", err)
+
+	if err != nil || calls.Load() != 0 || len(db.Attempts) != 1 {
+		t.Fatalf("Test Failure! Internal address called")
+	}
+	if db.Attempts[0].Status != database.DeliverystatusPending || !strings.Contains(db.Attempts[0].LastError.String, "not allowed") {
+		t.Errorf("Test Failure! Incorrect attempt %+v", db.Attempts[0])
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for attempt, max := range map[int32]time.Duration{1: 10 * time.Second, 3: 40 * time.Second, 30: webhookMaxDelay} {
+		delay := webhookBackoff(attempt)
+		if delay < max/2 || delay > max {
+			t.Errorf("Test Failure! Incorrect delay %s for attempt %d", delay, attempt)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// xlsxParts are the fixed parts of a workbook with a single worksheet.
+// The worksheet itself is streamed by xlsxExport.
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Users" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxExport writes a minimal Office Open XML workbook. Text is written as
+// inline strings so that rows can be streamed without a shared string table.
+type xlsxExport struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+}
+
+func newXLSXExport(w io.Writer) *xlsxExport {
+	return &xlsxExport{zip: zip.NewWriter(w)}
+}
+
+func (e *xlsxExport) header(columns []string) error {
+	for _, part := range xlsxParts {
+		f, err := e.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, part.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = bufio.NewWriter(f)
+	e.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		values[i] = column
+	}
+	return e.row(values)
+}
+
+func (e *xlsxExport) row(values []any) error {
+	e.sheet.WriteString("<row>")
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			e.sheet.WriteString("<c/>")
+		case int32:
+			e.sheet.WriteString("<c><v>" + strconv.Itoa(int(v)) + "</v></c>")
+		case time.Time:
+			e.inlineString(v.Format(time.RFC3339))
+		case string:
+			e.inlineString(v)
+		}
+	}
+	_, err := e.sheet.WriteString("</row>")
+	return err
+}
+
+func (e *xlsxExport) inlineString(s string) {
+	e.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	// EscapeText replaces characters not allowed in XML
+	xml.EscapeText(e.sheet, []byte(s))
+	e.sheet.WriteString("</t></is></c>")
+}
+
+func (e *xlsxExport) close() error {
+	_, err := e.sheet.WriteString("</sheetData></worksheet>")
+	if err != nil {
+		return err
+	}
+	err = e.sheet.Flush()
+	if err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
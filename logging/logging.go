@@ -0,0 +1,95 @@
+// Package logging configures the log/slog logger of the service. Records
+// carry the request ID of their context, and attributes holding secrets are
+// redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are the attribute keys whose values are never logged.
+var secretKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"password_hash": true,
+	"args":          true,
+}
+
+// New returns a logger writing records of at least level to w, as JSON or text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("unknown log level %q, use debug, info, warn or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, use json or text", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// redact replaces the values of secret attributes.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// Secret is a string that is logged redacted, whatever its key.
+type Secret string
+
+func (Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// contextHandler adds the request ID and the trace ID of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// WithRequestID returns a context carrying a request ID, read back with
+// middleware.GetReqID like the IDs of the HTTP requests.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, middleware.RequestIDKey, requestID)
+}
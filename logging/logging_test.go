@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var record map[string]any
+	err := json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestRedactSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("login", "Password", "hunter2", "refresh_token", "abc", "dsn", Secret("postgresql://u:p@db"), "user_id", 42)
+
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "abc") || strings.Contains(buf.String(), "u:p@db") {
+		t.Errorf("Test Failure! Secret logged: %s", buf.String())
+	}
+	record := decode(t, &buf)
+	if record["Password"] != redacted || record["dsn"] != redacted || record["user_id"] != float64(42) {
+		t.Errorf("Test Failure! Unexpected record: %v", record)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.InfoContext(WithRequestID(t.Context(), "req-1"), "user created")
+
+	if record := decode(t, &buf); record["request_id"] != "req-1" {
+		t.Errorf("Test Failure! Request ID not logged: %v", record)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("ignored")
+	if buf.Len() != 0 {
+		t.Errorf("Test Failure! Info logged at warn level: %s", buf.String())
+	}
+	logger.Warn("kept")
+	if !strings.Contains(buf.String(), "msg=kept") {
+		t.Errorf("Test Failure! Warning not logged as text: %s", buf.String())
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", "json")
+	if err == nil {
+		t.Errorf("Test Failure! Unknown level accepted")
+	}
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	if err == nil {
+		t.Errorf("Test Failure! Unknown format accepted")
+	}
+}
+
+func TestRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware.RequestID(Requests(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/users?token=abc", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-2")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get(middleware.RequestIDHeader) != "req-2" {
+		t.Errorf("Test Failure! Request ID not returned: %v", rec.Header())
+	}
+	record := decode(t, &buf)
+	if record["request_id"] != "req-2" || record["status"] != float64(http.StatusTeapot) || record["path"] != "/users" {
+		t.Errorf("Test Failure! Unexpected record: %v", record)
+	}
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// QueryTracer logs the database calls, with the request ID of their context.
+// Failed queries are logged at warn level, the others at debug level only.
+// Query arguments are never logged, as they may hold passwords and tokens.
+func QueryTracer(logger *slog.Logger) *tracelog.TraceLog {
+	level := tracelog.LogLevelError
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		level = tracelog.LogLevelInfo
+	}
+
+	return &tracelog.TraceLog{
+		LogLevel: level,
+		Logger: tracelog.LoggerFunc(func(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+			l := slog.LevelDebug
+			if level <= tracelog.LogLevelWarn {
+				l = slog.LevelWarn
+			}
+			attrs := make([]slog.Attr, 0, len(data))
+			for _, key := range slices.Sorted(maps.Keys(data)) {
+				if key != "args" {
+					attrs = append(attrs, slog.Any(key, data[key]))
+				}
+			}
+			logger.LogAttrs(ctx, l, "database "+msg, attrs...)
+		}),
+	}
+}
@@ -2,40 +2,93 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 	"user-manager/api"
+	"user-manager/auth"
 	"user-manager/config"
 	"user-manager/database"
+	"user-manager/database/memory"
+	"user-manager/database/migrations"
+	"user-manager/database/sqlite"
 	_ "user-manager/docs"
+	"user-manager/dto"
+	"user-manager/grpcapi"
+	"user-manager/health"
+	services "user-manager/internal"
+	"user-manager/logging"
+	"user-manager/metrics"
+	"user-manager/ratelimit"
+	"user-manager/tracing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT bearer token. Use "Bearer <token>"
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal("error on configurations", err)
+		fatal("error on configurations", err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fatal("error on configurations", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter)
+	if err != nil {
+		fatal("error on setting up tracing", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = runMigrate(cfg, os.Args[2:])
+		if err != nil {
+			fatal("error on migrations", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		err = runBootstrapAdmin(cfg, os.Args[2:])
+		if err != nil {
+			fatal("error on bootstrapping the admin", err)
+		}
+		return
 	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(tracing.Requests)
+	r.Use(middleware.RequestID)
+	r.Use(logging.Requests(logger))
+	r.Use(metrics.Requests)
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	r.Handle("/metrics", promhttp.Handler())
+
 	r.Get("/doc/*", httpSwagger.Handler(
 		httpSwagger.URL(fmt.Sprintf("http://localhost:%d/docs/swagger.json", cfg.APPPort)),
 	))
@@ -44,22 +97,80 @@ func main() {
 	filesDir := http.Dir(filepath.Join(workDir, "docs"))
 	fileServer(r, "/docs", filesDir)
 
-	server, err := ConnectDatabase(cfg)
+	server, storage, err := ConnectDatabase(cfg)
 	if err != nil {
-		log.Fatal(err)
+		fatal("error on connecting storage", err)
+	}
+
+	prometheus.MustRegister(metrics.NewUserCollector(storage.Queries))
+	if storage.Pool != nil {
+		prometheus.MustRegister(metrics.NewPoolCollector(storage.Pool))
+
+		applied, err := migrations.Up(context.Background(), storage.Pool)
+		logMigrations("applied migration", applied)
+		if err != nil {
+			fatal("error on migrations", err)
+		}
+	}
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimits.Store == "postgres" {
+		store := ratelimit.NewPostgresStore(database.New(storage.Pool))
+		go store.Run(jobCtx, time.Minute)
+		rateLimitStore = store
 	}
+	clients := &ratelimit.Clients{Verifier: server.Verifier, TrustedProxies: cfg.RateLimits.TrustedProxies}
+	limiter := ratelimit.New(cfg.RateLimits, rateLimitStore, clients.Key)
+
+	// the probes, metrics and docs are not rate limited
+	r.Group(func(limited chi.Router) {
+		limited.Use(limiter.Handler(r))
+		limited.Route("/users", server.UserRouter)
+		limited.Route("/webhooks", server.WebhookRouter)
+		limited.Route("/graphql", server.GraphQLRouter)
+		if server.Issuer != nil {
+			limited.Route("/auth", server.AuthRouter)
+		}
+	})
 
-	r.Route("/users", server.UserRouter)
+	checker := health.NewChecker()
+	checker.Add("database", storage.Ping)
+	checker.Add("migrations", storage.MigrationsApplied)
+	r.Get("/healthz", checker.Live)
+	r.Get("/readyz", checker.Ready)
+
+	go services.RunPurgeJob(jobCtx, cfg.PurgeInterval, cfg.PurgeRetention, cfg.WebhookRetention, server.Queries)
+	dispatcher := services.NewWebhookDispatcher(cfg.WebhookTimeout, cfg.WebhookMaxAttempts)
+	go dispatcher.Run(jobCtx, cfg.WebhookInterval, server.Queries)
+	go storage.Run(jobCtx)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.APPPort),
 		Handler: r,
 	}
+	srv.RegisterOnShutdown(server.CloseStreams)
 	go func() {
-		fmt.Println("Server is Running on port 8080")
-		err = srv.ListenAndServe()
+		slog.Info("server is running", "port", cfg.APPPort)
+		err := srv.ListenAndServe()
 		if err != nil && err != http.ErrServerClosed {
-			log.Fatal("Error on Listening server!")
+			fatal("error on listening server", err)
+		}
+	}()
+
+	grpcServer := grpcapi.NewServer(server.Queries, server.Verifier)
+	grpcSrv := grpcServer.GRPCServer()
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+	if err != nil {
+		fatal("error on listening gRPC port", err)
+	}
+	go func() {
+		slog.Info("gRPC server is running", "port", cfg.GRPCPort)
+		err := grpcSrv.Serve(lis)
+		if err != nil {
+			fatal("error on serving gRPC", err)
 		}
 	}()
 
@@ -67,39 +178,280 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutdown Signal Received")
+	slog.Info("shutdown signal received")
+
+	// fail the readiness probes first, and keep serving until the load
+	// balancers stopped routing new requests here
+	checker.Shutdown()
+	grpcServer.Health.Shutdown()
+	time.Sleep(cfg.ShutdownDelay)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+
 	err = srv.Shutdown(ctx)
 	if err != nil {
-		log.Fatal("Forced Shutdown: ", err)
+		fatal("forced shutdown", err)
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		// streams still open at the deadline are cancelled
+		grpcSrv.Stop()
+	}
+
+	stopJobs()
+
+	storage.Close()
+	slog.Info("storage closed")
+
+	err = shutdownTracing(ctx)
+	if err != nil {
+		slog.Error("error on flushing traces", "error", err)
 	}
 
-	server.Pool.Close()
-	log.Println("DB Connection Pools Closed")
+	slog.Info("server exited gracefully")
+}
 
-	log.Println("Server Exited Gracefully")
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-func ConnectDatabase(cfg *config.Config) (*api.Server, error) {
-	ctx := context.Background()
+func logMigrations(msg string, list []migrations.Migration) {
+	for _, m := range list {
+		slog.Info(msg, "version", m.Version, "name", m.Name)
+	}
+}
+
+// Storage is the storage backend selected by cfg.Storage.
+type Storage struct {
+	Queries database.Querier
+	Events  database.Notifier
+	// Pool is the Postgres pool, nil for the other backends.
+	Pool *pgxpool.Pool
+	// listener wakes Events on Postgres notifications, nil for the other backends.
+	listener *database.Listener
+	ping     func(ctx context.Context) error
+	close    func()
+}
+
+func OpenStorage(ctx context.Context, cfg *config.Config) (*Storage, error) {
+	switch cfg.Storage {
+	case "memory":
+		store := memory.New()
+		return &Storage{Queries: store, Events: store, ping: func(context.Context) error { return nil }, close: func() {}}, nil
+	case "sqlite":
+		store, err := sqlite.Open(ctx, cfg.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{Queries: store, Events: store, ping: store.Ping, close: func() { store.Close() }}, nil
+	}
+
+	pool, err := connectPostgres(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = pool.Ping(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("error on connecting to Postgres: %w", err)
+	}
+	listener := database.NewListener(pool, database.UserEventsChannel)
+	return &Storage{Queries: database.New(pool), Events: listener, Pool: pool, listener: listener, ping: pool.Ping, close: pool.Close}, nil
+}
 
-	connString := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s",
-		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+// Run listens to the Postgres notifications until ctx is done.
+func (s *Storage) Run(ctx context.Context) {
+	if s.listener != nil {
+		s.listener.Run(ctx)
+	}
+}
+
+// Ping checks that the storage can be reached.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.ping(ctx)
+}
+
+// MigrationsApplied fails while Postgres migrations are pending. The other
+// backends create their schema when opened.
+func (s *Storage) MigrationsApplied(ctx context.Context) error {
+	if s.Pool == nil {
+		return nil
+	}
+	pending, err := migrations.Pending(ctx, s.Pool)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations pending, the first is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
 
-	fmt.Println("DB connection string: ", connString)
+func (s *Storage) Close() {
+	s.close()
+}
 
-	pool, err := pgxpool.New(ctx, connString)
+func connectPostgres(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	connURL := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:   net.JoinHostPort(cfg.DBHost, strconv.Itoa(cfg.DBPort)),
+		Path:   cfg.DBName,
+	}
+	slog.InfoContext(ctx, "connecting to Postgres", "url", connURL.Redacted())
+
+	poolConfig, err := pgxpool.ParseConfig(connURL.String())
 	if err != nil {
 		return nil, err
 	}
+	poolConfig.ConnConfig.Tracer = multitracer.New(
+		logging.QueryTracer(slog.Default()),
+		metrics.QueryTracer(),
+		tracing.QueryTracer(),
+	)
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
+
+func ConnectDatabase(cfg *config.Config) (*api.Server, *Storage, error) {
+	ctx := context.Background()
+
+	storage, err := OpenStorage(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verifier, err := auth.NewVerifier(cfg.JWTSecret, cfg.JWKSFile, cfg.JWTIssuer, cfg.JWTAudience)
+	if err != nil {
+		storage.Close()
+		return nil, nil, err
+	}
+
+	// password logins issue HS256 tokens, so they need the shared secret
+	var issuer *auth.Issuer
+	if cfg.JWTSecret != "" {
+		issuer, err = auth.NewIssuer(cfg.JWTSecret, cfg.JWTIssuer, cfg.JWTAudience, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+		if err != nil {
+			storage.Close()
+			return nil, nil, err
+		}
+	}
+	services.ConfigurePasswords(cfg.PasswordPolicy, cfg.PasswordHash)
+	services.ConfigureWebhooks(cfg.WebhookAllowPrivate)
 
-	queries := database.New(pool)
-	server := api.NewServer(queries, pool)
+	server := api.NewServer(storage.Queries, storage.Events, verifier, issuer)
+	server.GraphQLMaxDepth = cfg.GraphQLMaxDepth
+	server.GraphQLMaxComplexity = cfg.GraphQLMaxComplexity
 
-	return server, nil
+	return server, storage, nil
+}
+
+// runMigrate runs the migrate subcommand: migrate up, migrate down [steps] or migrate status.
+// Only the postgres storage has migrations, the others create their schema when opened.
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.Storage != "postgres" {
+		return fmt.Errorf("the %s storage has no migrations", cfg.Storage)
+	}
+
+	ctx := context.Background()
+	pool, err := connectPostgres(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrations.Up(ctx, pool)
+		logMigrations("applied migration", applied)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("down steps must be a positive integer")
+			}
+		}
+		reverted, err := migrations.Down(ctx, pool, steps)
+		logMigrations("reverted migration", reverted)
+		return err
+	case "status":
+		statuses, err := migrations.GetStatus(ctx, pool)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", command)
+	}
+}
+
+// runBootstrapAdmin runs the bootstrap-admin subcommand, granting the admin
+// role to the user with the given email and creating it when missing:
+// bootstrap-admin -email jay@example.com [-first-name Jay -last-name Vas].
+// The password is read from ADMIN_PASSWORD, and left unchanged when unset.
+func runBootstrapAdmin(cfg *config.Config, args []string) error {
+	if cfg.Storage == "memory" {
+		return fmt.Errorf("the memory storage does not keep the admin after the command")
+	}
+
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the admin")
+	firstName := flags.String("first-name", "Admin", "first name of the admin when it is created")
+	lastName := flags.String("last-name", "Admin", "last name of the admin when it is created")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+
+	ctx := context.Background()
+	storage, err := OpenStorage(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+	err = storage.MigrationsApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	services.ConfigurePasswords(cfg.PasswordPolicy, cfg.PasswordHash)
+	admin, err := services.BootstrapAdmin(ctx, dto.User{
+		Firstname: *firstName,
+		Lastname:  *lastName,
+		Email:     *email,
+	}, os.Getenv("ADMIN_PASSWORD"), storage.Queries)
+	if err != nil {
+		return err
+	}
+	slog.Info("granted the admin role", "user_id", admin.Userid, "email", admin.Email)
+	return nil
 }
 
 func fileServer(r chi.Router, path string, root http.FileSystem) {
@@ -1,31 +1,62 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 	"user-manager/api"
+	"user-manager/auth"
 	"user-manager/database"
+	"user-manager/database/migrations"
 	"user-manager/dto"
+	"user-manager/grpcapi"
+	services "user-manager/internal"
+	userv1 "user-manager/proto/user/v1"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 var ts *httptest.Server
 
+// testServer is the api.Server behind ts, for running background jobs in tests.
+var testServer *api.Server
+
+// testPostgresURL is the URL of the test Postgres server, without a database.
+var testPostgresURL string
+
+const testJWTSecret = "user-manager-integration-test-secret"
+
+// testAdminID is the user seeded with the admin role. Tests call the API as this user.
+const testAdminID = "1000"
+
 func TestMain(m *testing.M) {
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 
 	var err error
@@ -34,38 +65,319 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal(err)
 	}
-
+	testServer = server
 	defer cleanup()
+	// the webhook receivers of the tests listen on loopback
+	services.ConfigureWebhooks(true)
 
 	r.Route("/users", server.UserRouter)
+	r.Route("/auth", server.AuthRouter)
+	r.Route("/webhooks", server.WebhookRouter)
+	r.Route("/graphql", server.GraphQLRouter)
 
 	fmt.Println("Test Server is Running")
 	ts = httptest.NewServer(r)
 
+	client := ts.Client()
+	client.Transport = &bearerTransport{token: signToken(testAdminID), base: client.Transport}
+
 	code := m.Run()
 
 	os.Exit(code)
 }
 
+func TestUnauthorized(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/users")
+	if err != nil {
+		log.Fatal("Can not call users endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token. Received %d", resp.StatusCode)
+	}
+
+	expired := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	})
+	token, err := expired.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		log.Fatal("Can not sign token")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/users", nil)
+	if err != nil {
+		log.Fatal("Can not create request")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal("Can not call users endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with an expired token. Received %d", resp.StatusCode)
+	}
+
+	// the user of the token does not exist, as after a purge
+	req.Header.Set("Authorization", "Bearer "+signToken("999999"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal("Can not call users endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a token of a missing user. Received %d", resp.StatusCode)
+	}
+}
+
 func TestUserLifeCycle(t *testing.T) {
 	t.Run("Create", CreateUserTest)
+	t.Run("Create Duplicate Email", CreateDuplicateUserTest)
 	t.Run("Get All", GetUsersTest)
+	t.Run("Search", SearchUsersTest)
+	t.Run("Export", ExportUsersTest)
+	t.Run("Self Access", SelfAccessTest)
+	t.Run("Roles", UserRolesTest)
+	t.Run("Login", LoginTest)
 	t.Run("Get Single", GetUserTest)
 	t.Run("Update", UpdateUserTest)
+	t.Run("Replace", ReplaceUserTest)
 	t.Run("Delete", DeleteUserTest)
+	t.Run("Restore", RestoreUserTest)
+	t.Run("Audit", UserAuditTest)
 }
 
 func GetUsersTest(t *testing.T) {
-	resp, err := ts.Client().Get(ts.URL + "/users")
+	resp, err := ts.Client().Get(ts.URL + "/users?limit=10&sort=firstName&status=Active")
 	if err != nil {
 		log.Fatal("Can not call users endpoint")
 	}
 
-	resp.Body.Close()
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		t.Errorf("Expected 200 for Get Users List. Received %d", resp.StatusCode)
 	}
+
+	var page dto.UserPage
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil {
+		t.Errorf("Can not decode users page: %v", err)
+	}
+
+	if page.Total != 1 || len(page.Users) != 1 || page.NextCursor != "" {
+		t.Errorf("Expected a single page with 1 user. Received %d of %d", len(page.Users), page.Total)
+	}
+}
+
+func SearchUsersTest(t *testing.T) {
+	resp, err := ts.Client().Get(ts.URL + "/users/search?q=jay")
+	if err != nil {
+		log.Fatal("Can not call users search endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for Search Users. Received %d", resp.StatusCode)
+	}
+
+	var results []database.SearchUsersRow
+	err = json.NewDecoder(resp.Body).Decode(&results)
+	if err != nil {
+		t.Errorf("Can not decode search results: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Score <= 0 {
+		t.Errorf("Expected 1 ranked search result. Received %d", len(results))
+	}
+}
+
+func ExportUsersTest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/users/export?columns=email,firstName&name_prefix=jay", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call users export endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Expected 200 with NDJSON for Export Users. Received %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"email":"jay@gmail.com","firstName":"jay"}`+"\n" {
+		t.Errorf("Expected the matching user. Received %s", body)
+	}
+
+	req.Header.Set("Accept", "application/xml")
+	resp, err = ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call users export endpoint")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("Expected 406 for unsupported Accept. Received %d", resp.StatusCode)
+	}
+}
+
+func SelfAccessTest(t *testing.T) {
+	token := signToken("1")
+	expected := map[string]int{
+		"/users":                http.StatusForbidden,
+		"/users/1":              http.StatusOK,
+		"/users/1/roles":        http.StatusOK,
+		"/users/" + testAdminID: http.StatusForbidden,
+	}
+
+	for path, status := range expected {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			log.Fatal("Can not create request")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal("Can not call users endpoint")
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != status {
+			t.Errorf("Expected %d for %s as self. Received %d", status, path, resp.StatusCode)
+		}
+	}
+}
+
+func UserRolesTest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/users/1/roles", bytes.NewBufferString(`{"roles": ["support"]}`))
+	if err != nil {
+		log.Fatal("Can not create roles request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call roles endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for Set User Roles. Received %d", resp.StatusCode)
+	}
+
+	var roles dto.UserRoles
+	err = json.NewDecoder(resp.Body).Decode(&roles)
+	if err != nil {
+		t.Errorf("Can not decode roles: %v", err)
+	}
+
+	if len(roles.Roles) != 1 || roles.Roles[0] != "support" {
+		t.Errorf("Expected support role. Received %v", roles.Roles)
+	}
+}
+
+func LoginTest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/users/1/password", bytes.NewBufferString(`{"new_password": "correct horse battery"}`))
+	if err != nil {
+		log.Fatal("Can not create password request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call password endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204 for Set Password. Received %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/auth/login", "application/json", bytes.NewBufferString(`{"email": "jay@gmail.com", "password": "wrong horse battery"}`))
+	if err != nil {
+		log.Fatal("Can not call login endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong password. Received %d", resp.StatusCode)
+	}
+
+	tokens := postTokens(t, "/auth/login", `{"email": "jay@gmail.com", "password": "correct horse battery"}`)
+	refreshed := postTokens(t, "/auth/refresh", `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+
+	req, err = http.NewRequest(http.MethodPost, ts.URL+"/auth/logout", bytes.NewBufferString(`{"refresh_token": "`+refreshed.RefreshToken+`"}`))
+	if err != nil {
+		log.Fatal("Can not create logout request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal("Can not call logout endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204 for Logout. Received %d", resp.StatusCode)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/users/1", nil)
+	if err != nil {
+		log.Fatal("Can not create request")
+	}
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal("Can not call users endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 after Logout. Received %d", resp.StatusCode)
+	}
+}
+
+func postTokens(t *testing.T, path string, body string) dto.TokenPair {
+	var tokens dto.TokenPair
+
+	resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal("Can not call auth endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for %s. Received %d", path, resp.StatusCode)
+		return tokens
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&tokens)
+	if err != nil {
+		t.Errorf("Can not decode tokens: %v", err)
+	}
+	return tokens
 }
 
 func CreateUserTest(t *testing.T) {
@@ -74,8 +386,8 @@ func CreateUserTest(t *testing.T) {
 		Firstname: "jay",
 		Lastname:  "vas",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       30,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
 		Status:    string(database.UserstatusActive),
 	}
 
@@ -102,6 +414,43 @@ func CreateUserTest(t *testing.T) {
 	}
 }
 
+func CreateDuplicateUserTest(t *testing.T) {
+	user := dto.User{
+		Firstname: "jay",
+		Lastname:  "vas",
+		Email:     "JAY@gmail.com",
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(30)),
+		Status:    string(database.UserstatusActive),
+	}
+
+	jsonData, err := json.Marshal(user)
+	if err != nil {
+		log.Fatal("Can not create request by parsing json")
+	}
+
+	resp, err := ts.Client().Post(ts.URL+"/users", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Fatal("Can not call create user endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 for Duplicate Email. Received %d", resp.StatusCode)
+	}
+
+	var problem dto.Problem
+	err = json.NewDecoder(resp.Body).Decode(&problem)
+	if err != nil {
+		t.Errorf("Can not decode problem response: %v", err)
+	}
+
+	if problem.Code != "duplicate_field" || len(problem.Errors) != 1 || problem.Errors[0].Field != "email" {
+		t.Errorf("Expected duplicate_field problem on email. Received %+v", problem)
+	}
+}
+
 func GetUserTest(t *testing.T) {
 	// test /users/id GET endpoint
 	resp, err := ts.Client().Get(ts.URL + "/users/1")
@@ -114,6 +463,23 @@ func GetUserTest(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Errorf("Expected 200 for Get a User. Received %d", resp.StatusCode)
 	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/users/1", nil)
+	if err != nil {
+		log.Fatal("Can not create request")
+	}
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+
+	resp, err = ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call users/id endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected 304 for an unchanged User. Received %d", resp.StatusCode)
+	}
 }
 
 func UpdateUserTest(t *testing.T) {
@@ -122,8 +488,8 @@ func UpdateUserTest(t *testing.T) {
 		Firstname: "jay",
 		Lastname:  "vas",
 		Email:     "jay@gmail.com",
-		Phone:     "+0722134567",
-		Age:       35,
+		Phone:     ptr("+0722134567"),
+		Age:       ptr(int32(35)),
 		Status:    string(database.UserstatusActive),
 	}
 
@@ -132,12 +498,33 @@ func UpdateUserTest(t *testing.T) {
 		log.Fatal("Can not update request by parsing json")
 	}
 
+	// writes must name the version they are based on
+	status := patchUser(jsonData, "")
+	if status != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 for Update User without If-Match. Received %d", status)
+	}
+
+	status = patchUser(jsonData, `"999"`)
+	if status != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for Update User with a stale ETag. Received %d", status)
+	}
+
+	status = patchUser(jsonData, userETag())
+	if status != http.StatusOK {
+		t.Errorf("Expected 200 for Update User. Received %d", status)
+	}
+}
+
+func patchUser(jsonData []byte, ifMatch string) int {
 	req, err := http.NewRequest(http.MethodPatch, ts.URL+"/users/1", bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Fatal("Can not create update request")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
 	resp, err := ts.Client().Do(req)
 	if err != nil {
 		log.Fatal("Can not call update user endpoint")
@@ -145,8 +532,61 @@ func UpdateUserTest(t *testing.T) {
 
 	resp.Body.Close()
 
+	return resp.StatusCode
+}
+
+// userETag returns the current ETag of user 1.
+func userETag() string {
+	resp, err := ts.Client().Get(ts.URL + "/users/1")
+	if err != nil {
+		log.Fatal("Can not call users/id endpoint")
+	}
+
+	resp.Body.Close()
+
+	return resp.Header.Get("ETag")
+}
+
+func ReplaceUserTest(t *testing.T) {
+	// phone and age are left out, so a full replacement clears them
+	user := dto.User{
+		Firstname: "jay",
+		Lastname:  "vasquez",
+		Email:     "jay@gmail.com",
+		Status:    string(database.UserstatusInactive),
+	}
+
+	jsonData, err := json.Marshal(user)
+	if err != nil {
+		log.Fatal("Can not replace request by parsing json")
+	}
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/users/1", bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Fatal("Can not create replace request")
+	}
+	req.Header.Set("If-Match", userETag())
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call replace user endpoint")
+	}
+
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		t.Errorf("Expected 200 for Update User. Received %d", resp.StatusCode)
+		t.Errorf("Expected 200 for Replace User. Received %d", resp.StatusCode)
+	}
+
+	var dbUser database.User
+	err = json.NewDecoder(resp.Body).Decode(&dbUser)
+	if err != nil {
+		t.Errorf("Can not decode replaced user: %v", err)
+	}
+
+	if dbUser.Lastname != "vasquez" || dbUser.Phone.Valid || dbUser.Age.Valid {
+		t.Errorf("Expected replaced user without phone and age. Received %+v", dbUser)
 	}
 }
 
@@ -155,6 +595,7 @@ func DeleteUserTest(t *testing.T) {
 	if err != nil {
 		log.Fatal("Can not create delete request")
 	}
+	req.Header.Set("If-Match", userETag())
 
 	resp, err := ts.Client().Do(req)
 	if err != nil {
@@ -165,6 +606,510 @@ func DeleteUserTest(t *testing.T) {
 		t.Errorf("Expected 200 for Delete a User. Received %d", resp.StatusCode)
 	}
 
+	resp, err = ts.Client().Get(ts.URL + "/users/1")
+	if err != nil {
+		log.Fatal("Can not call users/id endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a Deleted User. Received %d", resp.StatusCode)
+	}
+
+	resp, err = ts.Client().Get(ts.URL + "/users/1?include_deleted=true")
+	if err != nil {
+		log.Fatal("Can not call users/id endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for a Deleted User with include_deleted. Received %d", resp.StatusCode)
+	}
+}
+
+func UserAuditTest(t *testing.T) {
+	resp, err := ts.Client().Get(ts.URL + "/users/1/audit?limit=2")
+	if err != nil {
+		log.Fatal("Can not call audit endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for Get User Audit. Received %d", resp.StatusCode)
+	}
+
+	var page dto.AuditPage
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil {
+		t.Errorf("Can not decode audit page: %v", err)
+	}
+
+	// newest first: the restore, then the delete
+	if len(page.Events) != 2 || page.Events[0].Action != "restore" || page.Events[1].Action != "delete" || page.NextCursor == "" {
+		t.Errorf("Expected restore and delete events. Received %+v", page.Events)
+	}
+	if page.Events[0].ActorID == nil || fmt.Sprint(*page.Events[0].ActorID) != testAdminID || page.Events[0].RequestID == "" {
+		t.Errorf("Expected the admin as actor with a request id. Received %+v", page.Events[0])
+	}
+}
+
+func RestoreUserTest(t *testing.T) {
+	resp, err := ts.Client().Post(ts.URL+"/users/1/restore", "application/json", nil)
+	if err != nil {
+		log.Fatal("Can not call restore user endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for Restore a User. Received %d", resp.StatusCode)
+	}
+
+	resp, err = ts.Client().Get(ts.URL + "/users/1")
+	if err != nil {
+		log.Fatal("Can not call users/id endpoint")
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for a Restored User. Received %d", resp.StatusCode)
+	}
+}
+
+// TestMigrationsBaseline migrates databases created from the schema.sql file
+// of earlier releases, which the migrations replaced.
+func TestMigrationsBaseline(t *testing.T) {
+	tests := []struct {
+		schema    string
+		baselined int
+	}{
+		{"database/migrations/testdata/schema_v1.sql", 1},
+		{"database/migrations/testdata/schema_v10.sql", 6},
+	}
+	ctx := t.Context()
+	admin, err := pgxpool.New(ctx, testPostgresURL+"/usermanager_testdb?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	for i, test := range tests {
+		name := fmt.Sprintf("legacy_testdb_%d", i)
+		_, err := admin.Exec(ctx, "CREATE DATABASE "+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool, err := pgxpool.New(ctx, testPostgresURL+"/"+name+"?sslmode=disable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pool.Close()
+
+		schema, err := os.ReadFile(test.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = pool.Exec(ctx, string(schema)+`;
+			INSERT INTO users (firstName, lastName, email, user_status) VALUES ('Old', 'User', 'old@example.com', 'Active')`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		applied, err := migrations.Up(ctx, pool)
+		if err != nil {
+			t.Fatalf("Test Failure! Could not migrate %s: %v", test.schema, err)
+		}
+		all, _ := migrations.Load()
+		if len(applied) != len(all)-test.baselined || applied[0].Version != int64(test.baselined+1) {
+			t.Errorf("Test Failure! Expected %d migrations baselined for %s, applied %+v", test.baselined, test.schema, applied)
+		}
+		pending, err := migrations.Pending(ctx, pool)
+		if err != nil || len(pending) != 0 {
+			t.Errorf("Test Failure! Expected no pending migration, got %+v (%v)", pending, err)
+		}
+
+		var count int
+		err = pool.QueryRow(ctx, "SELECT count(*) FROM users WHERE email = 'old@example.com'").Scan(&count)
+		if err != nil || count != 1 {
+			t.Errorf("Test Failure! Expected the existing user kept, got %d (%v)", count, err)
+		}
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	body := "firstName,lastName,email,age\nImran,Khan,imran@gmail.com,35\nNo,Email,,\nMaya,Silva,maya@gmail.com,\n"
+
+	report, status := postImport(t, "?atomic=true", body)
+	if status != http.StatusUnprocessableEntity || report.Committed || report.Rejected != 1 {
+		t.Errorf("Expected atomic import to be rejected. Received %d %+v", status, report)
+	}
+
+	report, status = postImport(t, "?dry_run=true", body)
+	if status != http.StatusOK || report.Committed || report.Accepted != 2 {
+		t.Errorf("Expected dry run to accept 2 rows. Received %d %+v", status, report)
+	}
+
+	report, status = postImport(t, "", body)
+	if status != http.StatusOK || !report.Committed || report.Accepted != 2 || report.Rows[0].UserID == 0 {
+		t.Errorf("Expected import to store 2 users. Received %d %+v", status, report)
+	}
+
+	// both emails are now taken
+	report, _ = postImport(t, "", body)
+	if report.Accepted != 0 || report.Rows[0].Errors[0].Code != "already_exists" {
+		t.Errorf("Expected existing emails to be rejected. Received %+v", report)
+	}
+}
+
+func postImport(t *testing.T, query string, body string) (dto.ImportReport, int) {
+	resp, err := ts.Client().Post(ts.URL+"/users/import"+query, "text/csv", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal("Can not call import endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	var report dto.ImportReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		t.Errorf("Can not decode import report: %v", err)
+	}
+	return report, resp.StatusCode
+}
+
+func TestBatchUsers(t *testing.T) {
+	body := `{"atomic": true, "operations": [
+		{"op": "create", "user": {"firstName": "Nuwan", "lastName": "Perera", "email": "nuwan@gmail.com"}},
+		{"op": "delete", "id": 999999}
+	]}`
+	report, status := postBatch(t, body)
+	if status != http.StatusUnprocessableEntity || report.Committed || report.Results[0].Status != http.StatusFailedDependency || report.Results[1].Status != http.StatusNotFound {
+		t.Errorf("Expected atomic batch to be rolled back. Received %d %+v", status, report)
+	}
+
+	body = `{"operations": [
+		{"op": "create", "user": {"firstName": "Nuwan", "lastName": "Perera", "email": "nuwan@gmail.com"}},
+		{"op": "create", "user": {"firstName": "Nuwan", "lastName": "Perera", "email": "nuwan@gmail.com"}},
+		{"op": "delete", "id": 999999}
+	]}`
+	report, status = postBatch(t, body)
+	if status != http.StatusOK || !report.Committed || report.Succeeded != 1 || report.Results[1].Status != http.StatusConflict {
+		t.Errorf("Expected best effort batch to keep the first create. Received %d %+v", status, report)
+	}
+
+	body = fmt.Sprintf(`{"operations": [{"op": "patch", "id": %d, "version": 1, "user": {"age": 40}}]}`, report.Results[0].User.Userid)
+	report, _ = postBatch(t, body)
+	if report.Succeeded != 1 || report.Results[0].User.Age.Int32 != 40 {
+		t.Errorf("Expected the patch to succeed. Received %+v", report)
+	}
+}
+
+func postBatch(t *testing.T, body string) (dto.BatchReport, int) {
+	resp, err := ts.Client().Post(ts.URL+"/users/batch", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatal("Can not call batch endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	var report dto.BatchReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		t.Errorf("Can not decode batch report: %v", err)
+	}
+	return report, resp.StatusCode
+}
+
+func TestWebhooks(t *testing.T) {
+	received := make(chan dto.WebhookEvent, 10)
+	var secret string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Webhook-Signature") != services.SignWebhook(secret, r.Header.Get("Webhook-Timestamp"), body) {
+			t.Errorf("Expected a valid webhook signature")
+		}
+		var event dto.WebhookEvent
+		json.Unmarshal(body, &event)
+		received <- event
+	}))
+	defer receiver.Close()
+
+	resp, err := ts.Client().Post(ts.URL+"/webhooks", "application/json",
+		bytes.NewBufferString(fmt.Sprintf(`{"url": %q, "events": ["user.created"]}`, receiver.URL)))
+	if err != nil {
+		log.Fatal("Can not call webhooks endpoint")
+	}
+	var webhook dto.Webhook
+	json.NewDecoder(resp.Body).Decode(&webhook)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || webhook.Secret == "" {
+		t.Fatalf("Expected 201 with a secret for Create Webhook. Received %d", resp.StatusCode)
+	}
+	secret = webhook.Secret
+
+	resp, err = ts.Client().Post(ts.URL+"/users", "application/json",
+		bytes.NewBufferString(`{"firstName": "Hook", "lastName": "Test", "email": "hook@gmail.com"}`))
+	if err != nil {
+		log.Fatal("Can not call users endpoint")
+	}
+	resp.Body.Close()
+
+	_, err = services.NewWebhookDispatcher(time.Second, 3).Dispatch(context.Background(), testServer.Queries)
+	if err != nil {
+		t.Fatalf("Can not dispatch webhooks: %v", err)
+	}
+	select {
+	case event := <-received:
+		if event.Type != "user.created" || !bytes.Contains(event.Data, []byte("hook@gmail.com")) {
+			t.Errorf("Expected the user.created event. Received %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the webhook to be called")
+	}
+
+	resp, err = ts.Client().Get(fmt.Sprintf("%s/webhooks/%d/deliveries", ts.URL, webhook.ID))
+	if err != nil {
+		log.Fatal("Can not call webhook deliveries endpoint")
+	}
+	var page dto.WebhookDeliveryPage
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if len(page.Deliveries) != 1 || page.Deliveries[0].Status != "delivered" || page.Deliveries[0].Attempts != 1 {
+		t.Errorf("Expected one delivered delivery. Received %+v", page.Deliveries)
+	}
+}
+
+func TestUserEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream := openEventStream(t, ctx, "")
+	resp, err := ts.Client().Post(ts.URL+"/users", "application/json",
+		bytes.NewBufferString(`{"firstName": "Stream", "lastName": "Test", "email": "stream@gmail.com"}`))
+	if err != nil {
+		log.Fatal("Can not call users endpoint")
+	}
+	resp.Body.Close()
+
+	id, event, data := readEvent(t, stream)
+	if event != "user.created" || !strings.Contains(data, "stream@gmail.com") {
+		t.Errorf("Expected the user.created event. Received %s %s", event, data)
+	}
+
+	// resuming before the event sends it again
+	stream = openEventStream(t, ctx, fmt.Sprint(id-1))
+	resumedID, _, _ := readEvent(t, stream)
+	if resumedID != id {
+		t.Errorf("Expected event %d after resuming. Received %d", id, resumedID)
+	}
+}
+
+func openEventStream(t *testing.T, ctx context.Context, lastEventID string) *bufio.Reader {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/users/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		log.Fatal("Can not call users events endpoint")
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected 200 with an event stream. Received %d", resp.StatusCode)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent returns the id, type and data of the next event of an SSE stream.
+func readEvent(t *testing.T, stream *bufio.Reader) (int64, string, string) {
+	var id int64
+	var event, data string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Can not read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return id, event, data
+		}
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	result, status := postGraphQL(t, `mutation($input: CreateUserInput!) { createUser(input: $input) { userId version age } }`,
+		map[string]any{"input": map[string]any{"firstName": "Graph", "lastName": "Test", "email": "graph@gmail.com", "age": 28}})
+	if status != http.StatusOK || len(result.Errors) != 0 {
+		t.Fatalf("Expected the user to be created. Received %d %+v", status, result.Errors)
+	}
+	created := result.Data["createUser"].(map[string]any)
+	id, version := created["userId"].(float64), created["version"].(float64)
+
+	result, _ = postGraphQL(t, fmt.Sprintf(`mutation { updateUser(id: %d, version: %d, input: {lastName: "QL"}, clear: [age]) { lastName age } }`, int(id), int(version)), nil)
+	updated, _ := result.Data["updateUser"].(map[string]any)
+	if len(result.Errors) != 0 || updated["lastName"] != "QL" || updated["age"] != nil {
+		t.Errorf("Expected lastName to be set and age cleared. Received %+v %+v", updated, result.Errors)
+	}
+
+	result, _ = postGraphQL(t, `{ users(emailPrefix: "graph", limit: 5) { total users { email } } }`, nil)
+	page, _ := result.Data["users"].(map[string]any)
+	if len(result.Errors) != 0 || page["total"] != float64(1) {
+		t.Errorf("Expected one user with the graph email prefix. Received %+v %+v", page, result.Errors)
+	}
+
+	result, _ = postGraphQL(t, fmt.Sprintf(`mutation { deleteUser(id: %d, version: %d) }`, int(id), int(version)), nil)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "version_mismatch" {
+		t.Errorf("Expected version_mismatch for a stale version. Received %+v", result.Errors)
+	}
+
+	_, status = postGraphQL(t, `{ users(limit: 100) { users { userId firstName lastName email phone age status deletedAt version } } }`+
+		` query Other { users { total } }`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without operationName for a document with two operations. Received %d", status)
+	}
+
+	testServer.GraphQLMaxComplexity = 50
+	defer func() { testServer.GraphQLMaxComplexity = api.DefaultGraphQLMaxComplexity }()
+	result, status = postGraphQL(t, `{ users(limit: 100) { users { userId email } } }`, nil)
+	if status != http.StatusBadRequest || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "complexity") {
+		t.Errorf("Expected 400 for a query over the complexity limit. Received %d %+v", status, result.Errors)
+	}
+	result, status = postGraphQL(t, `query($n: Int = 100) { users(limit: $n) { users { userId email } } }`, nil)
+	if status != http.StatusBadRequest || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "complexity") {
+		t.Errorf("Expected 400 for a limit over the complexity limit given as a variable default. Received %d %+v", status, result.Errors)
+	}
+}
+
+type graphqlResult struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, query string, variables map[string]any) (graphqlResult, int) {
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+	resp, err := ts.Client().Post(ts.URL+"/graphql", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		log.Fatal("Can not call graphql endpoint")
+	}
+
+	defer resp.Body.Close()
+
+	var result graphqlResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Errorf("Can not decode GraphQL result: %v", err)
+	}
+	return result, resp.StatusCode
+}
+
+func TestGRPCUserService(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpcapi.NewServer(testServer.Queries, testServer.Verifier).GRPCServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Can not dial gRPC server: %v", err)
+	}
+	defer conn.Close()
+	client := userv1.NewUserServiceClient(conn)
+
+	_, err = client.GetUser(t.Context(), &userv1.GetUserRequest{Id: 1})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token. Received %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer "+signToken(testAdminID))
+	user, err := client.CreateUser(ctx, &userv1.CreateUserRequest{User: &userv1.UserInput{
+		FirstName: "Grpc", LastName: "Test", Email: "grpc@gmail.com", Age: ptr(int32(30)), Status: "Active",
+	}})
+	if err != nil || user.GetStatus() != "Active" {
+		t.Fatalf("Expected the user to be created. Received %v %v", user, err)
+	}
+
+	_, err = client.CreateUser(ctx, &userv1.CreateUserRequest{User: &userv1.UserInput{FirstName: "Grpc", LastName: "Test", Email: "grpc@gmail.com"}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists for a duplicate email. Received %v", err)
+	}
+
+	updated, err := client.UpdateUser(ctx, &userv1.UpdateUserRequest{
+		Id: user.GetId(), Version: user.GetVersion(),
+		User:       &userv1.UserInput{LastName: "Updated"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"last_name", "age"}},
+	})
+	if err != nil || updated.GetLastName() != "Updated" || updated.Age != nil || updated.GetFirstName() != "Grpc" {
+		t.Errorf("Expected the masked fields to be updated. Received %v %v", updated, err)
+	}
+
+	_, err = client.DeleteUser(ctx, &userv1.DeleteUserRequest{Id: user.GetId(), Version: user.GetVersion()})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for a stale version. Received %v", err)
+	}
+
+	stream, err := client.ListUsers(ctx, &userv1.ListUsersRequest{EmailPrefix: "grpc"})
+	if err != nil {
+		t.Fatalf("Can not list users: %v", err)
+	}
+	var listed []*userv1.User
+	for {
+		u, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Can not receive users: %v", err)
+		}
+		listed = append(listed, u)
+	}
+	if len(listed) != 1 || listed[0].GetId() != user.GetId() {
+		t.Errorf("Expected the created user to be listed. Received %v", listed)
+	}
+}
+
+func signToken(subject string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		log.Fatal("Can not sign token", err)
+	}
+	return signed
+}
+
+// bearerTransport adds a bearer token to every request of the test client.
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+func ptr[T any](v T) *T {
+	return &v
 }
 
 func connectDatabase() (*api.Server, func(), error) {
@@ -191,28 +1136,55 @@ func connectDatabase() (*api.Server, func(), error) {
 	host, _ := container.Host(ctx)
 	port, _ := container.MappedPort(ctx, "5432")
 
-	dsn := fmt.Sprintf("postgres://postgres:password@%s:%s/usermanager_testdb?sslmode=disable", host, port.Port())
-	pool, err := pgxpool.New(ctx, dsn)
+	testPostgresURL = fmt.Sprintf("postgres://postgres:password@%s:%s", host, port.Port())
+	pool, err := pgxpool.New(ctx, testPostgresURL+"/usermanager_testdb?sslmode=disable")
 
 	if err != nil {
 		log.Fatal("Could not connect to test DB")
 	}
 	fmt.Println("connected to test db")
 
+	verifier, err := auth.NewVerifier(testJWTSecret, "", "", "")
+	if err != nil {
+		log.Fatal("Could not create token verifier", err)
+	}
+
+	issuer, err := auth.NewIssuer(testJWTSecret, "", "", 15*time.Minute, time.Hour)
+	if err != nil {
+		log.Fatal("Could not create token issuer", err)
+	}
+
 	queries := database.New(pool)
-	server := api.NewServer(queries, pool)
+	listener := database.NewListener(pool, database.UserEventsChannel)
+	server := api.NewServer(queries, listener, verifier, issuer)
+	listenerCtx, stopListener := context.WithCancel(ctx)
+	go listener.Run(listenerCtx)
 
-	schema, err := os.ReadFile("./test_schema.sql")
+	// apply, revert and re-apply every migration so the down migrations are exercised too
+	applied, err := migrations.Up(ctx, pool)
+	if err != nil {
+		log.Fatal("Could not apply migrations", err)
+	}
+	_, err = migrations.Down(ctx, pool, len(applied))
+	if err != nil {
+		log.Fatal("Could not revert migrations", err)
+	}
+	_, err = migrations.Up(ctx, pool)
 	if err != nil {
-		log.Fatal("Could not read the Schema file", err)
+		log.Fatal("Could not apply migrations", err)
 	}
 
-	_, err = pool.Exec(ctx, string(schema))
+	// the admin calling the API in the tests. It is inactive so it does not show up in the filtered lists
+	_, err = pool.Exec(ctx, `
+		INSERT INTO users (userId, firstName, lastName, email, user_status)
+		VALUES (`+testAdminID+`, 'Admin', 'Root', 'admin@example.com', 'Inactive');
+		INSERT INTO user_roles (userId, role) VALUES (`+testAdminID+`, 'admin');`)
 	if err != nil {
-		log.Fatal("Could not execute DB Schema", err)
+		log.Fatal("Could not seed the admin user", err)
 	}
 
 	cleanup := func() {
+		stopListener()
 		pool.Close()
 		err := container.Terminate(ctx)
 		if err != nil {
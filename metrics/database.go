@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"user-manager/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "db_query_duration_seconds",
+	Help:      "Latency of the Postgres queries, by sqlc query name.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"query"})
+
+// QueryTracer observes the latency of the Postgres queries, labelled with
+// database.QueryName.
+func QueryTracer() pgx.QueryTracer {
+	return queryTracer{}
+}
+
+type queryTracer struct{}
+
+type queryStart struct {
+	name string
+	at   time.Time
+}
+
+type queryStartKey struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{name: database.QueryName(data.SQL), at: time.Now()})
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if ok {
+		queryDuration.WithLabelValues(start.name).Observe(time.Since(start.at).Seconds())
+	}
+}
+
+var (
+	poolAcquiredConns = prometheus.NewDesc(namespace+"_db_pool_acquired_connections",
+		"Connections of the pool currently in use.", nil, nil)
+	poolIdleConns = prometheus.NewDesc(namespace+"_db_pool_idle_connections",
+		"Idle connections of the pool.", nil, nil)
+	poolTotalConns = prometheus.NewDesc(namespace+"_db_pool_total_connections",
+		"Connections of the pool, constructing, idle or in use.", nil, nil)
+	poolMaxConns = prometheus.NewDesc(namespace+"_db_pool_max_connections",
+		"Maximum size of the pool.", nil, nil)
+	poolAcquires = prometheus.NewDesc(namespace+"_db_pool_acquires_total",
+		"Connections acquired from the pool.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total",
+		"Acquires that waited for a connection as the pool was empty.", nil, nil)
+	poolAcquireWait = prometheus.NewDesc(namespace+"_db_pool_acquire_wait_seconds_total",
+		"Time spent waiting for a connection as the pool was empty.", nil, nil)
+)
+
+// PoolCollector reports the statistics of a pgxpool.Pool.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	return &PoolCollector{pool: pool}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, stat.EmptyAcquireWaitTime().Seconds())
+}
+
+var usersByStatus = prometheus.NewDesc(namespace+"_users",
+	"Users that are not deleted, by status.", []string{"status"}, nil)
+
+// usersTimeout bounds the queries of a scrape.
+const usersTimeout = 5 * time.Second
+
+// UserCollector counts the users by status when scraped, on any storage backend.
+type UserCollector struct {
+	queries database.Querier
+}
+
+func NewUserCollector(queries database.Querier) *UserCollector {
+	return &UserCollector{queries: queries}
+}
+
+func (c *UserCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- usersByStatus
+}
+
+func (c *UserCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), usersTimeout)
+	defer cancel()
+
+	for _, status := range []database.Userstatus{database.UserstatusActive, database.UserstatusInactive} {
+		count, err := c.queries.CountUsers(ctx, database.ListUsersFilter{
+			Status: database.NullUserstatus{Userstatus: status, Valid: true},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error on counting users for metrics", "error", err)
+			ch <- prometheus.NewInvalidMetric(usersByStatus, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(usersByStatus, prometheus.GaugeValue, float64(count), string(status))
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-manager/database"
+	"user-manager/database/memory"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequestsRouteLabel(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Requests)
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if count := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/users/{id}", "404")); count != 2 {
+		t.Errorf("Test Failure! Expected 2 requests for the route, got %v", count)
+	}
+	if count := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")); count != 1 {
+		t.Errorf("Test Failure! Expected 1 unmatched request, got %v", count)
+	}
+}
+
+func TestUserCollector(t *testing.T) {
+	store := memory.New()
+	for i, status := range []database.NullUserstatus{
+		{Userstatus: database.UserstatusActive, Valid: true},
+		{Userstatus: database.UserstatusActive, Valid: true},
+		{Userstatus: database.UserstatusInactive, Valid: true},
+	} {
+		_, err := store.CreateUser(t.Context(), database.CreateUserParams{
+			Firstname:  "Jane",
+			Lastname:   "Doe",
+			Email:      string(rune('a'+i)) + "@example.com",
+			UserStatus: status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `
+# HELP usermanager_users Users that are not deleted, by status.
+# TYPE usermanager_users gauge
+usermanager_users{status="Active"} 2
+usermanager_users{status="Inactive"} 1
+`
+	err := testutil.CollectAndCompare(NewUserCollector(store), strings.NewReader(expected))
+	if err != nil {
+		t.Errorf("Test Failure! %v", err)
+	}
+}
@@ -1,9 +1,10 @@
 -- name: GetUser :one
 SELECT * FROM users
-WHERE userId = $1 LIMIT 1;
+WHERE userId = $1 AND (deleted_at IS NULL OR @include_deleted::bool) LIMIT 1;
 
 -- name: ListUsers :many
 SELECT * FROM users
+WHERE deleted_at IS NULL
 ORDER BY firstName;
 
 -- name: CreateUser :one
@@ -14,7 +15,7 @@ INSERT INTO users (
 )
 RETURNING *;
 
--- name: UpdateUser :exec
+-- name: UpdateUser :one
 UPDATE users
   set 
   firstName = $2,
@@ -22,9 +23,258 @@ UPDATE users
   email = $4,
   phone = $5,
   age = $6,
-  user_status = $7
-WHERE userId = $1;
+  user_status = $7,
+  version = version + 1
+WHERE userId = $1 AND version = $8 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteUser :execrows
+UPDATE users
+  set deleted_at = now(), version = version + 1
+WHERE userId = $1 AND version = $2 AND deleted_at IS NULL;
 
--- name: DeleteUser :exec
+-- name: RestoreUser :one
+UPDATE users
+  set deleted_at = NULL, version = version + 1
+WHERE userId = $1 AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: PurgeDeletedUsers :many
 DELETE FROM users
-WHERE userId = $1;
\ No newline at end of file
+WHERE deleted_at < @deleted_before
+RETURNING *;
+
+-- name: SearchUsers :many
+SELECT users.*, (
+  ts_rank(
+    to_tsvector('simple', firstName || ' ' || lastName || ' ' || email),
+    plainto_tsquery('simple', @query::text)
+  ) + word_similarity(@query::text, firstName || ' ' || lastName || ' ' || email)
+)::real AS score
+FROM users
+WHERE deleted_at IS NULL
+  AND (
+    to_tsvector('simple', firstName || ' ' || lastName || ' ' || email) @@ plainto_tsquery('simple', @query::text)
+    OR @query::text <% (firstName || ' ' || lastName || ' ' || email)
+  )
+ORDER BY score DESC, userId
+LIMIT @max_results;
+
+-- name: ListUserRoles :many
+SELECT user_roles.role FROM user_roles
+JOIN users ON users.userId = user_roles.userId
+WHERE user_roles.userId = $1 AND users.deleted_at IS NULL
+ORDER BY user_roles.role;
+
+-- name: SetUserRoles :exec
+WITH removed AS (
+  DELETE FROM user_roles
+  WHERE user_roles.userId = @userid AND NOT (user_roles.role::text = ANY(@roles::text[]))
+)
+INSERT INTO user_roles (userId, role)
+SELECT @userid, unnest(@roles::text[])::userRole
+ON CONFLICT DO NOTHING;
+
+-- name: GetCredentialByEmail :one
+SELECT users.userId, users.user_status, user_credentials.password_hash
+FROM users
+JOIN user_credentials ON user_credentials.userId = users.userId
+WHERE lower(users.email) = lower(@email::text) AND users.deleted_at IS NULL;
+
+-- name: GetCredential :one
+SELECT user_credentials.* FROM user_credentials
+JOIN users ON users.userId = user_credentials.userId
+WHERE user_credentials.userId = $1 AND users.deleted_at IS NULL;
+
+-- name: UpsertCredential :exec
+INSERT INTO user_credentials (userId, password_hash)
+VALUES ($1, $2)
+ON CONFLICT (userId) DO UPDATE
+  SET password_hash = EXCLUDED.password_hash, updated_at = now();
+
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (token_hash, userId, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: RevokeRefreshToken :one
+UPDATE refresh_tokens
+  set revoked_at = now()
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > now()
+RETURNING userId;
+
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+  set revoked_at = now()
+WHERE userId = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_tokens (jti, expires_at)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+  SELECT 1 FROM revoked_tokens WHERE jti = $1
+);
+
+-- name: DeleteExpiredTokens :one
+WITH expired_refresh AS (
+  DELETE FROM refresh_tokens WHERE refresh_tokens.expires_at < now()
+  RETURNING 1
+), expired_revoked AS (
+  DELETE FROM revoked_tokens WHERE revoked_tokens.expires_at < now()
+  RETURNING 1
+)
+SELECT ((SELECT count(*) FROM expired_refresh) + (SELECT count(*) FROM expired_revoked))::bigint AS deleted;
+
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+  userId, action, actor_id, request_id, before, after, changes
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE userId = @userid AND (@before_id::bigint = 0 OR id < @before_id)
+ORDER BY id DESC
+LIMIT @max_results;
+
+-- name: ImportUsers :copyfrom
+INSERT INTO users (
+  firstName, lastName, email, phone, age, user_status
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+
+-- name: ListUsersByEmails :many
+SELECT * FROM users
+WHERE lower(email) = ANY(@emails::text[]) AND deleted_at IS NULL;
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (
+  url, secret, events, active
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+ORDER BY id;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+  set url = $2,
+  events = $3,
+  active = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1;
+
+-- name: CreateOutboxEvent :exec
+INSERT INTO webhook_outbox (
+  event_type, userId, payload
+) VALUES (
+  $1, $2, $3
+);
+
+-- name: FanOutWebhookEvents :execrows
+WITH events AS (
+  UPDATE webhook_outbox
+    set dispatched_at = now()
+  WHERE id IN (
+    SELECT id FROM webhook_outbox
+    WHERE dispatched_at IS NULL
+    ORDER BY id
+    LIMIT @max_events
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING id, event_type, created_at
+)
+INSERT INTO webhook_deliveries (webhook_id, event_id)
+SELECT w.id, e.id FROM events e
+JOIN webhooks w ON w.active AND e.event_type = ANY(w.events) AND w.created_at <= e.created_at
+ON CONFLICT DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+  UPDATE webhook_deliveries
+    set attempts = attempts + 1,
+    next_attempt_at = now() + make_interval(secs => @lease_seconds::float8)
+  WHERE webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT @max_results
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING webhook_deliveries.id, webhook_deliveries.webhook_id, webhook_deliveries.event_id, webhook_deliveries.attempts
+)
+SELECT c.id, c.attempts, w.url, w.secret, o.id AS event_id, o.event_type, o.payload, o.created_at AS event_created_at
+FROM claimed c
+JOIN webhooks w ON w.id = c.webhook_id
+JOIN webhook_outbox o ON o.id = c.event_id;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+  set status = @status,
+  next_attempt_at = @next_attempt_at,
+  last_status_code = @last_status_code,
+  last_error = @last_error,
+  delivered_at = CASE WHEN @status = 'delivered'::deliveryStatus THEN now() END
+WHERE id = @id;
+
+-- name: ListWebhookDeliveries :many
+SELECT d.*, o.event_type FROM webhook_deliveries d
+JOIN webhook_outbox o ON o.id = d.event_id
+WHERE d.webhook_id = @webhook_id AND (@before_id::bigint = 0 OR d.id < @before_id)
+ORDER BY d.id DESC
+LIMIT @max_results;
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+  set status = 'pending',
+  attempts = 0,
+  next_attempt_at = now()
+WHERE id = $1 AND webhook_id = $2 AND status = 'dead'
+RETURNING *;
+
+-- name: DeleteFinishedWebhookEvents :execrows
+-- Removes the events dispatched before the cutoff that have no
+-- pending delivery left. Their deliveries are removed with them.
+DELETE FROM webhook_outbox o
+WHERE o.dispatched_at < @dispatched_before
+  AND NOT EXISTS (
+    SELECT 1 FROM webhook_deliveries d
+    WHERE d.event_id = o.id AND d.status = 'pending'
+  );
+
+-- name: ListUserEvents :many
+-- The event id of a stream is seq, numbered in commit order. Events of the
+-- transactions still committing have no seq yet.
+SELECT seq::bigint AS seq, userId, action, changes, created_at FROM audit_events
+WHERE seq > @after_id::bigint
+ORDER BY seq
+LIMIT @max_results;
+
+-- name: LatestUserEventID :one
+SELECT COALESCE(max(seq), 0)::bigint AS id FROM audit_events;
+
+-- name: TakeRateLimitToken :one
+SELECT remaining::float8 AS remaining, allowed::boolean AS allowed
+FROM take_rate_limit_token(@bucket_key::text, @burst::float8, @rate::float8);
+
+-- name: DeleteFullRateLimits :execrows
+DELETE FROM rate_limits WHERE full_at < now();
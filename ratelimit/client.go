@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"user-manager/auth"
+)
+
+// Clients identifies the client of a request: the subject of a valid bearer
+// token, or else the client IP.
+//
+// Clients are not keyed by API key, as the service has no API keys: a key
+// taken from a header that is not authenticated would give a client fresh
+// buckets whenever it changes the header.
+type Clients struct {
+	// Verifier checks the bearer tokens, nil to key every client by IP. Share
+	// the verifier of the API: the verified claims are passed on to the
+	// authentication, which does not verify the token again.
+	Verifier *auth.Verifier
+	// TrustedProxies are the proxies whose X-Forwarded-For header is trusted.
+	TrustedProxies []netip.Prefix
+}
+
+// Key returns the bucket key of the client of r, and r carrying the claims
+// of its bearer token once verified. Invalid tokens are keyed by IP, so that
+// forging tokens does not get a client fresh buckets.
+func (c *Clients) Key(r *http.Request) (string, *http.Request) {
+	if c.Verifier != nil {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") && token != "" {
+			claims, err := c.Verifier.Verify(token)
+			if err == nil && claims.Subject != "" {
+				return "sub:" + claims.Subject, r.WithContext(auth.NewVerifiedContext(r.Context(), claims))
+			}
+		}
+	}
+	return "ip:" + c.IP(r), r
+}
+
+// IP returns the client IP of r. Behind trusted proxies it is the last
+// X-Forwarded-For address that is not a trusted proxy, as the addresses
+// before it may be forged by the client.
+func (c *Clients) IP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !c.trusted(addr) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			// a malformed hop cannot be trusted, nor anything before it
+			break
+		}
+		addr = hop.Unmap()
+		if !c.trusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func (c *Clients) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
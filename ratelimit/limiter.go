@@ -0,0 +1,126 @@
+// Package ratelimit limits the requests of every client to the HTTP API with
+// token buckets: a bucket holds up to a burst of tokens, refilled at a steady
+// rate, and every request takes one. The buckets are kept in memory, or in
+// Postgres to share the limits between the replicas.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"user-manager/config"
+	"user-manager/dto"
+	"user-manager/metrics"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Store keeps the token buckets.
+type Store interface {
+	// Take takes a token from the bucket of key, holding up to burst tokens
+	// refilled at rate tokens per second. It returns the tokens left, and
+	// whether a token was taken.
+	Take(ctx context.Context, key string, burst int, rate float64) (remaining float64, allowed bool, err error)
+}
+
+// Limiter is the rate limiting middleware of the HTTP API.
+type Limiter struct {
+	limits config.RateLimits
+	store  Store
+	key    func(r *http.Request) (string, *http.Request)
+}
+
+// New returns a limiter applying limits to the clients identified by key,
+// which returns the request to pass on, such as Clients.Key.
+func New(limits config.RateLimits, store Store, key func(r *http.Request) (string, *http.Request)) *Limiter {
+	return &Limiter{limits: limits, store: store, key: key}
+}
+
+// Handler limits the requests to the routes of root. A route listed in the
+// route limits has its own bucket per client, the other routes share the
+// default bucket of the client. It fails open when the store fails, counting
+// the requests let through in metrics.
+func (l *Limiter) Handler(root chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, r := l.key(r)
+			limit := l.limits.Default
+			if route := routeOf(root, r); route != "" {
+				if routeLimit, ok := l.limits.Routes[route]; ok {
+					limit = routeLimit
+					key += " " + route
+				}
+			}
+			if limit.Requests == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			burst := limit.Requests
+			rate := float64(limit.Requests) / limit.Period.Seconds()
+			remaining, allowed, err := l.store.Take(r.Context(), key, burst, rate)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error on taking rate limit token", "error", err)
+				metrics.RateLimitFailedOpen()
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(int(remaining)))
+			h.Set("RateLimit-Reset", seconds((float64(burst)-remaining)/rate))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", burst, seconds(limit.Period.Seconds())))
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := seconds((1 - remaining) / rate)
+			h.Set("Retry-After", retryAfter)
+			slog.InfoContext(r.Context(), "rate limit exceeded", "key", key)
+			writeTooManyRequests(w, r, retryAfter)
+		})
+	}
+}
+
+// routeOf returns the method and chi route pattern of the request, such as
+// "PATCH /users/{id}", or "" when no route matches. The trailing slash of the
+// root of a subrouter is trimmed, so that POST /users is "POST /users".
+func routeOf(root chi.Routes, r *http.Request) string {
+	rctx := chi.NewRouteContext()
+	if !root.Match(rctx, r.Method, r.URL.Path) {
+		return ""
+	}
+	pattern := rctx.RoutePattern()
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return r.Method + " " + pattern
+}
+
+// seconds formats a delay in whole seconds, rounded up.
+func seconds(s float64) string {
+	return strconv.Itoa(int(math.Ceil(max(s, 0))))
+}
+
+func writeTooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	err := json.NewEncoder(w).Encode(dto.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusTooManyRequests),
+		Status:   http.StatusTooManyRequests,
+		Detail:   "Rate limit exceeded, retry in " + retryAfter + " seconds",
+		Instance: r.URL.Path,
+		Code:     "rate_limited",
+	})
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on writing problem response", "error", err)
+	}
+}
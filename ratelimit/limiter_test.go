@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+	"user-manager/auth"
+	"user-manager/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newRouter(limits config.RateLimits, store Store) chi.Router {
+	clients := &Clients{}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := chi.NewRouter()
+	r.Group(func(limited chi.Router) {
+		limited.Use(New(limits, store, clients.Key).Handler(r))
+		limited.Route("/users", func(r chi.Router) {
+			r.Get("/", ok)
+			r.Post("/", ok)
+			r.Get("/{id}", ok)
+		})
+	})
+	r.Get("/healthz", ok)
+	return r
+}
+
+func serve(r http.Handler, method string, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimiter(t *testing.T) {
+	limits := config.RateLimits{Default: config.RateLimit{Requests: 2, Period: time.Minute}}
+	r := newRouter(limits, NewMemoryStore())
+
+	rec := serve(r, http.MethodGet, "/users/1")
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("Test Failure! Unexpected response: %d %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("RateLimit-Policy") != "2;w=60" || rec.Header().Get("RateLimit-Reset") != "30" {
+		t.Errorf("Test Failure! Unexpected policy headers: %v", rec.Header())
+	}
+
+	serve(r, http.MethodGet, "/users/")
+	rec = serve(r, http.MethodGet, "/users/2")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("Test Failure! Expected 429 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Test Failure! Expected a problem response, got %s", rec.Header().Get("Content-Type"))
+	}
+
+	rec = serve(r, http.MethodGet, "/healthz")
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Test Failure! Expected unlimited probe, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestLimiterRoutes(t *testing.T) {
+	limits := config.RateLimits{
+		Default: config.RateLimit{Requests: 100, Period: time.Minute},
+		Routes: map[string]config.RateLimit{
+			"POST /users":     {Requests: 1, Period: time.Minute},
+			"GET /users/{id}": {},
+		},
+	}
+	r := newRouter(limits, NewMemoryStore())
+
+	if rec := serve(r, http.MethodPost, "/users"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("Test Failure! Expected the route limit, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve(r, http.MethodPost, "/users/"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Test Failure! Expected 429, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodGet, "/users/"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "99" {
+		t.Errorf("Test Failure! Expected the default bucket untouched, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve(r, http.MethodGet, "/users/1"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Test Failure! Expected an exempt route, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for range 2 {
+		store.Take(t.Context(), "ip:192.0.2.1", 2, 1)
+	}
+	if _, allowed, _ := store.Take(t.Context(), "ip:192.0.2.1", 2, 1); allowed {
+		t.Errorf("Test Failure! Expected an empty bucket")
+	}
+
+	now = now.Add(time.Second)
+	if remaining, allowed, _ := store.Take(t.Context(), "ip:192.0.2.1", 2, 1); !allowed || remaining != 0 {
+		t.Errorf("Test Failure! Expected a refilled token, got %v %v", remaining, allowed)
+	}
+
+	now = now.Add(time.Hour)
+	store.Take(t.Context(), "ip:192.0.2.2", 2, 1)
+	if len(store.buckets) != 1 {
+		t.Errorf("Test Failure! Expected the full bucket swept, got %d buckets", len(store.buckets))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	clients := &Clients{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		{"192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{"10.0.0.2:1234", "198.51.100.7", "198.51.100.7"},
+		{"10.0.0.2:1234", "203.0.113.9, 198.51.100.7, 10.0.0.3", "198.51.100.7"},
+		{"10.0.0.2:1234", "", "10.0.0.2"},
+		{"10.0.0.2:1234", "bogus, 10.0.0.3", "10.0.0.3"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if ip := clients.IP(req); ip != test.expected {
+			t.Errorf("Test Failure! Expected %s for %s %q, got %s", test.expected, test.remoteAddr, test.forwarded, ip)
+		}
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, burst int, rate float64) (float64, bool, error) {
+	return 0, false, errors.New("store unavailable")
+}
+
+func failOpenCount(t *testing.T) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "usermanager_rate_limit_fail_open_total" {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestLimiterFailOpen(t *testing.T) {
+	limits := config.RateLimits{Default: config.RateLimit{Requests: 1, Period: time.Minute}}
+	r := newRouter(limits, failingStore{})
+	before := failOpenCount(t)
+
+	for range 2 {
+		if rec := serve(r, http.MethodGet, "/users/1"); rec.Code != http.StatusOK {
+			t.Errorf("Test Failure! Expected the request let through, got %d", rec.Code)
+		}
+	}
+	if count := failOpenCount(t) - before; count != 2 {
+		t.Errorf("Test Failure! Expected 2 fail open requests counted, got %v", count)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	const secret = "user-manager-unit-test-secret-value"
+	verifier, err := auth.NewVerifier(secret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := &Clients{Verifier: verifier}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Authorization", "Bearer "+token)
+	key, verified := clients.Key(req)
+	if key != "sub:42" || verified == req {
+		t.Errorf("Test Failure! Expected the subject key and the verified claims, got %s", key)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token+"x")
+	key, verified = clients.Key(req)
+	if key != "ip:192.0.2.1" || verified != req {
+		t.Errorf("Test Failure! Expected the IP key for an invalid token, got %s", key)
+	}
+}
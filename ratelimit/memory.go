@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often the full buckets are dropped from a MemoryStore.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the buckets of a single replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+	swept   time.Time
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*rate.Limiter{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, burst int, r float64) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.swept) > sweepInterval {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = rate.NewLimiter(rate.Limit(r), burst)
+		s.buckets[key] = bucket
+	}
+	allowed := bucket.AllowN(now, 1)
+	return bucket.TokensAt(now), allowed, nil
+}
+
+// sweep drops the full buckets, which are the same as no bucket.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
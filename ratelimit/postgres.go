@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"user-manager/database"
+)
+
+// PostgresStore keeps the buckets in the rate_limits table, shared by the
+// replicas. Every token is taken by a single call of the
+// take_rate_limit_token function, which locks the row of the bucket.
+type PostgresStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresStore(queries *database.Queries) *PostgresStore {
+	return &PostgresStore{queries: queries}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, burst int, rate float64) (float64, bool, error) {
+	row, err := s.queries.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		BucketKey: key,
+		Burst:     float64(burst),
+		Rate:      rate,
+	})
+	return row.Remaining, row.Allowed, err
+}
+
+// Run deletes the full buckets every interval until ctx is cancelled.
+func (s *PostgresStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, err := s.queries.DeleteFullRateLimits(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "error on deleting full rate limit buckets", "error", err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-manager/database"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestRequestsSpan(t *testing.T) {
+	recorder := record(t)
+
+	r := chi.NewRouter()
+	r.Use(Requests)
+	r.Route("/users", func(r chi.Router) {
+		r.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodPatch, "/users/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Test Failure! Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != "PATCH /users/{id}" {
+		t.Errorf("Test Failure! Span not named after the route: %s", spans[0].Name())
+	}
+	if spans[0].SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Test Failure! traceparent not continued: %s", spans[0].SpanContext().TraceID())
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	tests := map[string]string{
+		"-- name: GetUser :one\nSELECT 1":                      "GetUser",
+		"-- name: CountUsers :one\nSELECT COUNT(*) FROM users": "CountUsers",
+		"begin": "other",
+	}
+	for sql, expected := range tests {
+		if name := database.QueryName(sql); name != expected {
+			t.Errorf("Test Failure! Expected %s for %q, got %s", expected, sql, name)
+		}
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup(t.Context(), "jaeger")
+	if err == nil {
+		t.Errorf("Test Failure! Unknown exporter accepted")
+	}
+}